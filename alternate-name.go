@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+)
+
+// What sort of name an alternate name is
+type AlternateNameKind string
+
+const (
+	AlternateNameMaiden  AlternateNameKind = "maiden"
+	AlternateNameMarried AlternateNameKind = "married"
+	AlternateNameBirth   AlternateNameKind = "birth"
+	AlternateNameAlias   AlternateNameKind = "alias"
+)
+
+var alternateNameKinds = []AlternateNameKind{
+	AlternateNameMaiden, AlternateNameMarried, AlternateNameBirth, AlternateNameAlias,
+}
+
+func (k AlternateNameKind) IsValid() bool {
+	for _, kind := range alternateNameKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (k AlternateNameKind) Format() string {
+	switch k {
+	case AlternateNameMaiden:
+		return "Maiden name"
+	case AlternateNameMarried:
+		return "Married name"
+	case AlternateNameBirth:
+		return "Birth name"
+	default:
+		return "Also known as"
+	}
+}
+
+// Another name someone is known by, like a maiden name.  Searches find people
+// by these too.
+type AlternateName struct {
+	Id       int
+	PersonId int
+	Kind     AlternateNameKind
+	Name     string
+}
+
+func (s *MySqlStore) LoadAlternateNames(personId int) ([]AlternateName, error) {
+	defer trace(traceName(fmt.Sprintf("LoadAlternateNames(%d)", personId)))
+	rows, err := s.db.Query(
+		"SELECT id, person_id, kind, name"+
+			" FROM alternate_names"+
+			" WHERE person_id=?"+
+			" ORDER BY id", personId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []AlternateName
+	for rows.Next() {
+		var item AlternateName
+		err = rows.Scan(&item.Id, &item.PersonId, &item.Kind, &item.Name)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, rows.Err()
+}
+
+func (s *MySqlStore) InsertAlternateName(personId int, kind AlternateNameKind, name string) (int, error) {
+	defer trace(traceName(fmt.Sprintf("InsertAlternateName(%d, %s, %s)", personId, kind, name)))
+	res, err := s.db.Exec(
+		"INSERT INTO alternate_names (person_id, kind, name) VALUES(?, ?, ?)",
+		personId, kind, name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func (s *MySqlStore) DeleteAlternateName(personId int, id int) error {
+	defer trace(traceName(fmt.Sprintf("DeleteAlternateName(%d, %d)", personId, id)))
+	_, err := s.db.Exec("DELETE FROM alternate_names WHERE id=? AND person_id=?", id, personId)
+	return err
+}
@@ -0,0 +1,1322 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var apiColorRegexp = regexp.MustCompile(`^[0-9A-Fa-f]{6}$`)
+
+// A date without a time, like "2006-01-02"
+type apiDate string
+
+// The date, or nil when it isn't known so it's sent as null
+func newApiDate(date time.Time) *apiDate {
+	if date.IsZero() {
+		return nil
+	}
+	value := apiDate(date.Format("2006-01-02"))
+	return &value
+}
+
+// A person as the API returns it: the fields it's saved with plus its id and
+// full name.  The dates hide PersonData's so unknown ones are null.
+type apiPersonRecord struct {
+	Id   int
+	Name string
+	PersonData
+	BirthDate *apiDate
+	DeathDate *apiDate
+}
+
+func newApiPersonRecord(person *Person) apiPersonRecord {
+	return apiPersonRecord{
+		Id:         person.Id,
+		Name:       person.FullName(),
+		PersonData: person.Data(),
+		BirthDate:  newApiDate(person.BirthDate),
+		DeathDate:  newApiDate(person.DeathDate),
+	}
+}
+
+// A person along with their places and family
+type apiFamilyRecord struct {
+	apiPersonRecord
+	BirthCity  *CityLite
+	DeathCity  *CityLite
+	BurialCity *CityLite
+	HomeCity   *CityLite
+	Mother     *PersonLite
+	Father     *PersonLite
+	Children   []PersonLite
+	Spouses    []apiSpouseRecord
+	Siblings   []PersonLite
+}
+
+func newApiFamilyRecord(person *Person) apiFamilyRecord {
+	family := apiFamilyRecord{
+		apiPersonRecord: newApiPersonRecord(person),
+		BirthCity:       person.BirthCity,
+		DeathCity:       person.DeathCity,
+		BurialCity:      person.BurialCity,
+		HomeCity:        person.HomeCity,
+		Mother:          person.Mother,
+		Father:          person.Father,
+		Children:        person.Children,
+		Siblings:        person.Siblings,
+	}
+	for i := range person.Spouses {
+		family.Spouses = append(family.Spouses, newApiSpouseRecord(&person.Spouses[i]))
+	}
+	return family
+}
+
+type apiSpouseData struct {
+	Person1Id   int
+	Person2Id   int
+	Status      int // 1=MARRIED,2=DATING,3=EX-MARRIED
+	MarriedDate apiDate
+}
+
+// A spouse as the API returns it
+type apiSpouseRecord struct {
+	Person1     PersonLite
+	Person2     PersonLite
+	Status      int // 1=MARRIED,2=DATING,3=EX-MARRIED
+	MarriedDate *apiDate
+}
+
+func newApiSpouseRecord(spouse *SpouseLite) apiSpouseRecord {
+	return apiSpouseRecord{Person1: spouse.Person1, Person2: spouse.Person2, Status: spouse.Status, MarriedDate: newApiDate(spouse.MarriedDate)}
+}
+
+type apiAlternateNameData struct {
+	Kind AlternateNameKind
+	Name string
+}
+
+type apiCityData struct {
+	Name      string
+	RegionId  int
+	Latitude  float32
+	Longitude float32
+}
+
+type apiTagData struct {
+	Label string
+}
+
+// A holiday as the API returns it, with every date it's been worked out for
+type apiHolidayRecord struct {
+	Id int
+	HolidayData
+	Dates []apiDate
+}
+
+func newApiHolidayRecord(holiday *HolidayDefinition) apiHolidayRecord {
+	record := apiHolidayRecord{Id: holiday.Id, HolidayData: holiday.HolidayData, Dates: []apiDate{}}
+	for _, date := range holiday.Dates {
+		record.Dates = append(record.Dates, apiDate(date.Format("2006-01-02")))
+	}
+	return record
+}
+
+// A tag along with the people who have it
+type apiTagWithPeople struct {
+	Tag
+	People []PersonLite
+}
+
+func isValidApiDate(date string) bool {
+	_, err := time.Parse("2006-01-02", date)
+	return err == nil
+}
+
+// People
+
+func apiPersonList(r *http.Request, params apiParams) (int, interface{}, error) {
+	people, err := store.LoadPersonLiteList()
+	return http.StatusOK, people, err
+}
+
+// The person with their parents, children, spouses and siblings
+func apiPersonFamily(r *http.Request, params apiParams) (int, interface{}, error) {
+	personId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	person, err := store.LoadPersonById(personId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusOK, newApiFamilyRecord(person), nil
+}
+
+func apiPersonGet(r *http.Request, params apiParams) (int, interface{}, error) {
+	personId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	person, err := store.LoadPersonById(personId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusOK, newApiPersonRecord(person), nil
+}
+
+// Read and check a person's fields.  personId is 0 for a new person.
+func decodeApiPerson(r *http.Request, personId int) (*PersonData, error) {
+	var data PersonData
+	err := decodeApiBody(r, &data)
+	if err != nil {
+		return nil, err
+	}
+	data.FirstName = strings.TrimSpace(data.FirstName)
+	data.MiddleName = strings.TrimSpace(data.MiddleName)
+	data.LastName = strings.TrimSpace(data.LastName)
+	data.NickName = strings.TrimSpace(data.NickName)
+	data.BirthDate = strings.TrimSpace(data.BirthDate)
+	data.DeathDate = strings.TrimSpace(data.DeathDate)
+
+	fields := make(apiFieldErrors)
+	if data.FirstName == "" {
+		fields.add("FirstName", "is required")
+	}
+	if data.Gender != "M" && data.Gender != "F" && data.Gender != "U" {
+		fields.add("Gender", "must be M, F or U")
+	}
+	if data.BirthDate != "" && !isValidApiDate(data.BirthDate) {
+		fields.add("BirthDate", "must be a date like 2006-01-02")
+	}
+	if data.DeathDate != "" {
+		if !isValidApiDate(data.DeathDate) {
+			fields.add("DeathDate", "must be a date like 2006-01-02")
+		}
+		data.IsAlive = false
+	}
+	for field, cityId := range map[string]int{
+		"BirthCityId":  data.BirthCityId,
+		"DeathCityId":  data.DeathCityId,
+		"BurialCityId": data.BurialCityId,
+		"HomeCityId":   data.HomeCityId,
+	} {
+		if cityId == 0 {
+			continue
+		}
+		_, err := store.LoadCityById(cityId)
+		if errors.Is(err, ErrNotFound) {
+			fields.add(field, "city %d doesn't exist", cityId)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	problems, err := checkPersonParents(store, personId, data.MotherId, data.FatherId)
+	if err != nil {
+		return nil, err
+	}
+	for field, problem := range problems {
+		fields.add(field, "%s", problem)
+	}
+	err = fields.err()
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func apiPersonAdd(r *http.Request, params apiParams) (int, interface{}, error) {
+	data, err := decodeApiPerson(r, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	personId, err := store.InsertPerson(*data)
+	if err != nil {
+		return 0, nil, err
+	}
+	person, err := store.LoadPersonById(personId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, newApiPersonRecord(person), nil
+}
+
+func apiPersonUpdate(r *http.Request, params apiParams) (int, interface{}, error) {
+	personId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadPersonLiteById(personId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	data, err := decodeApiPerson(r, personId)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.UpdatePerson(personId, *data)
+	if err != nil {
+		return 0, nil, err
+	}
+	person, err := store.LoadPersonById(personId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, newApiPersonRecord(person), nil
+}
+
+func apiPersonDelete(r *http.Request, params apiParams) (int, interface{}, error) {
+	personId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadPersonLiteById(personId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusNoContent, nil, store.DeletePerson(personId)
+}
+
+func apiPersonSearch(r *http.Request, params apiParams) (int, interface{}, error) {
+	offset, err := apiQueryInt(r, "offset", 0, 0, math.MaxInt32)
+	if err != nil {
+		return 0, nil, err
+	}
+	limit, err := apiQueryInt(r, "limit", personSearchPageSize, 1, 100)
+	if err != nil {
+		return 0, nil, err
+	}
+	results, err := SearchPeople(store, r.URL.Query().Get("q"), offset, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+	if results.People == nil {
+		results.People = []PersonSearchHit{}
+	}
+	return http.StatusOK, results, nil
+}
+
+func apiAlternateNameList(r *http.Request, params apiParams) (int, interface{}, error) {
+	personId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadPersonLiteById(personId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	names, err := store.LoadAlternateNames(personId)
+	return http.StatusOK, names, err
+}
+
+func apiAlternateNameAdd(r *http.Request, params apiParams) (int, interface{}, error) {
+	personId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadPersonLiteById(personId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	var data apiAlternateNameData
+	err = decodeApiBody(r, &data)
+	if err != nil {
+		return 0, nil, err
+	}
+	data.Name = strings.TrimSpace(data.Name)
+	fields := make(apiFieldErrors)
+	if !data.Kind.IsValid() {
+		fields.add("Kind", "must be maiden, married, birth or alias")
+	}
+	if data.Name == "" {
+		fields.add("Name", "is required")
+	}
+	err = fields.err()
+	if err != nil {
+		return 0, nil, err
+	}
+	id, err := store.InsertAlternateName(personId, data.Kind, data.Name)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, AlternateName{Id: id, PersonId: personId, Kind: data.Kind, Name: data.Name}, nil
+}
+
+func apiAlternateNameDelete(r *http.Request, params apiParams) (int, interface{}, error) {
+	personId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	nameId, err := params.int("nameId")
+	if err != nil {
+		return 0, nil, err
+	}
+	names, err := store.LoadAlternateNames(personId)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, name := range names {
+		if name.Id == nameId {
+			return http.StatusNoContent, nil, store.DeleteAlternateName(personId, nameId)
+		}
+	}
+	return 0, nil, apiNotFound("Alternate name not found with id: %d", nameId)
+}
+
+// Spouses
+
+func apiSpouseList(r *http.Request, params apiParams) (int, interface{}, error) {
+	spouses, err := store.LoadSpouseList()
+	if err != nil {
+		return 0, nil, err
+	}
+	records := make([]apiSpouseRecord, len(spouses))
+	for i := range spouses {
+		records[i] = newApiSpouseRecord(&spouses[i])
+	}
+	return http.StatusOK, records, nil
+}
+
+func loadApiSpouse(person1Id int, person2Id int) (*apiSpouseRecord, error) {
+	spouses, err := store.LoadSpousesByPersonId(person1Id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range spouses {
+		if spouses[i].Person1.Id == person2Id || spouses[i].Person2.Id == person2Id {
+			record := newApiSpouseRecord(&spouses[i])
+			return &record, nil
+		}
+	}
+	return nil, apiNotFound("Spouse not found with person1_id: %d, person2_id: %d", person1Id, person2Id)
+}
+
+func apiSpouseGet(r *http.Request, params apiParams) (int, interface{}, error) {
+	person1Id, err := params.int("person1Id")
+	if err != nil {
+		return 0, nil, err
+	}
+	person2Id, err := params.int("person2Id")
+	if err != nil {
+		return 0, nil, err
+	}
+	spouse, err := loadApiSpouse(person1Id, person2Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, spouse, nil
+}
+
+// Check the status and married date shared by adding and updating a spouse
+func validateApiSpouse(data *apiSpouseData, fields apiFieldErrors) {
+	data.MarriedDate = apiDate(strings.TrimSpace(string(data.MarriedDate)))
+	if data.Status < 1 || data.Status > 3 {
+		fields.add("Status", "must be 1 (married), 2 (dating) or 3 (ex-married)")
+	}
+	if data.MarriedDate != "" && !isValidApiDate(string(data.MarriedDate)) {
+		fields.add("MarriedDate", "must be a date like 2006-01-02")
+	}
+}
+
+func apiSpouseAdd(r *http.Request, params apiParams) (int, interface{}, error) {
+	var data apiSpouseData
+	err := decodeApiBody(r, &data)
+	if err != nil {
+		return 0, nil, err
+	}
+	fields := make(apiFieldErrors)
+	for field, personId := range map[string]int{"Person1Id": data.Person1Id, "Person2Id": data.Person2Id} {
+		_, err := store.LoadPersonLiteById(personId)
+		if errors.Is(err, ErrNotFound) {
+			fields.add(field, "person %d doesn't exist", personId)
+		} else if err != nil {
+			return 0, nil, err
+		}
+	}
+	if data.Person1Id == data.Person2Id {
+		fields.add("Person2Id", "must be someone other than Person1Id")
+	}
+	validateApiSpouse(&data, fields)
+	err = fields.err()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	exists, err := store.SpouseExists(data.Person1Id, data.Person2Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if exists {
+		return 0, nil, apiConflict("%d and %d are already spouses", data.Person1Id, data.Person2Id)
+	}
+	err = store.InsertSpouse(data.Person1Id, data.Person2Id, data.Status, string(data.MarriedDate))
+	if err != nil {
+		return 0, nil, err
+	}
+	spouse, err := loadApiSpouse(data.Person1Id, data.Person2Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, spouse, nil
+}
+
+func apiSpouseUpdate(r *http.Request, params apiParams) (int, interface{}, error) {
+	person1Id, err := params.int("person1Id")
+	if err != nil {
+		return 0, nil, err
+	}
+	person2Id, err := params.int("person2Id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = loadApiSpouse(person1Id, person2Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	var data apiSpouseData
+	err = decodeApiBody(r, &data)
+	if err != nil {
+		return 0, nil, err
+	}
+	// The people come from the path, so only a matching pair is accepted in the body
+	fields := make(apiFieldErrors)
+	if data.Person1Id != 0 || data.Person2Id != 0 {
+		bodyPerson1Id, bodyPerson2Id := orderedPair(data.Person1Id, data.Person2Id)
+		pathPerson1Id, pathPerson2Id := orderedPair(person1Id, person2Id)
+		if bodyPerson1Id != pathPerson1Id || bodyPerson2Id != pathPerson2Id {
+			fields.add("Person1Id", "the people can't be changed, delete the spouse and add a new one")
+		}
+	}
+	validateApiSpouse(&data, fields)
+	err = fields.err()
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.UpdateSpouse(person1Id, person2Id, data.Status, string(data.MarriedDate))
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	spouse, err := loadApiSpouse(person1Id, person2Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, spouse, nil
+}
+
+func apiSpouseDelete(r *http.Request, params apiParams) (int, interface{}, error) {
+	person1Id, err := params.int("person1Id")
+	if err != nil {
+		return 0, nil, err
+	}
+	person2Id, err := params.int("person2Id")
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.DeleteSpouse(person1Id, person2Id)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// Cities
+
+func apiCityList(r *http.Request, params apiParams) (int, interface{}, error) {
+	cities, err := store.LoadCityList()
+	return http.StatusOK, cities, err
+}
+
+func apiCityGet(r *http.Request, params apiParams) (int, interface{}, error) {
+	cityId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	city, err := store.LoadCityById(cityId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusOK, city, nil
+}
+
+func decodeApiCity(r *http.Request) (*apiCityData, error) {
+	var data apiCityData
+	err := decodeApiBody(r, &data)
+	if err != nil {
+		return nil, err
+	}
+	data.Name = strings.TrimSpace(data.Name)
+	fields := make(apiFieldErrors)
+	if data.Name == "" {
+		fields.add("Name", "is required")
+	}
+	_, err = store.LoadRegionById(data.RegionId)
+	if errors.Is(err, ErrNotFound) {
+		fields.add("RegionId", "region %d doesn't exist", data.RegionId)
+	} else if err != nil {
+		return nil, err
+	}
+	if data.Latitude < -90 || data.Latitude > 90 {
+		fields.add("Latitude", "must be between -90 and 90")
+	}
+	if data.Longitude < -180 || data.Longitude > 180 {
+		fields.add("Longitude", "must be between -180 and 180")
+	}
+	err = fields.err()
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func apiCityAdd(r *http.Request, params apiParams) (int, interface{}, error) {
+	data, err := decodeApiCity(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	city, err := store.InsertCity(data.Name, data.RegionId, data.Latitude, data.Longitude)
+	if err != nil {
+		return 0, nil, err
+	}
+	city, err = store.LoadCityById(city.Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, city, nil
+}
+
+func apiCityUpdate(r *http.Request, params apiParams) (int, interface{}, error) {
+	cityId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadCityById(cityId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	data, err := decodeApiCity(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.UpdateCity(cityId, data.Name, data.RegionId, data.Latitude, data.Longitude)
+	if err != nil {
+		return 0, nil, err
+	}
+	city, err := store.LoadCityById(cityId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, city, nil
+}
+
+func apiCityDelete(r *http.Request, params apiParams) (int, interface{}, error) {
+	cityId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.DeleteCity(cityId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// Regions
+
+func apiRegionList(r *http.Request, params apiParams) (int, interface{}, error) {
+	regions, err := store.LoadRegionList()
+	return http.StatusOK, regions, err
+}
+
+func apiRegionGet(r *http.Request, params apiParams) (int, interface{}, error) {
+	regionId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	region, err := store.LoadRegionById(regionId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusOK, region, nil
+}
+
+func decodeApiRegion(r *http.Request) (*RegionData, error) {
+	var data RegionData
+	err := decodeApiBody(r, &data)
+	if err != nil {
+		return nil, err
+	}
+	data.Code = strings.TrimSpace(data.Code)
+	data.Name = strings.TrimSpace(data.Name)
+	data.CountryCode = strings.TrimSpace(data.CountryCode)
+	fields := make(apiFieldErrors)
+	if data.Code == "" {
+		fields.add("Code", "is required")
+	}
+	if data.Name == "" {
+		fields.add("Name", "is required")
+	}
+	_, err = store.LoadCountryByCode(data.CountryCode)
+	if errors.Is(err, ErrNotFound) {
+		fields.add("CountryCode", "country %q doesn't exist", data.CountryCode)
+	} else if err != nil {
+		return nil, err
+	}
+	err = fields.err()
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func apiRegionAdd(r *http.Request, params apiParams) (int, interface{}, error) {
+	data, err := decodeApiRegion(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	region, err := store.InsertRegion(*data)
+	if err != nil {
+		return 0, nil, err
+	}
+	region, err = store.LoadRegionById(region.Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, region, nil
+}
+
+func apiRegionUpdate(r *http.Request, params apiParams) (int, interface{}, error) {
+	regionId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadRegionById(regionId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	data, err := decodeApiRegion(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.UpdateRegion(regionId, *data)
+	if err != nil {
+		return 0, nil, err
+	}
+	region, err := store.LoadRegionById(regionId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, region, nil
+}
+
+func apiRegionDelete(r *http.Request, params apiParams) (int, interface{}, error) {
+	regionId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadRegionById(regionId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusNoContent, nil, store.DeleteRegion(regionId)
+}
+
+// Countries
+
+func apiCountryList(r *http.Request, params apiParams) (int, interface{}, error) {
+	countries, err := store.LoadCountryList()
+	return http.StatusOK, countries, err
+}
+
+func apiCountryGet(r *http.Request, params apiParams) (int, interface{}, error) {
+	country, err := store.LoadCountryByCode(params["code"])
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusOK, country, nil
+}
+
+// Read and check a country's fields.  originalCode is empty for a new country.
+func decodeApiCountry(r *http.Request, originalCode string) (*CountryData, error) {
+	var data CountryData
+	err := decodeApiBody(r, &data)
+	if err != nil {
+		return nil, err
+	}
+	data.Code = strings.ToUpper(strings.TrimSpace(data.Code))
+	data.Name = strings.TrimSpace(data.Name)
+	data.ContinentCode = strings.ToUpper(strings.TrimSpace(data.ContinentCode))
+	fields := make(apiFieldErrors)
+	if len(data.Code) != 2 {
+		fields.add("Code", "must be 2 letters")
+	}
+	if data.Name == "" {
+		fields.add("Name", "is required")
+	}
+	if data.Gdp < 0 {
+		fields.add("Gdp", "can't be negative")
+	}
+	if data.Population < 0 {
+		fields.add("Population", "can't be negative")
+	}
+	_, err = store.LoadContinentByCode(data.ContinentCode)
+	if errors.Is(err, ErrNotFound) {
+		fields.add("ContinentCode", "continent %q doesn't exist", data.ContinentCode)
+	} else if err != nil {
+		return nil, err
+	}
+	if data.CapitalCityId != 0 {
+		_, err = store.LoadCityById(data.CapitalCityId)
+		if errors.Is(err, ErrNotFound) {
+			fields.add("CapitalCityId", "city %d doesn't exist", data.CapitalCityId)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	err = fields.err()
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Code != originalCode {
+		_, err = store.LoadCountryByCode(data.Code)
+		if err == nil {
+			return nil, apiConflict("There's already a country with code %s", data.Code)
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return &data, nil
+}
+
+func apiCountryAdd(r *http.Request, params apiParams) (int, interface{}, error) {
+	data, err := decodeApiCountry(r, "")
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.InsertCountry(*data)
+	if err != nil {
+		return 0, nil, err
+	}
+	country, err := store.LoadCountryByCode(data.Code)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, country, nil
+}
+
+func apiCountryUpdate(r *http.Request, params apiParams) (int, interface{}, error) {
+	code := params["code"]
+	_, err := store.LoadCountryByCode(code)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	data, err := decodeApiCountry(r, code)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.UpdateCountry(code, *data)
+	if err != nil {
+		return 0, nil, err
+	}
+	country, err := store.LoadCountryByCode(data.Code)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, country, nil
+}
+
+func apiCountryDelete(r *http.Request, params apiParams) (int, interface{}, error) {
+	err := store.DeleteCountryByCode(params["code"])
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// Continents
+
+func apiContinentList(r *http.Request, params apiParams) (int, interface{}, error) {
+	continents, err := store.LoadContinentList()
+	return http.StatusOK, continents, err
+}
+
+func apiContinentGet(r *http.Request, params apiParams) (int, interface{}, error) {
+	continent, err := store.LoadContinentByCode(params["code"])
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusOK, continent, nil
+}
+
+// Read and check a continent's fields.  originalCode is empty for a new
+// continent.
+func decodeApiContinent(r *http.Request, originalCode string) (*ContinentWithMap, error) {
+	var data ContinentWithMap
+	err := decodeApiBody(r, &data)
+	if err != nil {
+		return nil, err
+	}
+	data.Code = strings.ToUpper(strings.TrimSpace(data.Code))
+	data.Name = strings.TrimSpace(data.Name)
+	data.Color = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(data.Color), "#"))
+	fields := make(apiFieldErrors)
+	if len(data.Code) != 2 {
+		fields.add("Code", "must be 2 letters")
+	}
+	if data.Name == "" {
+		fields.add("Name", "is required")
+	}
+	if data.Color != "" && !apiColorRegexp.MatchString(data.Color) {
+		fields.add("Color", "must be 6 hex digits, like 3366CC")
+	}
+	if data.MapLatitude < -90 || data.MapLatitude > 90 {
+		fields.add("MapLatitude", "must be between -90 and 90")
+	}
+	if data.MapLongitude < -180 || data.MapLongitude > 180 {
+		fields.add("MapLongitude", "must be between -180 and 180")
+	}
+	if data.MapZoom < 0 || data.MapZoom > 21 {
+		fields.add("MapZoom", "must be between 0 and 21")
+	}
+	err = fields.err()
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Code != originalCode {
+		_, err = store.LoadContinentByCode(data.Code)
+		if err == nil {
+			return nil, apiConflict("There's already a continent with code %s", data.Code)
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return &data, nil
+}
+
+func apiContinentAdd(r *http.Request, params apiParams) (int, interface{}, error) {
+	data, err := decodeApiContinent(r, "")
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.InsertContinent(*data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, data, nil
+}
+
+func apiContinentUpdate(r *http.Request, params apiParams) (int, interface{}, error) {
+	code := params["code"]
+	_, err := store.LoadContinentByCode(code)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	data, err := decodeApiContinent(r, code)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.UpdateContinent(code, *data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, data, nil
+}
+
+func apiContinentDelete(r *http.Request, params apiParams) (int, interface{}, error) {
+	code := params["code"]
+	_, err := store.LoadContinentByCode(code)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	countries, err := store.LoadCountriesByContinentCode(code)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(countries) > 0 {
+		return 0, nil, apiConflict("The continent still has %d countries", len(countries))
+	}
+	return http.StatusNoContent, nil, store.DeleteContinent(code)
+}
+
+// Tags
+
+func apiTagList(r *http.Request, params apiParams) (int, interface{}, error) {
+	tags, err := store.LoadTagsListByPrefix("")
+	return http.StatusOK, tags, err
+}
+
+func loadApiTag(tagId int) (*apiTagWithPeople, error) {
+	tag, err := store.LoadTagById(tagId)
+	if err != nil {
+		return nil, apiLoadError(err)
+	}
+	people, err := store.LoadPersonLiteListWithTag(tag.Label)
+	if err != nil {
+		return nil, err
+	}
+	return &apiTagWithPeople{Tag: *tag, People: people}, nil
+}
+
+func apiTagGet(r *http.Request, params apiParams) (int, interface{}, error) {
+	tagId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	tag, err := loadApiTag(tagId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, tag, nil
+}
+
+// Read and check a tag's label.  tagId is 0 for a new tag.
+func decodeApiTag(r *http.Request, tagId int) (string, error) {
+	var data apiTagData
+	err := decodeApiBody(r, &data)
+	if err != nil {
+		return "", err
+	}
+	label := strings.TrimSpace(data.Label)
+	if label == "" {
+		return "", apiFieldErrors{"Label": "is required"}.err()
+	}
+	existing, err := store.LoadTagByLabel(label)
+	if err == nil && existing.Id != tagId {
+		return "", apiConflict("There's already a tag labelled %s", label)
+	} else if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	return label, nil
+}
+
+func apiTagAdd(r *http.Request, params apiParams) (int, interface{}, error) {
+	label, err := decodeApiTag(r, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	tag, err := store.InsertTag(label)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, apiTagWithPeople{Tag: *tag}, nil
+}
+
+func apiTagUpdate(r *http.Request, params apiParams) (int, interface{}, error) {
+	tagId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadTagById(tagId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	label, err := decodeApiTag(r, tagId)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.UpdateTag(tagId, label)
+	if err != nil {
+		return 0, nil, err
+	}
+	tag, err := loadApiTag(tagId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, tag, nil
+}
+
+func apiTagDelete(r *http.Request, params apiParams) (int, interface{}, error) {
+	tagId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadTagById(tagId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusNoContent, nil, store.DeleteTag(tagId)
+}
+
+// Tag a person, or untag them when remove is true
+func apiTagPerson(r *http.Request, params apiParams, remove bool) (int, interface{}, error) {
+	tagId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	personId, err := params.int("personId")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadTagById(tagId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	_, err = store.LoadPersonLiteById(personId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+
+	tags, err := store.LoadTagsForPerson(personId)
+	if err != nil {
+		return 0, nil, err
+	}
+	hasTag := false
+	for _, tag := range tags {
+		if tag.Id == tagId {
+			hasTag = true
+		}
+	}
+	if remove && hasTag {
+		err = store.DeletePeopleTag(tagId, personId)
+	} else if !remove && !hasTag {
+		err = store.InsertPeopleTag(tagId, personId)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	tag, err := loadApiTag(tagId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, tag, nil
+}
+
+// Holidays
+
+func apiHolidayList(r *http.Request, params apiParams) (int, interface{}, error) {
+	holidays, err := store.LoadHolidayList()
+	if err != nil {
+		return 0, nil, err
+	}
+	records := make([]apiHolidayRecord, len(holidays))
+	for i := range holidays {
+		records[i] = newApiHolidayRecord(&holidays[i])
+	}
+	return http.StatusOK, records, nil
+}
+
+func apiHolidayGet(r *http.Request, params apiParams) (int, interface{}, error) {
+	holidayId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	holiday, err := store.LoadHolidayById(holidayId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusOK, newApiHolidayRecord(holiday), nil
+}
+
+func decodeApiHoliday(r *http.Request) (*HolidayData, error) {
+	var data HolidayData
+	err := decodeApiBody(r, &data)
+	if err != nil {
+		return nil, err
+	}
+	data.Name = strings.TrimSpace(data.Name)
+	data.CountryCode = strings.ToUpper(strings.TrimSpace(data.CountryCode))
+	err = data.Validate()
+	if err != nil {
+		return nil, &ApiError{Status: http.StatusUnprocessableEntity, Code: "invalid", Message: err.Error()}
+	}
+	if data.CountryCode != "" {
+		_, err = store.LoadCountryByCode(data.CountryCode)
+		if errors.Is(err, ErrNotFound) {
+			return nil, apiFieldErrors{"CountryCode": "country " + data.CountryCode + " doesn't exist"}.err()
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return &data, nil
+}
+
+func apiHolidayAdd(r *http.Request, params apiParams) (int, interface{}, error) {
+	data, err := decodeApiHoliday(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	holidayId, err := SaveHoliday(store, 0, *data)
+	if err != nil {
+		return 0, nil, err
+	}
+	holiday, err := store.LoadHolidayById(holidayId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, newApiHolidayRecord(holiday), nil
+}
+
+func apiHolidayUpdate(r *http.Request, params apiParams) (int, interface{}, error) {
+	holidayId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = store.LoadHolidayById(holidayId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	data, err := decodeApiHoliday(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	_, err = SaveHoliday(store, holidayId, *data)
+	if err != nil {
+		return 0, nil, err
+	}
+	holiday, err := store.LoadHolidayById(holidayId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, newApiHolidayRecord(holiday), nil
+}
+
+func apiHolidayDelete(r *http.Request, params apiParams) (int, interface{}, error) {
+	holidayId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	err = store.DeleteHoliday(holidayId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// Add or remove one of the dates of a holiday whose dates are set by hand
+func apiHolidayDate(r *http.Request, params apiParams, remove bool) (int, interface{}, error) {
+	holidayId, err := params.int("id")
+	if err != nil {
+		return 0, nil, err
+	}
+	date, err := time.Parse("2006-01-02", params["date"])
+	if err != nil {
+		return 0, nil, apiNotFound("Invalid date: %s", params["date"])
+	}
+	holiday, err := store.LoadHolidayById(holidayId)
+	if err != nil {
+		return 0, nil, apiLoadError(err)
+	}
+	if holiday.Rule != HolidayRuleDates {
+		return 0, nil, apiConflict("The holiday's dates come from its rule")
+	}
+	if remove {
+		err = store.DeleteHolidayItem(holidayId, date)
+	} else {
+		err = store.InsertHolidayItem(holidayId, date)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	holiday, err = store.LoadHolidayById(holidayId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, newApiHolidayRecord(holiday), nil
+}
+
+// Every API endpoint, by collection.  The OpenAPI document is built from
+// this too, so it can't miss a route.
+func apiCollections() []apiCollection {
+	tagPerson := func(r *http.Request, params apiParams) (int, interface{}, error) {
+		return apiTagPerson(r, params, false)
+	}
+	untagPerson := func(r *http.Request, params apiParams) (int, interface{}, error) {
+		return apiTagPerson(r, params, true)
+	}
+	addHolidayDate := func(r *http.Request, params apiParams) (int, interface{}, error) {
+		return apiHolidayDate(r, params, false)
+	}
+	deleteHolidayDate := func(r *http.Request, params apiParams) (int, interface{}, error) {
+		return apiHolidayDate(r, params, true)
+	}
+
+	return []apiCollection{
+		{"people", "People in the family", []apiRoute{
+			{Method: "GET", Pattern: "", Role: RoleViewer, Handler: apiPersonList, Summary: "List people", Response: []PersonLite{}},
+			{Method: "POST", Pattern: "", Role: RoleEditor, Handler: apiPersonAdd, Summary: "Add a person", Request: PersonData{}, Response: apiPersonRecord{}},
+			{Method: "GET", Pattern: "search", Role: RoleViewer, Handler: apiPersonSearch, Summary: "Search people by any of their names, best matches first",
+				Query: []apiQueryParameter{
+					{"q", "string", "Words to find in first, middle, last, nick and alternate names.  Accents and small typos are ignored."},
+					{"offset", "integer", "Matches to skip, for later pages"},
+					{"limit", "integer", "Matches to return, up to 100"},
+				},
+				Response: PersonSearchResults{}},
+			{Method: "GET", Pattern: "{id}", Role: RoleViewer, Handler: apiPersonGet, Summary: "Get a person", Response: apiPersonRecord{}},
+			{Method: "GET", Pattern: "{id}/family", Role: RoleViewer, Handler: apiPersonFamily, Summary: "Get a person with their parents, children, spouses and siblings", Response: apiFamilyRecord{}},
+			{Method: "PUT", Pattern: "{id}", Role: RoleEditor, Handler: apiPersonUpdate, Summary: "Replace a person", Request: PersonData{}, Response: apiPersonRecord{}},
+			{Method: "DELETE", Pattern: "{id}", Role: RoleAdmin, Handler: apiPersonDelete, Summary: "Delete a person"},
+			{Method: "GET", Pattern: "{id}/names", Role: RoleViewer, Handler: apiAlternateNameList, Summary: "List a person's alternate names", Response: []AlternateName{}},
+			{Method: "POST", Pattern: "{id}/names", Role: RoleEditor, Handler: apiAlternateNameAdd, Summary: "Add an alternate name, like a maiden name", Request: apiAlternateNameData{}, Response: AlternateName{}},
+			{Method: "DELETE", Pattern: "{id}/names/{nameId}", Role: RoleEditor, Handler: apiAlternateNameDelete, Summary: "Delete an alternate name"},
+		}},
+		{"spouses", "Couples, by the ids of the two people in either order", []apiRoute{
+			{Method: "GET", Pattern: "", Role: RoleViewer, Handler: apiSpouseList, Summary: "List spouses", Response: []apiSpouseRecord{}},
+			{Method: "POST", Pattern: "", Role: RoleEditor, Handler: apiSpouseAdd, Summary: "Add a spouse", Request: apiSpouseData{}, Response: apiSpouseRecord{}},
+			{Method: "GET", Pattern: "{person1Id}/{person2Id}", Role: RoleViewer, Handler: apiSpouseGet, Summary: "Get a spouse", Response: apiSpouseRecord{}},
+			{Method: "PUT", Pattern: "{person1Id}/{person2Id}", Role: RoleEditor, Handler: apiSpouseUpdate, Summary: "Replace a spouse's status and married date", Request: apiSpouseData{}, Response: apiSpouseRecord{}},
+			{Method: "DELETE", Pattern: "{person1Id}/{person2Id}", Role: RoleAdmin, Handler: apiSpouseDelete, Summary: "Delete a spouse"},
+		}},
+		{"cities", "Cities people were born, lived, died and were buried in", []apiRoute{
+			{Method: "GET", Pattern: "", Role: RoleViewer, Handler: apiCityList, Summary: "List cities", Response: []CityLite{}},
+			{Method: "POST", Pattern: "", Role: RoleEditor, Handler: apiCityAdd, Summary: "Add a city", Request: apiCityData{}, Response: CityLite{}},
+			{Method: "GET", Pattern: "{id}", Role: RoleViewer, Handler: apiCityGet, Summary: "Get a city", Response: CityLite{}},
+			{Method: "PUT", Pattern: "{id}", Role: RoleEditor, Handler: apiCityUpdate, Summary: "Replace a city", Request: apiCityData{}, Response: CityLite{}},
+			{Method: "DELETE", Pattern: "{id}", Role: RoleAdmin, Handler: apiCityDelete, Summary: "Delete a city"},
+		}},
+		{"regions", "States, provinces and other regions of countries", []apiRoute{
+			{Method: "GET", Pattern: "", Role: RoleViewer, Handler: apiRegionList, Summary: "List regions", Response: []RegionLite{}},
+			{Method: "POST", Pattern: "", Role: RoleEditor, Handler: apiRegionAdd, Summary: "Add a region", Request: RegionData{}, Response: RegionLite{}},
+			{Method: "GET", Pattern: "{id}", Role: RoleViewer, Handler: apiRegionGet, Summary: "Get a region", Response: RegionLite{}},
+			{Method: "PUT", Pattern: "{id}", Role: RoleEditor, Handler: apiRegionUpdate, Summary: "Replace a region", Request: RegionData{}, Response: RegionLite{}},
+			{Method: "DELETE", Pattern: "{id}", Role: RoleAdmin, Handler: apiRegionDelete, Summary: "Delete a region"},
+		}},
+		{"countries", "Countries, by their 2 letter code", []apiRoute{
+			{Method: "GET", Pattern: "", Role: RoleViewer, Handler: apiCountryList, Summary: "List countries", Response: []Country{}},
+			{Method: "POST", Pattern: "", Role: RoleEditor, Handler: apiCountryAdd, Summary: "Add a country", Request: CountryData{}, Response: Country{}},
+			{Method: "GET", Pattern: "{code}", Role: RoleViewer, Handler: apiCountryGet, Summary: "Get a country", Response: Country{}},
+			{Method: "PUT", Pattern: "{code}", Role: RoleEditor, Handler: apiCountryUpdate, Summary: "Replace a country, which can change its code", Request: CountryData{}, Response: Country{}},
+			{Method: "DELETE", Pattern: "{code}", Role: RoleAdmin, Handler: apiCountryDelete, Summary: "Delete a country"},
+		}},
+		{"continents", "Continents, by their 2 letter code", []apiRoute{
+			{Method: "GET", Pattern: "", Role: RoleViewer, Handler: apiContinentList, Summary: "List continents", Response: []Continent{}},
+			{Method: "POST", Pattern: "", Role: RoleEditor, Handler: apiContinentAdd, Summary: "Add a continent", Request: ContinentWithMap{}, Response: ContinentWithMap{}},
+			{Method: "GET", Pattern: "{code}", Role: RoleViewer, Handler: apiContinentGet, Summary: "Get a continent", Response: ContinentWithMap{}},
+			{Method: "PUT", Pattern: "{code}", Role: RoleEditor, Handler: apiContinentUpdate, Summary: "Replace a continent, which can change its code", Request: ContinentWithMap{}, Response: ContinentWithMap{}},
+			{Method: "DELETE", Pattern: "{code}", Role: RoleAdmin, Handler: apiContinentDelete, Summary: "Delete a continent without any countries"},
+		}},
+		{"tags", "Labels for groups of people", []apiRoute{
+			{Method: "GET", Pattern: "", Role: RoleViewer, Handler: apiTagList, Summary: "List tags", Response: []Tag{}},
+			{Method: "POST", Pattern: "", Role: RoleEditor, Handler: apiTagAdd, Summary: "Add a tag", Request: apiTagData{}, Response: apiTagWithPeople{}},
+			{Method: "GET", Pattern: "{id}", Role: RoleViewer, Handler: apiTagGet, Summary: "Get a tag and its people", Response: apiTagWithPeople{}},
+			{Method: "PUT", Pattern: "{id}", Role: RoleEditor, Handler: apiTagUpdate, Summary: "Rename a tag", Request: apiTagData{}, Response: apiTagWithPeople{}},
+			{Method: "DELETE", Pattern: "{id}", Role: RoleAdmin, Handler: apiTagDelete, Summary: "Delete a tag"},
+			{Method: "PUT", Pattern: "{id}/people/{personId}", Role: RoleEditor, Handler: tagPerson, Summary: "Tag a person", Response: apiTagWithPeople{}},
+			{Method: "DELETE", Pattern: "{id}/people/{personId}", Role: RoleEditor, Handler: untagPerson, Summary: "Untag a person", Response: apiTagWithPeople{}},
+		}},
+		{"holidays", "Holidays and the rules for their dates", []apiRoute{
+			{Method: "GET", Pattern: "", Role: RoleViewer, Handler: apiHolidayList, Summary: "List holidays", Response: []apiHolidayRecord{}},
+			{Method: "POST", Pattern: "", Role: RoleEditor, Handler: apiHolidayAdd, Summary: "Add a holiday", Request: HolidayData{}, Response: apiHolidayRecord{}},
+			{Method: "GET", Pattern: "{id}", Role: RoleViewer, Handler: apiHolidayGet, Summary: "Get a holiday", Response: apiHolidayRecord{}},
+			{Method: "PUT", Pattern: "{id}", Role: RoleEditor, Handler: apiHolidayUpdate, Summary: "Replace a holiday", Request: HolidayData{}, Response: apiHolidayRecord{}},
+			{Method: "DELETE", Pattern: "{id}", Role: RoleAdmin, Handler: apiHolidayDelete, Summary: "Delete a holiday"},
+			{Method: "PUT", Pattern: "{id}/dates/{date}", Role: RoleEditor, Handler: addHolidayDate, Summary: "Add a date to a holiday whose dates are set by hand", Response: apiHolidayRecord{}},
+			{Method: "DELETE", Pattern: "{id}/dates/{date}", Role: RoleEditor, Handler: deleteHolidayDate, Summary: "Remove a date from a holiday whose dates are set by hand", Response: apiHolidayRecord{}},
+		}},
+	}
+}
+
+func addApiRoutes() {
+	for _, collection := range apiCollections() {
+		handleApi(collection.Name, collection.Routes...)
+	}
+	http.HandleFunc("/api/openapi.json", apiOpenApiDocument)
+
+	// Anything else under the prefix gets a JSON 404 rather than a page
+	http.HandleFunc(apiPrefix, func(w http.ResponseWriter, r *http.Request) {
+		writeApiError(w, apiNotFound("No API endpoint at %s", r.URL.Path))
+	})
+}
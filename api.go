@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Prefix of the versioned JSON API
+const apiPrefix = "/api/v1/"
+
+// Largest request body the API reads
+const maxApiBodyBytes = 1 << 20
+
+// An error returned by the API as a JSON object, eg.
+//
+//	{"Error": {"Status": 422, "Code": "invalid", "Message": "...", "Fields": {"FirstName": "is required"}}}
+type ApiError struct {
+	Status  int
+	Code    string
+	Message string
+	// Problems with individual fields of the request body, by field name
+	Fields map[string]string `json:",omitempty"`
+}
+
+func (e *ApiError) Error() string {
+	return e.Message
+}
+
+func apiBadRequest(format string, args ...interface{}) *ApiError {
+	return &ApiError{Status: http.StatusBadRequest, Code: "bad_request", Message: fmt.Sprintf(format, args...)}
+}
+
+func apiNotFound(format string, args ...interface{}) *ApiError {
+	return &ApiError{Status: http.StatusNotFound, Code: "not_found", Message: fmt.Sprintf(format, args...)}
+}
+
+func apiConflict(format string, args ...interface{}) *ApiError {
+	return &ApiError{Status: http.StatusConflict, Code: "conflict", Message: fmt.Sprintf(format, args...)}
+}
+
+// Problems with a request body's fields, by field name
+type apiFieldErrors map[string]string
+
+func (f apiFieldErrors) add(field string, format string, args ...interface{}) {
+	if _, found := f[field]; !found {
+		f[field] = fmt.Sprintf(format, args...)
+	}
+}
+
+// The fields as an error, or nil when there aren't any problems
+func (f apiFieldErrors) err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	return &ApiError{Status: http.StatusUnprocessableEntity, Code: "invalid", Message: "The request has invalid fields", Fields: f}
+}
+
+// Turn an error from loading something into a 404 when it wasn't there
+func apiLoadError(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return apiNotFound("%v", err)
+	}
+	return err
+}
+
+// The body of an error response
+type apiErrorResponse struct {
+	Error *ApiError
+}
+
+func writeApiJson(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if value != nil {
+		json.NewEncoder(w).Encode(value)
+	}
+}
+
+// Write the error as an error object.  Errors that aren't ApiErrors are
+// logged and reported as internal errors, without their text as it can have
+// SQL or other details in it.
+func writeApiError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*ApiError)
+	if !ok {
+		log.Printf("API error: %v", err)
+		apiErr = &ApiError{Status: http.StatusInternalServerError, Code: "internal", Message: http.StatusText(http.StatusInternalServerError)}
+	}
+	writeApiJson(w, apiErr.Status, apiErrorResponse{apiErr})
+}
+
+// Read a JSON request body into value.  Unknown fields are rejected so typos
+// don't silently do nothing.
+func decodeApiBody(r *http.Request, value interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return &ApiError{Status: http.StatusUnsupportedMediaType, Code: "unsupported_media_type", Message: "The request body must be application/json"}
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxApiBodyBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(value)
+	if err != nil {
+		return apiBadRequest("Invalid JSON: %v", err)
+	}
+	if decoder.More() {
+		return apiBadRequest("Invalid JSON: more than one value")
+	}
+	return nil
+}
+
+// Parameters matched from the path, by the name given in the route's pattern
+type apiParams map[string]string
+
+// An integer from the query string, or the default when it's missing
+func apiQueryInt(r *http.Request, name string, defaultValue int, minValue int, maxValue int) (int, error) {
+	text := r.URL.Query().Get(name)
+	if text == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < minValue || value > maxValue {
+		return 0, apiBadRequest("%s must be a number from %d to %d", name, minValue, maxValue)
+	}
+	return value, nil
+}
+
+func (p apiParams) int(name string) (int, error) {
+	value, err := strconv.Atoi(p[name])
+	if err != nil || value < 1 {
+		return 0, apiNotFound("Invalid %s: %s", name, p[name])
+	}
+	return value, nil
+}
+
+// Handles an API request, returning the status and the value to send as JSON.
+// A nil value sends no body.
+type apiHandler func(r *http.Request, params apiParams) (int, interface{}, error)
+
+// An API endpoint.  Pattern is the path after the collection, with {name}
+// for parameters, eg. "{id}" or "{id}/people/{personId}".  Request and
+// Response are zero values of what the body and the result decode into, for
+// the OpenAPI document, and Response is nil for routes that send no body.
+type apiRoute struct {
+	Method   string
+	Pattern  string
+	Role     Role
+	Handler  apiHandler
+	Summary  string
+	Query    []apiQueryParameter
+	Request  interface{}
+	Response interface{}
+}
+
+// A parameter a route reads from the query string
+type apiQueryParameter struct {
+	Name        string
+	Type        string // "string" or "integer"
+	Description string
+}
+
+// The routes under one path, eg. "people" for /api/v1/people
+type apiCollection struct {
+	Name        string
+	Description string
+	Routes      []apiRoute
+}
+
+// Status the route answers with when it works
+func (route *apiRoute) successStatus() int {
+	if route.Response == nil {
+		return http.StatusNoContent
+	}
+	if route.Method == "POST" {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+// Check a result against what the OpenAPI document says the route returns,
+// so the document can't quietly drift from the handlers
+func (route *apiRoute) checkResult(status int, value interface{}) error {
+	if status != route.successStatus() {
+		return fmt.Errorf("%s %s returned status %d, documented as %d", route.Method, route.Pattern, status, route.successStatus())
+	}
+	if route.Response == nil {
+		if value != nil {
+			return fmt.Errorf("%s %s returned a %T, documented as no body", route.Method, route.Pattern, value)
+		}
+		return nil
+	}
+	documented := reflect.TypeOf(route.Response)
+	returned := reflect.TypeOf(value)
+	for returned != nil && returned.Kind() == reflect.Ptr {
+		returned = returned.Elem()
+	}
+	if returned != documented {
+		return fmt.Errorf("%s %s returned a %v, documented as %v", route.Method, route.Pattern, returned, documented)
+	}
+	return nil
+}
+
+func (route *apiRoute) match(parts []string) (apiParams, bool) {
+	var patternParts []string
+	if route.Pattern != "" {
+		patternParts = strings.Split(route.Pattern, "/")
+	}
+	if len(patternParts) != len(parts) {
+		return nil, false
+	}
+	params := make(apiParams)
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			params[part[1:len(part)-1]] = parts[i]
+		} else if part != parts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Register the routes for a collection, eg. "people" for /api/v1/people and
+// everything under it.  Each route needs its own role, so viewers can read
+// what only editors can change.
+func handleApi(collection string, routes ...apiRoute) {
+	path := apiPrefix + collection
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var parts []string
+		if rest := strings.Trim(strings.TrimPrefix(r.URL.Path, path), "/"); rest != "" {
+			parts = strings.Split(rest, "/")
+		}
+
+		var allowed []string
+		for i := range routes {
+			route := &routes[i]
+			params, ok := route.match(parts)
+			if !ok {
+				continue
+			}
+			if route.Method != r.Method {
+				allowed = append(allowed, route.Method)
+				continue
+			}
+
+			user := CurrentUser(r)
+			if user == nil {
+				writeApiError(w, &ApiError{Status: http.StatusUnauthorized, Code: "unauthorized", Message: "Login required"})
+				return
+			}
+			if !user.Can(route.Role) {
+				writeApiError(w, &ApiError{Status: http.StatusForbidden, Code: "forbidden", Message: fmt.Sprintf("This needs the %s role", route.Role)})
+				return
+			}
+			status, value, err := route.Handler(r, params)
+			if err != nil {
+				writeApiError(w, err)
+				return
+			}
+			if err := route.checkResult(status, value); err != nil {
+				log.Printf("API result doesn't match the OpenAPI document: %v", err)
+			}
+			// Empty lists are sent as [] rather than null
+			if list := reflect.ValueOf(value); list.Kind() == reflect.Slice && list.IsNil() {
+				value = reflect.MakeSlice(list.Type(), 0, 0).Interface()
+			}
+			writeApiJson(w, status, value)
+			return
+		}
+
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			writeApiError(w, &ApiError{Status: http.StatusMethodNotAllowed, Code: "method_not_allowed", Message: fmt.Sprintf("%s isn't allowed here", r.Method)})
+			return
+		}
+		writeApiError(w, apiNotFound("No API endpoint at %s", r.URL.Path))
+	}
+	http.HandleFunc(path, handler)
+	http.HandleFunc(path+"/", handler)
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// What to call an API route with in TestApiRoutesMatchOpenApi
+type apiTestCall struct {
+	params apiParams
+	body   string
+}
+
+// Check a value decoded from JSON against a schema in the OpenAPI document,
+// returning the first thing that doesn't match
+func checkOpenApiValue(components map[string]interface{}, schema map[string]interface{}, value interface{}, path string) error {
+	if ref, found := schema["$ref"].(string); found {
+		schema = components[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+	}
+	if value == nil {
+		if schema["nullable"] != true {
+			return fmt.Errorf("%s is null", path)
+		}
+		return nil
+	}
+	if allOf, found := schema["allOf"].([]interface{}); found {
+		for _, item := range allOf {
+			err := checkOpenApiValue(components, item.(map[string]interface{}), value, path)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s is %v, not an object", path, value)
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		for name, item := range object {
+			itemSchema, found := properties[name].(map[string]interface{})
+			if !found {
+				itemSchema = additional
+			}
+			if itemSchema == nil {
+				return fmt.Errorf("%s.%s isn't in the document", path, name)
+			}
+			err := checkOpenApiValue(components, itemSchema, item, path+"."+name)
+			if err != nil {
+				return err
+			}
+		}
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s is %v, not an array", path, value)
+		}
+		for i, item := range list {
+			err := checkOpenApiValue(components, schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s is %v, not a string", path, value)
+		}
+		if schema["format"] == "date" {
+			if _, err := time.Parse("2006-01-02", text); err != nil {
+				return fmt.Errorf("%s is %q, not a date", path, text)
+			}
+		}
+		if enum, found := schema["enum"].([]interface{}); found {
+			for _, allowed := range enum {
+				if allowed == text {
+					return nil
+				}
+			}
+			return fmt.Errorf("%s is %q, not one of %v", path, text, enum)
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) {
+			return fmt.Errorf("%s is %v, not an integer", path, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s is %v, not a number", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s is %v, not a boolean", path, value)
+		}
+	default:
+		return fmt.Errorf("%s has a schema without a type: %v", path, schema)
+	}
+	return nil
+}
+
+// Round trip a value through JSON, so it's made of maps, lists and the like
+func decodeAsJson(t *testing.T, value interface{}) interface{} {
+	t.Helper()
+	text, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded interface{}
+	err = json.Unmarshal(text, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestApiRoutesMatchOpenApi(t *testing.T) {
+	// Panics when a route's request or response has a type without a schema
+	document := decodeAsJson(t, BuildOpenApiDocument()).(map[string]interface{})
+	components := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	paths := document["paths"].(map[string]interface{})
+
+	store = NewMemoryStore()
+	id := strconv.Itoa
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	must(store.InsertCountry(CountryData{Code: "ZZ", Name: "Testland", ContinentCode: "EU"}))
+	must(store.InsertCountry(CountryData{Code: "ZY", Name: "Deleteland", ContinentCode: "EU"}))
+	region, err := store.InsertRegion(RegionData{Code: "RG", Name: "Region", CountryCode: "ZZ"})
+	must(err)
+	oldRegion, err := store.InsertRegion(RegionData{Code: "OR", Name: "Old Region", CountryCode: "ZZ"})
+	must(err)
+	city, err := store.InsertCity("Testville", region.Id, 1.5, 2.5)
+	must(err)
+	oldCity, err := store.InsertCity("Oldville", region.Id, 1.5, 2.5)
+	must(err)
+	person := insertTestPerson(t, store, PersonData{FirstName: "Pat", LastName: "Tester", Gender: "U", HomeCityId: city.Id})
+	spouse := insertTestPerson(t, store, PersonData{FirstName: "Sam", LastName: "Tester", Gender: "F"})
+	single := insertTestPerson(t, store, PersonData{FirstName: "Alex", LastName: "Tester", Gender: "M"})
+	divorced := insertTestPerson(t, store, PersonData{FirstName: "Kim", LastName: "Tester", Gender: "F"})
+	removed := insertTestPerson(t, store, PersonData{FirstName: "Lee", LastName: "Tester", Gender: "M"})
+	must(store.InsertSpouse(person, spouse, 1, "2001-06-15"))
+	must(store.InsertSpouse(single, divorced, 3, ""))
+	nameId, err := store.InsertAlternateName(spouse, AlternateNameMaiden, "Smith")
+	must(err)
+	tag, err := store.InsertTag("cousins")
+	must(err)
+	oldTag, err := store.InsertTag("old")
+	must(err)
+	holiday, err := store.InsertHoliday(HolidayData{Name: "Picnic", Rule: HolidayRuleDates})
+	must(err)
+	must(store.InsertHolidayItem(holiday, time.Date(2020, 7, 4, 0, 0, 0, 0, time.UTC)))
+	oldHoliday, err := store.InsertHoliday(HolidayData{Name: "Old Day", Rule: HolidayRuleFixed, Month: 1, Day: 2})
+	must(err)
+
+	personJson := `{"FirstName": "Jo", "LastName": "Tester", "Gender": "U", "BirthDate": "1990-02-03", "MotherId": ` + id(spouse) + `}`
+	calls := map[string]apiTestCall{
+		"GET people":                        {},
+		"POST people":                       {body: personJson},
+		"GET people/search":                 {},
+		"GET people/{id}":                   {params: apiParams{"id": id(person)}},
+		"GET people/{id}/family":            {params: apiParams{"id": id(person)}},
+		"PUT people/{id}":                   {params: apiParams{"id": id(single)}, body: personJson},
+		"DELETE people/{id}":                {params: apiParams{"id": id(removed)}},
+		"GET people/{id}/names":             {params: apiParams{"id": id(spouse)}},
+		"POST people/{id}/names":            {params: apiParams{"id": id(person)}, body: `{"Kind": "alias", "Name": "Patch"}`},
+		"DELETE people/{id}/names/{nameId}": {params: apiParams{"id": id(spouse), "nameId": id(nameId)}},
+
+		"GET spouses":                            {},
+		"POST spouses":                           {body: `{"Person1Id": ` + id(single) + `, "Person2Id": ` + id(spouse) + `, "Status": 2}`},
+		"GET spouses/{person1Id}/{person2Id}":    {params: apiParams{"person1Id": id(spouse), "person2Id": id(person)}},
+		"PUT spouses/{person1Id}/{person2Id}":    {params: apiParams{"person1Id": id(person), "person2Id": id(spouse)}, body: `{"Status": 1, "MarriedDate": "2002-07-16"}`},
+		"DELETE spouses/{person1Id}/{person2Id}": {params: apiParams{"person1Id": id(single), "person2Id": id(divorced)}},
+
+		"GET cities":         {},
+		"POST cities":        {body: `{"Name": "Newville", "RegionId": ` + id(region.Id) + `}`},
+		"GET cities/{id}":    {params: apiParams{"id": id(city.Id)}},
+		"PUT cities/{id}":    {params: apiParams{"id": id(city.Id)}, body: `{"Name": "Testburg", "RegionId": ` + id(region.Id) + `, "Latitude": 3}`},
+		"DELETE cities/{id}": {params: apiParams{"id": id(oldCity.Id)}},
+
+		"GET regions":         {},
+		"POST regions":        {body: `{"Code": "NR", "Name": "New Region", "CountryCode": "ZZ"}`},
+		"GET regions/{id}":    {params: apiParams{"id": id(region.Id)}},
+		"PUT regions/{id}":    {params: apiParams{"id": id(region.Id)}, body: `{"Code": "RG", "Name": "Renamed", "CountryCode": "ZZ"}`},
+		"DELETE regions/{id}": {params: apiParams{"id": id(oldRegion.Id)}},
+
+		"GET countries":           {},
+		"POST countries":          {body: `{"Code": "ZX", "Name": "Newland", "ContinentCode": "AS"}`},
+		"GET countries/{code}":    {params: apiParams{"code": "ZZ"}},
+		"PUT countries/{code}":    {params: apiParams{"code": "ZZ"}, body: `{"Code": "ZZ", "Name": "Testland", "ContinentCode": "EU", "CapitalCityId": ` + id(city.Id) + `}`},
+		"DELETE countries/{code}": {params: apiParams{"code": "ZY"}},
+
+		"GET continents":           {},
+		"POST continents":          {body: `{"Code": "ZC", "Name": "Zealandia", "Color": "3366CC", "MapZoom": 3}`},
+		"GET continents/{code}":    {params: apiParams{"code": "EU"}},
+		"PUT continents/{code}":    {params: apiParams{"code": "EU"}, body: `{"Code": "EU", "Name": "Europe", "Color": "6495ED", "MapLatitude": 54, "MapLongitude": 15, "MapZoom": 3}`},
+		"DELETE continents/{code}": {params: apiParams{"code": "AN"}},
+
+		"GET tags":                           {},
+		"POST tags":                          {body: `{"Label": "friends"}`},
+		"GET tags/{id}":                      {params: apiParams{"id": id(tag.Id)}},
+		"PUT tags/{id}":                      {params: apiParams{"id": id(tag.Id)}, body: `{"Label": "cousins"}`},
+		"DELETE tags/{id}":                   {params: apiParams{"id": id(oldTag.Id)}},
+		"PUT tags/{id}/people/{personId}":    {params: apiParams{"id": id(tag.Id), "personId": id(person)}},
+		"DELETE tags/{id}/people/{personId}": {params: apiParams{"id": id(tag.Id), "personId": id(person)}},
+
+		"GET holidays":                      {},
+		"POST holidays":                     {body: `{"Name": "Harvest", "Rule": "fixed", "Month": 9, "Day": 22, "CountryCode": "ZZ"}`},
+		"GET holidays/{id}":                 {params: apiParams{"id": id(holiday)}},
+		"PUT holidays/{id}":                 {params: apiParams{"id": id(holiday)}, body: `{"Name": "Big Picnic", "Rule": "dates"}`},
+		"DELETE holidays/{id}":              {params: apiParams{"id": id(oldHoliday)}},
+		"PUT holidays/{id}/dates/{date}":    {params: apiParams{"id": id(holiday), "date": "2021-07-03"}},
+		"DELETE holidays/{id}/dates/{date}": {params: apiParams{"id": id(holiday), "date": "2020-07-04"}},
+	}
+
+	for _, collection := range apiCollections() {
+		for i := range collection.Routes {
+			route := &collection.Routes[i]
+			name := strings.TrimSuffix(route.Method+" "+collection.Name+"/"+route.Pattern, "/")
+			call, found := calls[name]
+			if !found {
+				t.Errorf("%s isn't called by this test", name)
+				continue
+			}
+			request := httptest.NewRequest(route.Method, apiPrefix+collection.Name, strings.NewReader(call.body))
+			if call.body != "" {
+				request.Header.Set("Content-Type", "application/json")
+			}
+			status, value, err := route.Handler(request, call.params)
+			if err != nil {
+				t.Errorf("%s failed: %v", name, err)
+				continue
+			}
+			err = route.checkResult(status, value)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			if value == nil {
+				continue
+			}
+
+			// The result as it's sent, against the document
+			path := apiPrefix + collection.Name
+			if route.Pattern != "" {
+				path += "/" + route.Pattern
+			}
+			operation := paths[path].(map[string]interface{})[strings.ToLower(route.Method)].(map[string]interface{})
+			success := operation["responses"].(map[string]interface{})[strconv.Itoa(status)].(map[string]interface{})
+			schema := success["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+			decoded := decodeAsJson(t, value)
+			// Empty lists are sent as [] rather than null
+			if decoded == nil && schema["type"] == "array" {
+				decoded = []interface{}{}
+			}
+			err = checkOpenApiValue(components, schema, decoded, name)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestApiPersonParents(t *testing.T) {
+	store = NewMemoryStore()
+	grandmother := insertTestPerson(t, store, PersonData{FirstName: "Gran", Gender: "F"})
+	mother := insertTestPerson(t, store, PersonData{FirstName: "Mum", Gender: "F", MotherId: grandmother})
+	child := insertTestPerson(t, store, PersonData{FirstName: "Kid", Gender: "U", MotherId: mother})
+	father := insertTestPerson(t, store, PersonData{FirstName: "Dad", Gender: "M"})
+	unknown := insertTestPerson(t, store, PersonData{FirstName: "Parent", Gender: "U"})
+
+	cases := []struct {
+		personId int
+		motherId int
+		fatherId int
+		field    string
+	}{
+		{grandmother, child, 0, "MotherId"},
+		{grandmother, mother, 0, "MotherId"},
+		{mother, mother, 0, "MotherId"},
+		{child, father, 0, "MotherId"},
+		{child, 0, mother, "FatherId"},
+		{child, 0, 999, "FatherId"},
+		{child, mother, father, ""},
+		{child, unknown, unknown, ""},
+		{father, grandmother, unknown, ""},
+	}
+	for _, c := range cases {
+		body := fmt.Sprintf(`{"FirstName": "Someone", "Gender": "U", "MotherId": %d, "FatherId": %d}`, c.motherId, c.fatherId)
+		request := httptest.NewRequest("PUT", apiPrefix+"people", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		_, _, err := apiPersonUpdate(request, apiParams{"id": strconv.Itoa(c.personId)})
+		apiErr, _ := err.(*ApiError)
+		switch {
+		case c.field == "" && err != nil:
+			t.Errorf("Person %d with mother %d and father %d gave %v", c.personId, c.motherId, c.fatherId, err)
+		case c.field != "" && (apiErr == nil || apiErr.Fields[c.field] == ""):
+			t.Errorf("Person %d with mother %d and father %d gave %v, expected a problem with %s", c.personId, c.motherId, c.fatherId, err, c.field)
+		}
+	}
+}
+
+func TestApiErrorsHideInternalDetails(t *testing.T) {
+	cases := []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{errors.New("Error 1054: Unknown column 'x' in 'SELECT x FROM people'"), http.StatusInternalServerError, "Internal Server Error"},
+		{apiNotFound("Person not found with id: 5"), http.StatusNotFound, "Person not found with id: 5"},
+		{apiFieldErrors{"FirstName": "is required"}.err(), http.StatusUnprocessableEntity, "The request has invalid fields"},
+	}
+	for _, c := range cases {
+		response := httptest.NewRecorder()
+		writeApiError(response, c.err)
+		var body apiErrorResponse
+		err := json.Unmarshal(response.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Code != c.status || body.Error == nil || body.Error.Message != c.message {
+			t.Errorf("%v gave %d: %s", c.err, response.Code, response.Body.String())
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// The assets and templates built into the binary, so one file is the whole
+// deployment
+//
+//go:embed assets tmpl
+var embeddedFiles embed.FS
+
+// Serves /assets/, set up at startup
+var assetServer *AssetServer
+
+// Where assets/ and tmpl/ are read from: the copies built into the binary,
+// or a directory when one is given
+func siteFiles(dir string) fs.FS {
+	if dir == "" {
+		return embeddedFiles
+	}
+	return os.DirFS(dir)
+}
+
+// Serves the files under assets/.  Each is hashed at startup, and pages link
+// to them with the hash in the url, so browsers can keep them for good and
+// still pick up a new version the moment it's deployed.  In dev mode files
+// are neither hashed nor cached, so edits show up on the next reload.
+type AssetServer struct {
+	hashes map[string]string
+	server http.Handler
+}
+
+func NewAssetServer(files fs.FS, dev bool) (*AssetServer, error) {
+	assets, err := fs.Sub(files, "assets")
+	if err != nil {
+		return nil, err
+	}
+	server := &AssetServer{
+		hashes: make(map[string]string),
+		server: http.FileServer(http.FS(assets)),
+	}
+	if dev {
+		return server, nil
+	}
+
+	err = fs.WalkDir(assets, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		contents, err := fs.ReadFile(assets, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(contents)
+		server.hashes[name] = hex.EncodeToString(sum[:])[:12]
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error hashing assets: %v", err)
+	}
+	return server, nil
+}
+
+// Url of an asset, like "css/main.css", with its hash when it has one
+func (a *AssetServer) Url(name string) string {
+	url := "/assets/" + name
+	if hash, found := a.hashes[name]; found {
+		url += "?v=" + hash
+	}
+	return url
+}
+
+// Serves a request with the /assets/ prefix stripped.  Anything not linked
+// with its current hash has to be checked with the server each time, as the
+// file can change under the same url.
+func (a *AssetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cacheControl := "no-cache"
+	if hash, found := a.hashes[strings.TrimPrefix(r.URL.Path, "/")]; found {
+		w.Header().Set("ETag", `"`+hash+`"`)
+		if r.URL.Query().Get("v") == hash {
+			cacheControl = "public, max-age=31536000, immutable"
+		}
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	a.server.ServeHTTP(w, r)
+}
+
+// Url of an asset, for templates
+func assetUrl(name string) string {
+	if assetServer == nil {
+		return "/assets/" + name
+	}
+	return assetServer.Url(name)
+}
@@ -3,11 +3,11 @@ function personTypeAhead(idField, nameField) {
     $('#' + nameField).typeahead({
         source: function(query, process) {
             $.get({
-                url: '/person/json/search?prefix=' + encodeURIComponent(query),
+                url: '/person/json/search?q=' + encodeURIComponent(query),
                 dataType: 'json',
                 success: function(data) {
                     var names = [];
-                    $.each(data, function(index, person) {
+                    $.each(data.People, function(index, person) {
                         names.push(person.Name);
                         personLookup[person.Name] = person.Id;
                     });
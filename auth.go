@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const sessionCookieName = "family_session"
+
+const sessionLifetime = 30 * 24 * time.Hour
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+const csrfContextKey contextKey = "csrf"
+
+// Where requests that change anything send their session's CSRF token
+const (
+	csrfFormField = "csrf_token"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+// Random token handed to the browser in the session cookie
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Sessions are stored by a hash of their token, so a leaked sessions table
+// can't be used to log in
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func isSecureRequest(r *http.Request) bool {
+	return config.secureCookies || r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// Token that has to come with every request that changes anything, so
+// another site can't make a logged in browser post to us.  Each session has
+// its own, worked out from the session token when the session starts, so
+// it's never stored and only pages of this site can know it.
+func csrfTokenForSession(sessionToken string) string {
+	sum := sha256.Sum256([]byte("csrf:" + sessionToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// The CSRF token of the request's session, or "" without one
+func CsrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfContextKey).(string)
+	return token
+}
+
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// Check a request that changes something came from one of our own pages:
+// its Origin or Referer, when the browser sends one, has to be this host,
+// and with a session it has to carry the session's CSRF token
+func checkCsrf(r *http.Request, csrfToken string) error {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	if source != "" {
+		sourceUrl, err := url.Parse(source)
+		if err != nil || sourceUrl.Host != r.Host {
+			return fmt.Errorf("Request came from another site: %s", source)
+		}
+	}
+	if csrfToken == "" {
+		return nil
+	}
+	sent := r.Header.Get(csrfHeader)
+	if sent == "" {
+		sent = r.FormValue(csrfFormField)
+	}
+	if subtle.ConstantTimeCompare([]byte(sent), []byte(csrfToken)) != 1 {
+		return fmt.Errorf("Missing or invalid CSRF token")
+	}
+	return nil
+}
+
+// Log the user in by creating a session and setting its cookie
+func StartSession(w http.ResponseWriter, r *http.Request, userId int) error {
+	token, err := newSessionToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(sessionLifetime)
+	err = store.InsertSession(hashSessionToken(token), userId, expiresAt)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Log the user out by deleting their session and clearing its cookie
+func EndSession(w http.ResponseWriter, r *http.Request) error {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		err = store.DeleteSession(hashSessionToken(cookie.Value))
+		if err != nil {
+			return err
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// The logged in user, or nil on public pages
+func CurrentUser(r *http.Request) *User {
+	user, _ := r.Context().Value(userContextKey).(*User)
+	return user
+}
+
+func loadSessionUser(r *http.Request) (*User, error) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil, nil
+	}
+	return store.LoadUserBySession(hashSessionToken(cookie.Value))
+}
+
+// Calendar feeds, which calendar apps fetch with a token instead of a session
+func isFeedPath(path string) bool {
+	return path == "/calendar.ics" || strings.HasPrefix(path, "/tag/ics/")
+}
+
+// Token for a user's calendar feed links.  Only its hash is stored, so making
+// a new one stops the old links working.
+func NewCalendarToken(userId int) (string, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return "", err
+	}
+	err = store.UpdateUserCalendarToken(userId, hashSessionToken(token))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func loadFeedUser(r *http.Request) (*User, error) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return nil, nil
+	}
+	return store.LoadUserByCalendarToken(hashSessionToken(token))
+}
+
+// Pages that can be reached without logging in
+func isPublicPath(path string) bool {
+	return path == "/login" || path == "/logout" || path == "/health" || path == "/health/live" || path == "/health/ready" || path == "/api/openapi.json" || strings.HasPrefix(path, "/assets/")
+}
+
+// Wrap a handler so every page except the public ones needs a logged in user.
+// Pages are redirected to the login form, JSON endpoints and calendar feeds
+// get a 401.  Calendar feeds can also log in with their token.  Every request
+// other than a GET, public or not, has to pass checkCsrf.
+func requireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(sessionCookieName); err == nil {
+			csrfToken := csrfTokenForSession(cookie.Value)
+			w.Header().Set(csrfHeader, csrfToken)
+			r = r.WithContext(context.WithValue(r.Context(), csrfContextKey, csrfToken))
+		}
+		if !isSafeMethod(r.Method) {
+			if err := checkCsrf(r, CsrfToken(r)); err != nil {
+				if strings.HasPrefix(r.URL.Path, apiPrefix) {
+					writeApiError(w, &ApiError{Status: http.StatusForbidden, Code: "csrf", Message: err.Error()})
+					return
+				}
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		if isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := loadSessionUser(r)
+		if err == nil && user == nil && isFeedPath(r.URL.Path) {
+			user, err = loadFeedUser(r)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error loading session: %v", err), 500)
+			return
+		}
+		if user == nil {
+			if strings.HasPrefix(r.URL.Path, apiPrefix) {
+				writeApiError(w, &ApiError{Status: http.StatusUnauthorized, Code: "unauthorized", Message: "Login required"})
+				return
+			}
+			if strings.Contains(r.URL.Path, "/json/") || isFeedPath(r.URL.Path) {
+				http.Error(w, "Login required", http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), 302)
+			return
+		}
+		next.ServeHTTP(w, withPersonLoader(r.WithContext(context.WithValue(r.Context(), userContextKey, user))))
+	})
+}
+
+// Wrap a route's handler so it only takes a POST, for actions like deleting
+// that a link or a page fetched from elsewhere shouldn't be able to do
+func requirePost(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "This needs a POST", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Wrap a route's handler so only users with at least the given role can use it.
+// Registering every route through this keeps the permissions in one place per
+// file, next to the paths they protect.
+func requireRole(role Role, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := CurrentUser(r)
+		if user == nil {
+			http.Error(w, "Login required", http.StatusUnauthorized)
+			return
+		}
+		if !user.Can(role) {
+			http.Error(w, fmt.Sprintf("This needs the %s role", role), http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
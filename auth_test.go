@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCsrfChecks(t *testing.T) {
+	handler := newTestServer(t)
+	cookie, csrfToken := loginTestUser(t, handler)
+
+	cases := []struct {
+		name   string
+		form   url.Values
+		header map[string]string
+		status int
+	}{
+		{"no token", url.Values{"first_name": {"Ann"}}, nil, http.StatusForbidden},
+		{"wrong token", url.Values{"first_name": {"Ann"}, csrfFormField: {"wrong"}}, nil, http.StatusForbidden},
+		{"form token", url.Values{"first_name": {"Ann"}, csrfFormField: {csrfToken}}, nil, http.StatusFound},
+		{"header token", url.Values{"first_name": {"Ann"}}, map[string]string{csrfHeader: csrfToken}, http.StatusFound},
+		{"other origin", url.Values{"first_name": {"Ann"}, csrfFormField: {csrfToken}}, map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"other referer", url.Values{"first_name": {"Ann"}, csrfFormField: {csrfToken}}, map[string]string{"Referer": "https://evil.example.com/page"}, http.StatusForbidden},
+		{"same origin", url.Values{"first_name": {"Ann"}, csrfFormField: {csrfToken}}, map[string]string{"Origin": "http://example.com"}, http.StatusFound},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest("POST", "/person/add", strings.NewReader(c.form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(cookie)
+		for name, value := range c.header {
+			request.Header.Set(name, value)
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code != c.status {
+			t.Errorf("%s: got %d, want %d: %s", c.name, response.Code, c.status, response.Body.String())
+		}
+	}
+}
+
+func TestCsrfApiAndLogout(t *testing.T) {
+	handler := newTestServer(t)
+	cookie, csrfToken := loginTestUser(t, handler)
+
+	request := httptest.NewRequest("POST", "/api/v1/tags", strings.NewReader(`{"Label": "Cousins"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.AddCookie(cookie)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden || !strings.Contains(response.Body.String(), `"csrf"`) {
+		t.Errorf("API write without a token gave %d: %s", response.Code, response.Body.String())
+	}
+
+	request = httptest.NewRequest("POST", "/logout", nil)
+	request.AddCookie(cookie)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Logging out without a token gave %d", response.Code)
+	}
+
+	request = httptest.NewRequest("POST", "/logout", nil)
+	request.AddCookie(cookie)
+	request.Header.Set(csrfHeader, csrfToken)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusFound {
+		t.Errorf("Logging out with a token gave %d", response.Code)
+	}
+}
+
+func TestDeleteNeedsPost(t *testing.T) {
+	handler := newTestServer(t)
+	cookie, csrfToken := loginTestUser(t, handler)
+	personId, err := store.InsertPerson(PersonData{FirstName: "Ann", Gender: "F", IsAlive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/person/delete/%d", personId)
+
+	request := httptest.NewRequest("GET", path, nil)
+	request.AddCookie(cookie)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Deleting with a GET gave %d", response.Code)
+	}
+	if _, err := store.LoadPersonById(personId); err != nil {
+		t.Errorf("Deleting with a GET deleted the person")
+	}
+
+	request = httptest.NewRequest("POST", path, nil)
+	request.AddCookie(cookie)
+	request.Header.Set(csrfHeader, csrfToken)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusFound {
+		t.Errorf("Deleting with a POST gave %d: %s", response.Code, response.Body.String())
+	}
+	if _, err := store.LoadPersonById(personId); err == nil {
+		t.Errorf("Deleting with a POST didn't delete the person")
+	}
+}
+
+// Routes that change things refuse GETs, which skip the CSRF check
+func TestChangesNeedPost(t *testing.T) {
+	handler := newTestServer(t)
+	cookie, _ := loginTestUser(t, handler)
+	personId, err := store.InsertPerson(PersonData{FirstName: "Ann", Gender: "F", IsAlive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	holidayId, err := store.InsertHoliday(HolidayData{Name: "Picnic", Rule: HolidayRuleDates})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		fmt.Sprintf("/tag/json/add?label=hack&person_id=%d", personId),
+		fmt.Sprintf("/holiday/date/add/%d?date=2024-07-04", holidayId),
+		fmt.Sprintf("/person/name/add/%d?kind=alias&name=Hack", personId),
+	} {
+		request := httptest.NewRequest("GET", path, nil)
+		request.AddCookie(cookie)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code != http.StatusMethodNotAllowed {
+			t.Errorf("GET %s gave %d", path, response.Code)
+		}
+	}
+	if tags, _ := store.LoadTagsForPerson(personId); len(tags) != 0 {
+		t.Errorf("A GET tagged the person with %v", tags)
+	}
+	if holiday, _ := store.LoadHolidayById(holidayId); holiday != nil && len(holiday.Dates) != 0 {
+		t.Errorf("A GET added holiday dates %v", holiday.Dates)
+	}
+	if names, _ := store.LoadAlternateNames(personId); len(names) != 0 {
+		t.Errorf("A GET added names %v", names)
+	}
+}
+
+func TestTagJsonAddNeedsPerson(t *testing.T) {
+	handler := newTestServer(t)
+	cookie, csrfToken := loginTestUser(t, handler)
+	personId, err := store.InsertPerson(PersonData{FirstName: "Ann", Gender: "F", IsAlive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		personId int
+		status   int
+	}{
+		{personId + 100, http.StatusNotFound},
+		{personId, http.StatusCreated},
+	} {
+		form := url.Values{"label": {"cousins"}, "person_id": {fmt.Sprint(c.personId)}}
+		request := httptest.NewRequest("POST", "/tag/json/add", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set(csrfHeader, csrfToken)
+		request.AddCookie(cookie)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code != c.status {
+			t.Errorf("Tagging person %d gave %d, want %d: %s", c.personId, response.Code, c.status, response.Body.String())
+		}
+	}
+	if _, err := store.LoadTagByLabel("cousins"); err != nil {
+		t.Errorf("Tag wasn't made: %v", err)
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"bytes"
-	"database/sql"
 	"fmt"
 	"html/template"
 	"log"
@@ -11,22 +10,137 @@ import (
 )
 
 type PeopleCalendar struct {
+	Year   int
 	Months []CalendarMonth
 }
 
 type CalendarMonth struct {
 	Name   string
+	Year   int
+	Month  time.Month
 	Events []CalendarEvent
 }
 
+// A day in a month grid
+type CalendarDay struct {
+	Date    time.Time
+	InMonth bool
+	IsToday bool
+	Events  []CalendarEvent
+}
+
+// The month's days a week at a time, Sunday to Saturday, with the first and
+// last weeks filled out by days from the months either side
+func (m *CalendarMonth) Weeks() [][]CalendarDay {
+	first := time.Date(m.Year, m.Month, 1, 0, 0, 0, 0, time.UTC)
+	day := first.AddDate(0, 0, -int(first.Weekday()))
+	today := time.Now().Format("2006-01-02")
+
+	eventsByDay := make(map[int][]CalendarEvent)
+	for _, event := range m.Events {
+		eventsByDay[event.Date.Day()] = append(eventsByDay[event.Date.Day()], event)
+	}
+
+	var weeks [][]CalendarDay
+	for len(weeks) == 0 || day.Month() == m.Month {
+		week := make([]CalendarDay, 7)
+		for i := range week {
+			week[i] = CalendarDay{
+				Date:    day,
+				InMonth: day.Month() == m.Month,
+				IsToday: day.Format("2006-01-02") == today,
+			}
+			if week[i].InMonth {
+				week[i].Events = eventsByDay[day.Day()]
+			}
+			day = day.AddDate(0, 0, 1)
+		}
+		weeks = append(weeks, week)
+	}
+	return weeks
+}
+
 type CalendarEvent struct {
 	Date    time.Time
 	Type    string
 	Caption template.HTML
+	// The age being reached, anniversary number or years since a death, when known
+	Years int
+	// Whether Years is based on an estimated birth year
+	YearsIsGuess bool
+	// Whether Years is a round number worth planning for
+	Milestone bool
+}
+
+// Describe the years, eg. "turns 40" or "25th anniversary (silver)"
+func (e CalendarEvent) Detail() string {
+	if e.Years <= 0 {
+		return ""
+	}
+	switch e.Type {
+	case "Birthday":
+		if e.YearsIsGuess {
+			return fmt.Sprintf("turns about %d", e.Years)
+		}
+		return fmt.Sprintf("turns %d", e.Years)
+	case "Anniversary":
+		detail := ordinalNumber(e.Years) + " anniversary"
+		if name, ok := anniversaryNames[e.Years]; ok {
+			detail += " (" + name + ")"
+		}
+		return detail
+	case "Memorial":
+		if e.Years == 1 {
+			return "1 year"
+		}
+		return fmt.Sprintf("%d years", e.Years)
+	}
+	return ""
+}
+
+// Traditional names of the bigger wedding anniversaries
+var anniversaryNames = map[int]string{
+	25: "silver",
+	40: "ruby",
+	50: "gold",
+	60: "diamond",
+	70: "platinum",
+}
+
+func isBirthdayMilestone(age int) bool {
+	return age == 1 || age == 18 || age == 21 || (age >= 30 && age%10 == 0)
+}
+
+func isAnniversaryMilestone(years int) bool {
+	_, named := anniversaryNames[years]
+	return years == 1 || years%10 == 0 || named
+}
+
+// Format a number as 1st, 2nd, 3rd, 11th, 21st, etc.
+func ordinalNumber(value int) string {
+	suffix := "th"
+	switch value % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	if value%100 >= 11 && value%100 <= 13 {
+		suffix = "th"
+	}
+	return fmt.Sprintf("%d%s", value, suffix)
 }
 
 type CalendarPerson struct {
-	BirthDate time.Time
+	BirthDate        time.Time
+	IsBirthYearGuess bool
+	Person           PersonLite
+}
+
+type CalendarMemorial struct {
+	DeathDate time.Time
 	Person    PersonLite
 }
 
@@ -36,87 +150,205 @@ type CalendarAnniversary struct {
 	Person2     PersonLite
 }
 
-func LoadPeopleCalendar(db *sql.DB) (*PeopleCalendar, error) {
-	defer trace(traceName("LoadPeopleCalendar"))
+func newBirthdayEvent(person CalendarPerson, date time.Time, caption template.HTML) CalendarEvent {
+	event := CalendarEvent{
+		Date:         date,
+		Type:         "Birthday",
+		Caption:      caption,
+		Years:        date.Year() - person.BirthDate.Year(),
+		YearsIsGuess: person.IsBirthYearGuess,
+	}
+	// An estimated age isn't worth planning a party around
+	event.Milestone = !event.YearsIsGuess && isBirthdayMilestone(event.Years)
+	return event
+}
+
+func newAnniversaryEvent(anniversary CalendarAnniversary, date time.Time, caption template.HTML) CalendarEvent {
+	event := CalendarEvent{
+		Date:    date,
+		Type:    "Anniversary",
+		Caption: caption,
+		Years:   date.Year() - anniversary.MarriedDate.Year(),
+	}
+	event.Milestone = isAnniversaryMilestone(event.Years)
+	return event
+}
 
-	personLookup, err := loadPeopleByBirthMonth(db)
+func newMemorialEvent(memorial CalendarMemorial, date time.Time, caption template.HTML) CalendarEvent {
+	return CalendarEvent{
+		Date:    date,
+		Type:    "Memorial",
+		Caption: caption,
+		Years:   date.Year() - memorial.DeathDate.Year(),
+	}
+}
+
+// Load the birthdays, anniversaries and holidays for each month of the year,
+// along with the anniversaries of deaths when includeMemorials is set.  Ages
+// and anniversary numbers are the ones reached that year, and events from
+// before someone was born or married are left out.  When countryCode isn't
+// empty only the holidays for everyone and that country are included.
+func LoadPeopleCalendar(store Store, year int, includeMemorials bool, countryCode string) (*PeopleCalendar, error) {
+	defer trace(traceName(fmt.Sprintf("LoadPeopleCalendar(%d, %v, %s)", year, includeMemorials, countryCode)))
+
+	personLookup, err := loadPeopleByBirthMonth(store)
 	if err != nil {
 		return nil, err
 	}
-	anniversaryLookup, err := loadAnniversariesByMonth(db)
+	anniversaryLookup, err := loadAnniversariesByMonth(store)
 	if err != nil {
 		return nil, err
 	}
 
-	holidayList, err := LoadHolidaysByYear(db, time.Now().Year())
+	memorialLookup := make([][]CalendarMemorial, 12)
+	if includeMemorials {
+		memorialLookup, err = loadMemorialsByMonth(store)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = ensureHolidayItems(store, year, year)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := store.LoadHolidaysInRange(
+		time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC))
 	if err != nil {
 		return nil, err
 	}
+	holidays = filterHolidaysByCountry(holidays, countryCode)
 
 	calendar := PeopleCalendar{
+		Year:   year,
 		Months: make([]CalendarMonth, 12),
 	}
 
 	personTemplate := template.Must(template.New("person").Parse("<a href=\"/person/view/{{.Person.Id}}\">{{.Person.Name}}</a>"))
+	memorialTemplate := template.Must(template.New("memorial").Parse(
+		"<a href=\"/person/view/{{.Person.Id}}\">{{.Person.Name}}</a> ({{.DeathDate.Year}})"))
 	anniversaryTemplate := template.Must(template.New("anniversary").Parse(
 		"<a href=\"/person/view/{{.Person1.Id}}\">{{.Person1.Name}}</a> &amp;" +
 			" <a href=\"/person/view/{{.Person2.Id}}\">{{.Person2.Name}}</a>"))
 
 	for i := 0; i < 12; i++ {
 		calendar.Months[i] = CalendarMonth{
-			Name: time.Month(i + 1).String(),
+			Name:  time.Month(i + 1).String(),
+			Year:  year,
+			Month: time.Month(i + 1),
 		}
 
 		events := make([]CalendarEvent, 0)
 
-		for _, value := range holidayList[0].Holidays {
+		for _, value := range holidays {
 			if int(value.Date.Month()) == i+1 {
 				event := CalendarEvent{
 					Date:    value.Date,
 					Type:    "Holiday",
-					Caption: template.HTML(value.Name),
+					Caption: template.HTML(template.HTMLEscapeString(value.Name)),
 				}
 				events = append(events, event)
 			}
 		}
 
 		for _, value := range (*personLookup)[i] {
+			if value.BirthDate.Year() > year {
+				continue
+			}
 			var buf bytes.Buffer
 			personTemplate.Execute(&buf, value)
-			event := CalendarEvent{
-				Date:    value.BirthDate,
-				Type:    "Birthday",
-				Caption: template.HTML(buf.String()),
-			}
-			events = append(events, event)
+			date := occurrenceInYear(value.BirthDate, year, time.UTC)
+			events = append(events, newBirthdayEvent(value, date, template.HTML(buf.String())))
 		}
 
 		for _, value := range (*anniversaryLookup)[i] {
+			if value.MarriedDate.Year() > year {
+				continue
+			}
 			var buf bytes.Buffer
 			anniversaryTemplate.Execute(&buf, value)
-			event := CalendarEvent{
-				Date:    value.MarriedDate,
-				Type:    "Anniversary",
-				Caption: template.HTML(buf.String()),
+			date := occurrenceInYear(value.MarriedDate, year, time.UTC)
+			events = append(events, newAnniversaryEvent(value, date, template.HTML(buf.String())))
+		}
+		for _, value := range memorialLookup[i] {
+			if value.DeathDate.Year() > year {
+				continue
 			}
-			events = append(events, event)
+			var buf bytes.Buffer
+			memorialTemplate.Execute(&buf, value)
+			date := occurrenceInYear(value.DeathDate, year, time.UTC)
+			events = append(events, newMemorialEvent(value, date, template.HTML(buf.String())))
 		}
-		sort.Slice(events, func(i, j int) bool { return events[i].Date.Day() < events[j].Date.Day() })
+		sort.SliceStable(events, func(i, j int) bool { return events[i].Date.Day() < events[j].Date.Day() })
 		calendar.Months[i].Events = events
 	}
 
 	return &calendar, nil
 }
 
-func loadPeopleByBirthMonth(db *sql.DB) (*[][]CalendarPerson, error) {
+func loadPeopleByBirthMonth(store Store) (*[][]CalendarPerson, error) {
 	defer trace(traceName("LoadPeopleByBirthMonth"))
 	monthLookup := make([][]CalendarPerson, 12)
 	for i := 0; i < 12; i++ {
 		monthLookup[i] = make([]CalendarPerson, 0, 0)
 	}
 
-	rows, err := db.Query(
-		"SELECT id, first_name, middle_name, last_name, nick_name, gender, birth_date" +
+	people, err := store.LoadBirthdays()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range people {
+		monthIndex := item.BirthDate.Month() - 1
+		monthLookup[monthIndex] = append(monthLookup[monthIndex], item)
+	}
+
+	log.Printf("Birthdays found: %d", len(people))
+	return &monthLookup, nil
+}
+
+func loadAnniversariesByMonth(store Store) (*[][]CalendarAnniversary, error) {
+	defer trace(traceName("LoadAnniversariesByMonth"))
+	monthLookup := make([][]CalendarAnniversary, 12)
+	for i := 0; i < 12; i++ {
+		monthLookup[i] = make([]CalendarAnniversary, 0, 0)
+	}
+
+	anniversaries, err := store.LoadAnniversaries()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range anniversaries {
+		monthIndex := item.MarriedDate.Month() - 1
+		monthLookup[monthIndex] = append(monthLookup[monthIndex], item)
+	}
+
+	log.Printf("Anniversaries found: %d", len(anniversaries))
+	return &monthLookup, nil
+}
+
+func loadMemorialsByMonth(store Store) ([][]CalendarMemorial, error) {
+	defer trace(traceName("LoadMemorialsByMonth"))
+	monthLookup := make([][]CalendarMemorial, 12)
+
+	memorials, err := store.LoadMemorials()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range memorials {
+		monthIndex := item.DeathDate.Month() - 1
+		monthLookup[monthIndex] = append(monthLookup[monthIndex], item)
+	}
+
+	log.Printf("Memorials found: %d", len(memorials))
+	return monthLookup, nil
+}
+
+// Load the living people with a known birth date, ordered by month and day
+func (s *MySqlStore) LoadBirthdays() ([]CalendarPerson, error) {
+	defer trace(traceName("LoadBirthdays"))
+	rows, err := s.db.Query(
+		"SELECT id, first_name, middle_name, last_name, nick_name, gender, birth_date, is_birth_year_guess" +
 			" FROM people" +
 			" WHERE is_alive = 1 AND birth_date IS NOT NULL" +
 			" ORDER BY MONTH(birth_date), DAY(birth_date)")
@@ -125,11 +357,12 @@ func loadPeopleByBirthMonth(db *sql.DB) (*[][]CalendarPerson, error) {
 	}
 	defer rows.Close()
 
-	count := 0
+	var people []CalendarPerson
 	for rows.Next() {
 		var id int
 		var birthDateString, firstName, middleName, lastName, nickName, gender string
-		rows.Scan(&id, &firstName, &middleName, &lastName, &nickName, &gender, &birthDateString)
+		var isBirthYearGuess bool
+		rows.Scan(&id, &firstName, &middleName, &lastName, &nickName, &gender, &birthDateString, &isBirthYearGuess)
 		birthDate, err := time.Parse("2006-01-02", birthDateString)
 		if err != nil {
 			fmt.Printf("Error parsing birthdate; person id: %d, birthdate: %s\n", id, birthDateString)
@@ -137,31 +370,59 @@ func loadPeopleByBirthMonth(db *sql.DB) (*[][]CalendarPerson, error) {
 		}
 
 		item := CalendarPerson{
-			BirthDate: birthDate,
+			BirthDate:        birthDate,
+			IsBirthYearGuess: isBirthYearGuess,
 			Person: PersonLite{
 				Id:     id,
 				Name:   BuildFullName(firstName, middleName, lastName, nickName),
 				Gender: GetGenderName(gender),
 			},
 		}
+		people = append(people, item)
+	}
+	return people, nil
+}
 
-		monthIndex := birthDate.Month() - 1
-		monthLookup[monthIndex] = append(monthLookup[monthIndex], item)
-		count++
+// Load the people who have died with a known death date, ordered by month and day
+func (s *MySqlStore) LoadMemorials() ([]CalendarMemorial, error) {
+	defer trace(traceName("LoadMemorials"))
+	rows, err := s.db.Query(
+		"SELECT id, first_name, middle_name, last_name, nick_name, gender, death_date" +
+			" FROM people" +
+			" WHERE is_alive = 0 AND death_date IS NOT NULL" +
+			" ORDER BY MONTH(death_date), DAY(death_date)")
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	log.Printf("Birthdays found: %d", count)
-	return &monthLookup, nil
-}
+	var memorials []CalendarMemorial
+	for rows.Next() {
+		var id int
+		var deathDateString, firstName, middleName, lastName, nickName, gender string
+		rows.Scan(&id, &firstName, &middleName, &lastName, &nickName, &gender, &deathDateString)
+		deathDate, err := time.Parse("2006-01-02", deathDateString)
+		if err != nil {
+			return nil, err
+		}
 
-func loadAnniversariesByMonth(db *sql.DB) (*[][]CalendarAnniversary, error) {
-	defer trace(traceName("LoadAnniversariesByMonth"))
-	monthLookup := make([][]CalendarAnniversary, 12)
-	for i := 0; i < 12; i++ {
-		monthLookup[i] = make([]CalendarAnniversary, 0, 0)
+		item := CalendarMemorial{
+			DeathDate: deathDate,
+			Person: PersonLite{
+				Id:     id,
+				Name:   BuildFullName(firstName, middleName, lastName, nickName),
+				Gender: GetGenderName(gender),
+			},
+		}
+		memorials = append(memorials, item)
 	}
+	return memorials, nil
+}
 
-	rows, err := db.Query(
+// Load the married couples with a known married date, ordered by month and day
+func (s *MySqlStore) LoadAnniversaries() ([]CalendarAnniversary, error) {
+	defer trace(traceName("LoadAnniversaries"))
+	rows, err := s.db.Query(
 		"SELECT s.married_date, " +
 			"  p1.id, p1.first_name, p1.middle_name, p1.last_name, p1.nick_name, p1.gender, " +
 			"  p2.id, p2.first_name, p2.middle_name, p2.last_name, p2.nick_name, p2.gender " +
@@ -175,7 +436,7 @@ func loadAnniversariesByMonth(db *sql.DB) (*[][]CalendarAnniversary, error) {
 	}
 	defer rows.Close()
 
-	count := 0
+	var anniversaries []CalendarAnniversary
 	for rows.Next() {
 		var marriedDateString string
 		var p1Id, p2Id int
@@ -203,12 +464,7 @@ func loadAnniversariesByMonth(db *sql.DB) (*[][]CalendarAnniversary, error) {
 				Gender: GetGenderName(p2Gender),
 			},
 		}
-
-		monthIndex := marriedDate.Month() - 1
-		monthLookup[monthIndex] = append(monthLookup[monthIndex], item)
-		count++
+		anniversaries = append(anniversaries, item)
 	}
-
-	log.Printf("Anniversaries found: %d", count)
-	return &monthLookup, nil
+	return anniversaries, nil
 }
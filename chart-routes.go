@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type chartPage struct {
+	Depth    int
+	MaxDepth int
+	Root     interface{}
+}
+
+func personAncestors(w http.ResponseWriter, r *http.Request) {
+	personId, err := getIntPathParam(r, "personId", 3)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse person id: %v", err), 400)
+		return
+	}
+	depth, _ := strconv.Atoi(r.FormValue("depth"))
+	depth = chartDepth(depth, 4)
+
+	root, err := LoadAncestorTree(store, personId, depth)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading ancestors: %v", err), 500)
+		return
+	}
+
+	data := chartPage{Depth: depth, MaxDepth: maxChartDepth, Root: root}
+	renderTemplate(w, r, "person/ancestors.html", data)
+}
+
+func personDescendants(w http.ResponseWriter, r *http.Request) {
+	personId, err := getIntPathParam(r, "personId", 3)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse person id: %v", err), 400)
+		return
+	}
+	depth, _ := strconv.Atoi(r.FormValue("depth"))
+	depth = chartDepth(depth, 3)
+
+	root, err := LoadDescendantTree(store, personId, depth)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading descendants: %v", err), 500)
+		return
+	}
+
+	data := chartPage{Depth: depth, MaxDepth: maxChartDepth, Root: root}
+	renderTemplate(w, r, "person/descendants.html", data)
+}
+
+func personJsonAncestors(w http.ResponseWriter, r *http.Request) {
+	personId, err := getIntPathParam(r, "personId", 4)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse person id: %v", err), 400)
+		return
+	}
+	depth, _ := strconv.Atoi(r.FormValue("depth"))
+
+	root, err := LoadAncestorTree(store, personId, chartDepth(depth, 4))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading ancestors: %v", err), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(root)
+}
+
+func personJsonDescendants(w http.ResponseWriter, r *http.Request) {
+	personId, err := getIntPathParam(r, "personId", 4)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse person id: %v", err), 400)
+		return
+	}
+	depth, _ := strconv.Atoi(r.FormValue("depth"))
+
+	root, err := LoadDescendantTree(store, personId, chartDepth(depth, 3))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading descendants: %v", err), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(root)
+}
+
+func addChartRoutes() {
+	http.HandleFunc("/person/ancestors/", requireRole(RoleViewer, personAncestors))
+	http.HandleFunc("/person/descendants/", requireRole(RoleViewer, personDescendants))
+	http.HandleFunc("/person/json/ancestors/", requireRole(RoleViewer, personJsonAncestors))
+	http.HandleFunc("/person/json/descendants/", requireRole(RoleViewer, personJsonDescendants))
+}
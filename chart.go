@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Charts are never walked further than this many generations
+const maxChartDepth = 10
+
+type AncestorNode struct {
+	Person     PersonLite
+	Generation int
+	Mother     *AncestorNode
+	Father     *AncestorNode
+}
+
+type DescendantNode struct {
+	Person     PersonLite
+	Generation int
+	// Relationships of the person, with the person as Person1
+	Spouses  []SpouseLite
+	Children []*DescendantNode
+}
+
+// Clamp a requested chart depth, falling back to defaultDepth when it's missing
+func chartDepth(depth int, defaultDepth int) int {
+	if depth <= 0 {
+		return defaultDepth
+	}
+	if depth > maxChartDepth {
+		return maxChartDepth
+	}
+	return depth
+}
+
+// Load the pedigree of a person, walking up the mother and father links for
+// the given number of generations.  Each generation takes a single query.
+func LoadAncestorTree(store PersonLiteLoader, personId int, depth int) (*AncestorNode, error) {
+	defer trace(traceName(fmt.Sprintf("LoadAncestorTree(%d, %d)", personId, depth)))
+
+	person, err := store.LoadPersonLiteById(personId)
+	if err != nil {
+		return nil, err
+	}
+	root := &AncestorNode{Person: *person}
+
+	generation := []*AncestorNode{root}
+	for level := 1; level <= depth && len(generation) > 0; level++ {
+		var parentIds []int
+		for _, node := range generation {
+			if node.Person.MotherId > 0 {
+				parentIds = append(parentIds, node.Person.MotherId)
+			}
+			if node.Person.FatherId > 0 {
+				parentIds = append(parentIds, node.Person.FatherId)
+			}
+		}
+		parents, err := store.LoadPersonLiteListByIds(uniqueInts(parentIds))
+		if err != nil {
+			return nil, err
+		}
+		lookup := make(map[int]PersonLite)
+		for _, parent := range parents {
+			lookup[parent.Id] = parent
+		}
+
+		var next []*AncestorNode
+		for _, node := range generation {
+			if mother, found := lookup[node.Person.MotherId]; found {
+				node.Mother = &AncestorNode{Person: mother, Generation: level}
+				next = append(next, node.Mother)
+			}
+			if father, found := lookup[node.Person.FatherId]; found {
+				node.Father = &AncestorNode{Person: father, Generation: level}
+				next = append(next, node.Father)
+			}
+		}
+		generation = next
+	}
+	return root, nil
+}
+
+// Load the descendants of a person and their spouses for the given number of
+// generations.  Each generation takes two queries, one for the spouses and one
+// for the children.
+func LoadDescendantTree(store Store, personId int, depth int) (*DescendantNode, error) {
+	defer trace(traceName(fmt.Sprintf("LoadDescendantTree(%d, %d)", personId, depth)))
+
+	person, err := store.LoadPersonLiteById(personId)
+	if err != nil {
+		return nil, err
+	}
+	root := &DescendantNode{Person: *person}
+
+	// Someone can only appear once, which also stops bad data from looping
+	seen := map[int]bool{root.Person.Id: true}
+	generation := []*DescendantNode{root}
+	for level := 0; len(generation) > 0; level++ {
+		var ids []int
+		lookup := make(map[int]*DescendantNode)
+		for _, node := range generation {
+			ids = append(ids, node.Person.Id)
+			lookup[node.Person.Id] = node
+		}
+
+		spouses, err := store.LoadSpousesByPersonIds(ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, spouse := range spouses {
+			if node, found := lookup[spouse.Person1.Id]; found {
+				node.Spouses = append(node.Spouses, spouse)
+			}
+			if node, found := lookup[spouse.Person2.Id]; found {
+				spouse.Person1, spouse.Person2 = spouse.Person2, spouse.Person1
+				node.Spouses = append(node.Spouses, spouse)
+			}
+		}
+
+		if level == depth {
+			break
+		}
+		children, err := store.LoadChildrenPersonLiteByParentIds(ids)
+		if err != nil {
+			return nil, err
+		}
+		var next []*DescendantNode
+		for _, child := range children {
+			if seen[child.Id] {
+				continue
+			}
+			parent, found := lookup[child.MotherId]
+			if !found {
+				parent = lookup[child.FatherId]
+			}
+			seen[child.Id] = true
+			node := &DescendantNode{Person: child, Generation: level + 1}
+			parent.Children = append(parent.Children, node)
+			next = append(next, node)
+		}
+		generation = next
+	}
+	return root, nil
+}
+
+func uniqueInts(values []int) []int {
+	var unique []int
+	seen := make(map[int]bool)
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
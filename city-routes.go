@@ -3,17 +3,13 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"net/http"
 	"strconv"
 	"strings"
 )
 
 func cityList(w http.ResponseWriter, r *http.Request) {
-	err := template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/city/list.html")).Execute(w, "data")
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "city/list.html", "data")
 }
 
 func cityView(w http.ResponseWriter, r *http.Request) {
@@ -23,18 +19,18 @@ func cityView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	city, err := LoadCityById(db, cityId)
+	city, err := store.LoadCityById(cityId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading city: %v", err), 500)
 		return
 	}
-	region, err := LoadRegionById(db, city.RegionId)
+	region, err := store.LoadRegionById(city.RegionId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading region: %v", err), 500)
 		return
 	}
 
-	personList, err := LoadPersonLiteListByHomeCityId(db, cityId)
+	personList, err := store.LoadPersonLiteListByHomeCityId(cityId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading people: %v", err), 500)
 		return
@@ -50,10 +46,7 @@ func cityView(w http.ResponseWriter, r *http.Request) {
 		personList,
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/city/view.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "city/view.html", data)
 }
 
 func cityEdit(w http.ResponseWriter, r *http.Request) {
@@ -76,7 +69,7 @@ func cityEdit(w http.ResponseWriter, r *http.Request) {
 		}
 		lat, _ := strconv.ParseFloat(r.FormValue("lat"), 32)
 		lng, _ := strconv.ParseFloat(r.FormValue("lng"), 32)
-		err = UpdateCity(db, cityId, cityName, regionId, float32(lat), float32(lng))
+		err = store.UpdateCity(cityId, cityName, regionId, float32(lat), float32(lng))
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error updating city: %v", err), 500)
 			return
@@ -84,16 +77,13 @@ func cityEdit(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, fmt.Sprintf("/region/view/%d", regionId), 302)
 	}
 
-	city, err := LoadCityById(db, cityId)
+	city, err := store.LoadCityById(cityId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading city by id: %v", err), 400)
 		return
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/city/edit.html")).Execute(w, city)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "city/edit.html", city)
 }
 
 func cityAdd(w http.ResponseWriter, r *http.Request) {
@@ -106,7 +96,7 @@ func cityAdd(w http.ResponseWriter, r *http.Request) {
 		}
 		lat, err := strconv.ParseFloat(r.FormValue("lat"), 32)
 		lng, err := strconv.ParseFloat(r.FormValue("lng"), 32)
-		_, err = InsertCity(db, cityName, regionId, float32(lat), float32(lng))
+		_, err = store.InsertCity(cityName, regionId, float32(lat), float32(lng))
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error creating city: %v", err), 500)
 			return
@@ -114,10 +104,7 @@ func cityAdd(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, fmt.Sprintf("/region/view/%d", regionId), 302)
 	}
 
-	err := template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/city/add.html")).Execute(w, nil)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "city/add.html", nil)
 }
 
 func cityJsonSearch(w http.ResponseWriter, r *http.Request) {
@@ -126,7 +113,7 @@ func cityJsonSearch(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		offset = 0
 	}
-	cities, err := LoadCitiesByPrefix(db, prefix, offset)
+	cities, err := store.LoadCitiesByPrefix(prefix, offset)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading cities: %v", err), 500)
 		return
@@ -142,7 +129,7 @@ func cityDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = DeleteCity(db, cityId)
+	err = store.DeleteCity(cityId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error delting city (id: %d): %v", cityId, err), 500)
 		return
@@ -152,10 +139,10 @@ func cityDelete(w http.ResponseWriter, r *http.Request) {
 }
 
 func addCityRoutes() {
-	http.HandleFunc("/city/list/", cityList)
-	http.HandleFunc("/city/view/", cityView)
-	http.HandleFunc("/city/edit/", cityEdit)
-	http.HandleFunc("/city/json/search", cityJsonSearch)
-	http.HandleFunc("/city/add", cityAdd)
-	http.HandleFunc("/city/delete/", cityDelete)
+	http.HandleFunc("/city/list/", requireRole(RoleViewer, cityList))
+	http.HandleFunc("/city/view/", requireRole(RoleViewer, cityView))
+	http.HandleFunc("/city/edit/", requireRole(RoleEditor, cityEdit))
+	http.HandleFunc("/city/json/search", requireRole(RoleViewer, cityJsonSearch))
+	http.HandleFunc("/city/add", requireRole(RoleEditor, cityAdd))
+	http.HandleFunc("/city/delete/", requireRole(RoleAdmin, requirePost(cityDelete)))
 }
@@ -71,9 +71,9 @@ func readCityFromRows(rows *sql.Rows) (*CityLite, error) {
 	return &city, nil
 }
 
-func LoadCityById(db *sql.DB, id int) (*CityLite, error) {
+func (s *MySqlStore) LoadCityById(id int) (*CityLite, error) {
 	defer trace(traceName(fmt.Sprintf("LoadCityById(%d)", id)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT city_id, city_name, region_id, region_code, country_code, lat, lng"+
 			" FROM city_view "+
 			" WHERE city_id=?", id)
@@ -82,7 +82,7 @@ func LoadCityById(db *sql.DB, id int) (*CityLite, error) {
 	}
 	defer rows.Close()
 	if !rows.Next() {
-		return nil, fmt.Errorf("Error, city not found (id: %d)", id)
+		return nil, notFoundf("Error, city not found (id: %d)", id)
 	}
 
 	city, err := readCityFromRows(rows)
@@ -92,9 +92,43 @@ func LoadCityById(db *sql.DB, id int) (*CityLite, error) {
 	return city, nil
 }
 
-func LoadCitiesByRegionId(db *sql.DB, regionId int) ([]CityLite, error) {
+// Load the cities with the given ids that exist, in a single query
+func (s *MySqlStore) LoadCitiesByIds(ids []int) ([]CityLite, error) {
+	defer trace(traceName(fmt.Sprintf("LoadCitiesByIds(%v)", ids)))
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.Query(
+		"SELECT city_id, city_name, region_id, region_code, country_code, lat, lng"+
+			" FROM city_view"+
+			" WHERE city_id IN ("+sqlPlaceholders(len(ids))+")"+
+			" ORDER BY city_id",
+		intArgs(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return readCityListFromRows(rows)
+}
+
+func (s *MySqlStore) LoadCityList() ([]CityLite, error) {
+	defer trace(traceName("LoadCityList"))
+	rows, err := s.db.Query(
+		"SELECT city_id, city_name, region_id, region_code, country_code, lat, lng" +
+			" FROM city_view" +
+			" ORDER BY city_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return readCityListFromRows(rows)
+}
+
+func (s *MySqlStore) LoadCitiesByRegionId(regionId int) ([]CityLite, error) {
 	defer trace(traceName(fmt.Sprintf("LoadCitiesByRegionId(%d)", regionId)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT city_id, city_name, region_id, region_code, country_code, lat, lng"+
 			" FROM city_view"+
 			" WHERE region_id=?"+
@@ -107,9 +141,9 @@ func LoadCitiesByRegionId(db *sql.DB, regionId int) ([]CityLite, error) {
 	return readCityListFromRows(rows)
 }
 
-func LoadCitiesByCountryCode(db *sql.DB, countryCode string) ([]CityLite, error) {
+func (s *MySqlStore) LoadCitiesByCountryCode(countryCode string) ([]CityLite, error) {
 	defer trace(traceName(fmt.Sprintf("LoadCitiesByCountryCode(%s)", countryCode)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT city_id, city_name, region_id, region_code, country_code, lat, lng"+
 			" FROM city_view"+
 			" WHERE country_code=?"+
@@ -122,10 +156,25 @@ func LoadCitiesByCountryCode(db *sql.DB, countryCode string) ([]CityLite, error)
 	return readCityListFromRows(rows)
 }
 
-func LoadCitiesByPrefix(db *sql.DB, prefix string, offset int) ([]CityLite, error) {
+func (s *MySqlStore) LoadCitiesByName(name string) ([]CityLite, error) {
+	defer trace(traceName(fmt.Sprintf("LoadCitiesByName(%s)", name)))
+	rows, err := s.db.Query(
+		"SELECT city_id, city_name, region_id, region_code, country_code, lat, lng"+
+			" FROM city_view"+
+			" WHERE city_name=?"+
+			" ORDER BY country_code, region_code", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return readCityListFromRows(rows)
+}
+
+func (s *MySqlStore) LoadCitiesByPrefix(prefix string, offset int) ([]CityLite, error) {
 	defer trace(traceName(fmt.Sprintf("LoadCitiesByPrefix(%s)", prefix)))
 	prefix = prefix + "%"
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT city_id, city_name, region_id, region_code, country_code, lat, lng"+
 			" FROM city_view"+
 			" WHERE city_name LIKE ?"+
@@ -139,22 +188,22 @@ func LoadCitiesByPrefix(db *sql.DB, prefix string, offset int) ([]CityLite, erro
 	return readCityListFromRows(rows)
 }
 
-func DeleteCity(db *sql.DB, id int) error {
+func (s *MySqlStore) DeleteCity(id int) error {
 	trace(traceName(fmt.Sprintf("DeleteCity(%d)", id)))
-	res, err := db.Exec("DELETE FROM cities WHERE id=?", id)
+	res, err := s.db.Exec("DELETE FROM cities WHERE id=?", id)
 	if err != nil {
 		return err
 	}
 	numAffected, _ := res.RowsAffected()
 	if numAffected < 1 {
-		return fmt.Errorf("City not found (id: %d)", id)
+		return notFoundf("City not found (id: %d)", id)
 	}
 	return nil
 }
 
-func InsertCity(db *sql.DB, name string, regionId int, lat float32, lng float32) (*CityLite, error) {
+func (s *MySqlStore) InsertCity(name string, regionId int, lat float32, lng float32) (*CityLite, error) {
 	trace(traceName(fmt.Sprintf("InsertCity(name:%s, regionId: %d)", name, regionId)))
-	res, err := db.Exec("INSERT INTO cities (name, region_id, lat, lng) VALUES(?, ?, ?, ?)", name, regionId, lat, lng)
+	res, err := s.db.Exec("INSERT INTO cities (name, region_id, lat, lng) VALUES(?, ?, ?, ?)", name, regionId, lat, lng)
 	if err != nil {
 		return nil, err
 	}
@@ -162,12 +211,12 @@ func InsertCity(db *sql.DB, name string, regionId int, lat float32, lng float32)
 	if err != nil {
 		return nil, err
 	}
-	return LoadCityById(db, int(cityId))
+	return s.LoadCityById(int(cityId))
 }
 
-func UpdateCity(db *sql.DB, cityId int, name string, regionId int, lat float32, lng float32) error {
+func (s *MySqlStore) UpdateCity(cityId int, name string, regionId int, lat float32, lng float32) error {
 	trace(traceName(fmt.Sprintf("UpdateCity(%d)", cityId)))
-	_, err := db.Exec(
+	_, err := s.db.Exec(
 		"UPDATE cities"+
 			" SET name=?, region_id=?, lat=?, lng=?"+
 			" WHERE id=?",
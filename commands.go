@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Run a command line subcommand (eg. "import-gedcom family.ged") instead of the web server
+func runCommand(args []string) error {
+	switch args[0] {
+	case "import-gedcom":
+		return importGedcomCommand(args[1:])
+	case "migrate":
+		return migrateCommand(args[1:])
+	case "create-user":
+		return createUserCommand(args[1:])
+	case "set-password":
+		return setPasswordCommand(args[1:])
+	case "set-role":
+		return setRoleCommand(args[1:])
+	case "send-reminders":
+		return sendRemindersCommand(args[1:])
+	case "add-reminder-recipient":
+		return addReminderRecipientCommand(args[1:])
+	case "openapi":
+		return openApiCommand(args[1:])
+	case "config":
+		printConfig()
+		return nil
+	default:
+		return fmt.Errorf("Unknown command: %s", args[0])
+	}
+}
+
+func importGedcomCommand(args []string) error {
+	flags := flag.NewFlagSet("import-gedcom", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without writing to the database")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: import-gedcom [-dry-run] <file.ged>")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	report, err := ImportGedcom(store, file, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range report.People {
+		fmt.Printf("person  %-8s %-6s %s", item.XRef, item.Action, item.Name)
+		if item.Reason != "" {
+			fmt.Printf(" (%s)", item.Reason)
+		}
+		fmt.Println()
+	}
+	for _, item := range report.Spouses {
+		fmt.Printf("spouses %-8s %-6s %s & %s", item.XRef, item.Action, item.Person1, item.Person2)
+		if item.Reason != "" {
+			fmt.Printf(" (%s)", item.Reason)
+		}
+		fmt.Println()
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("warning: %s\n", warning)
+	}
+	for _, estimate := range report.Estimated {
+		fmt.Printf("estimated: %s\n", estimate)
+	}
+	fmt.Printf("People: %d create, %d match, %d skip\n",
+		report.CountPeople(GedcomActionCreate), report.CountPeople(GedcomActionMatch), report.CountPeople(GedcomActionSkip))
+	fmt.Printf("Spouses: %d create, %d match, %d skip\n",
+		report.CountSpouses(GedcomActionCreate), report.CountSpouses(GedcomActionMatch), report.CountSpouses(GedcomActionSkip))
+	if report.DryRun {
+		fmt.Println("Dry run, nothing was written")
+	}
+	return nil
+}
+
+func migrateCommand(args []string) error {
+	usage := fmt.Errorf("Usage: migrate up | down [steps] | status")
+	if len(args) == 0 {
+		return usage
+	}
+	if db == nil {
+		return fmt.Errorf("Migrations require the mysql store")
+	}
+	switch args[0] {
+	case "up":
+		return MigrateUp(db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return usage
+			}
+		}
+		return MigrateDown(db, steps)
+	case "status":
+		migrations, err := LoadMigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			status := "pending"
+			if migration.IsApplied() {
+				status = "applied " + migration.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d %-30s %s\n", migration.Version, migration.Name, status)
+		}
+		return nil
+	default:
+		return usage
+	}
+}
+
+// Read a password from the first line of stdin, so it stays out of the shell history
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) < 8 {
+		return "", fmt.Errorf("Passwords must be at least 8 characters")
+	}
+	return password, nil
+}
+
+func createUserCommand(args []string) error {
+	flags := flag.NewFlagSet("create-user", flag.ExitOnError)
+	name := flags.String("name", "", "name shown for the user")
+	role := flags.String("role", string(RoleViewer), "what the user may do: viewer, editor or admin")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: create-user [-name <name>] [-role <role>] <email>")
+	}
+	email := strings.TrimSpace(flags.Arg(0))
+	if !Role(*role).IsValid() {
+		return fmt.Errorf("Unknown role: %s", *role)
+	}
+
+	existing, err := store.LoadUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("A user already exists with email: %s", email)
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	userId, err := store.InsertUser(email, *name, Role(*role), passwordHash)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %s %d: %s\n", *role, userId, email)
+	return nil
+}
+
+func setPasswordCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Usage: set-password <email>")
+	}
+	user, err := store.LoadUserByEmail(strings.TrimSpace(args[0]))
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("User not found with email: %s", args[0])
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return store.UpdateUserPassword(user.Id, passwordHash)
+}
+
+func setRoleCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("Usage: set-role <email> <viewer|editor|admin>")
+	}
+	role := Role(args[1])
+	if !role.IsValid() {
+		return fmt.Errorf("Unknown role: %s", args[1])
+	}
+	user, err := store.LoadUserByEmail(strings.TrimSpace(args[0]))
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("User not found with email: %s", args[0])
+	}
+	err = store.UpdateUserRole(user.Id, role)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", user.Email, role)
+	return nil
+}
+
+func sendRemindersCommand(args []string) error {
+	flags := flag.NewFlagSet("send-reminders", flag.ExitOnError)
+	start := flags.String("start", "", "Monday the reminders start on (YYYY-MM-DD), defaults to next Monday.  Recipients already sent that week are skipped.")
+	flags.Parse(args)
+
+	startDate := getMonday(time.Now())
+	if *start != "" {
+		var err error
+		startDate, err = time.Parse("2006-01-02", *start)
+		if err != nil {
+			return fmt.Errorf("Invalid start date: %s", *start)
+		}
+	}
+	run, err := RunReminders(store, startDate)
+	if run != nil {
+		fmt.Printf("Sent %d reminder email(s) with the %s mailer, skipped %d already sent\n", run.EmailsSent, config.mailer, run.EmailsSkipped)
+	}
+	return err
+}
+
+func addReminderRecipientCommand(args []string) error {
+	flags := flag.NewFlagSet("add-reminder-recipient", flag.ExitOnError)
+	leadDays := flags.Int("leadDays", defaultReminderLeadDays, "number of days of upcoming events in each email")
+	memorials := flags.Bool("memorials", false, "also remind about the anniversaries of deaths")
+	country := flags.String("country", "", "only remind about the holidays for everyone and this country code")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return fmt.Errorf("Usage: add-reminder-recipient [-leadDays <days>] [-memorials] [-country <code>] <name> <email>")
+	}
+	if *leadDays < 1 || *leadDays > maxReminderLeadDays {
+		return fmt.Errorf("Lead time must be between 1 and %d days", maxReminderLeadDays)
+	}
+	data := ReminderRecipientData{
+		Name:          strings.TrimSpace(flags.Arg(0)),
+		Email:         strings.TrimSpace(flags.Arg(1)),
+		Birthdays:     true,
+		Anniversaries: true,
+		Holidays:      true,
+		Memorials:     *memorials,
+		LeadDays:      *leadDays,
+		CountryCode:   strings.ToUpper(strings.TrimSpace(*country)),
+	}
+	if data.Name == "" {
+		return fmt.Errorf("Empty name")
+	}
+	if !strings.Contains(data.Email, "@") {
+		return fmt.Errorf("Invalid email: %s", data.Email)
+	}
+	id, err := store.InsertReminderRecipient(data)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added reminder recipient %d: %s <%s>\n", id, data.Name, data.Email)
+	return nil
+}
+
+// Print the API's OpenAPI document, eg. to generate a client from
+func openApiCommand(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("Usage: openapi")
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildOpenApiDocument())
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Every setting is a flag, and can also be given in a JSON config file or an
+// environment variable.  Flags win over environment variables, which win over
+// the file, so secrets can be kept off the command line where ps shows them.
+
+// Settings never printed as they are
+var secretSettings = map[string]bool{
+	"database":     true,
+	"mapsApiKey":   true,
+	"awsAccessKey": true,
+	"awsSecret":    true,
+	"smtpPassword": true,
+}
+
+// Where each setting's value came from: "default", "file", "environment"
+// or "flag"
+var configSources = make(map[string]string)
+
+// Environment variable for a setting, like FAMILY_SMTP_HOST for smtpHost
+func settingEnvName(name string) string {
+	var env strings.Builder
+	env.WriteString("FAMILY_")
+	previous := rune(0)
+	for _, r := range name {
+		if unicode.IsUpper(r) && (unicode.IsLower(previous) || unicode.IsDigit(previous)) {
+			env.WriteRune('_')
+		}
+		env.WriteRune(unicode.ToUpper(r))
+		previous = r
+	}
+	return env.String()
+}
+
+// Parse the command line, then fill in the settings it didn't give from the
+// environment and the config file
+func loadConfig(args []string) error {
+	err := flag.CommandLine.Parse(args)
+	if err != nil {
+		return err
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		configSources[f.Name] = "default"
+	})
+	flag.Visit(func(f *flag.Flag) {
+		configSources[f.Name] = "flag"
+	})
+
+	if path, found := os.LookupEnv(settingEnvName("config")); found && configSources["config"] != "flag" {
+		config.configFile = path
+		configSources["config"] = "environment"
+	}
+	if config.configFile != "" {
+		err = loadConfigFile(config.configFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	flag.VisitAll(func(f *flag.Flag) {
+		value, found := os.LookupEnv(settingEnvName(f.Name))
+		if err != nil || !found || configSources[f.Name] == "flag" {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("Invalid %s: %v", settingEnvName(f.Name), setErr)
+			return
+		}
+		configSources[f.Name] = "environment"
+	})
+	return err
+}
+
+// Settings from a JSON object keyed by flag name, like
+// {"database": "...", "smtpPort": 587}
+func loadConfigFile(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var settings map[string]interface{}
+	err = json.Unmarshal(contents, &settings)
+	if err != nil {
+		return fmt.Errorf("Error reading config file %s: %v", path, err)
+	}
+
+	for name, value := range settings {
+		f := flag.Lookup(name)
+		if f == nil || name == "config" {
+			return fmt.Errorf("Unknown setting in config file %s: %s", path, name)
+		}
+		if configSources[name] == "flag" {
+			continue
+		}
+		var text string
+		switch value := value.(type) {
+		case string:
+			text = value
+		case bool:
+			text = strconv.FormatBool(value)
+		case float64:
+			text = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			return fmt.Errorf("Invalid %s in config file %s: %v", name, path, value)
+		}
+		err = f.Value.Set(text)
+		if err != nil {
+			return fmt.Errorf("Invalid %s in config file %s: %v", name, path, err)
+		}
+		configSources[name] = "file"
+	}
+	return nil
+}
+
+// A setting's value as it's safe to print.  A database dsn keeps everything
+// but the password.
+func redactedSetting(name string, value string) string {
+	if !secretSettings[name] || value == "" {
+		return value
+	}
+	if name == "database" {
+		return redactDsn(value)
+	}
+	return "****"
+}
+
+func redactDsn(dsn string) string {
+	dsnConfig, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "****"
+	}
+	if dsnConfig.Passwd != "" {
+		dsnConfig.Passwd = "****"
+	}
+	return dsnConfig.FormatDSN()
+}
+
+// Secrets given as flags, which anyone on the machine can see in ps
+func secretFlagWarnings() []string {
+	var warnings []string
+	flag.Visit(func(f *flag.Flag) {
+		if secretSettings[f.Name] {
+			warnings = append(warnings, fmt.Sprintf(
+				"-%s was given on the command line, where ps shows it.  Use %s or the config file instead.",
+				f.Name, settingEnvName(f.Name)))
+		}
+	})
+	return warnings
+}
+
+// Every setting with its value, secrets redacted, and where it came from
+func printConfig() {
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	sort.Strings(names)
+	for _, name := range names {
+		value := flag.Lookup(name).Value.String()
+		fmt.Printf("%-22s %-12s %s\n", name, configSources[name], redactedSetting(name, value))
+	}
+}
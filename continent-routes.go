@@ -2,20 +2,16 @@ package main
 
 import (
 	"fmt"
-	"html/template"
 	"net/http"
 )
 
 func continentList(w http.ResponseWriter, r *http.Request) {
-	continents, err := LoadContinentList(db)
+	continents, err := store.LoadContinentList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading continents: %v", err), 500)
 		return
 	}
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/continent/list.html")).Execute(w, continents)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "continent/list.html", continents)
 }
 
 func continentView(w http.ResponseWriter, r *http.Request) {
@@ -25,13 +21,13 @@ func continentView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	continent, err := LoadContinentByCode(db, continentCode)
+	continent, err := store.LoadContinentByCode(continentCode)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading continent: %v", err), 500)
 		return
 	}
 
-	countries, err := LoadCountriesByContinentCode(db, continentCode)
+	countries, err := store.LoadCountriesByContinentCode(continentCode)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading countries: %v", err), 500)
 		return
@@ -45,13 +41,10 @@ func continentView(w http.ResponseWriter, r *http.Request) {
 		countries,
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/continent/view.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "continent/view.html", data)
 }
 
 func init() {
-	http.HandleFunc("/continent/list", continentList)
-	http.HandleFunc("/continent/view/", continentView)
+	http.HandleFunc("/continent/list", requireRole(RoleViewer, continentList))
+	http.HandleFunc("/continent/view/", requireRole(RoleViewer, continentView))
 }
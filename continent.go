@@ -33,9 +33,9 @@ func (c *ContinentWithMap) MapUrl() string {
 		config.mapsApiKey)
 }
 
-func LoadContinentList(db *sql.DB) ([]Continent, error) {
+func (s *MySqlStore) LoadContinentList() ([]Continent, error) {
 	defer trace(traceName("LoadContinentList"))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT code, name, color" +
 			" FROM continents" +
 			" ORDER BY name")
@@ -47,9 +47,9 @@ func LoadContinentList(db *sql.DB) ([]Continent, error) {
 	return readContinentListFromRows(rows)
 }
 
-func LoadContinentByCode(db *sql.DB, code string) (*ContinentWithMap, error) {
+func (s *MySqlStore) LoadContinentByCode(code string) (*ContinentWithMap, error) {
 	defer trace(traceName(fmt.Sprintf("LoadContinentByCode(%s)", code)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT code, name, map_lat, map_lng, map_zoom, color"+
 			" FROM continents"+
 			" WHERE code=?",
@@ -60,7 +60,7 @@ func LoadContinentByCode(db *sql.DB, code string) (*ContinentWithMap, error) {
 	defer rows.Close()
 
 	if !rows.Next() {
-		return nil, fmt.Errorf("Error continent not found. Code: %s", code)
+		return nil, notFoundf("Error continent not found. Code: %s", code)
 	}
 	var item ContinentWithMap
 	err = rows.Scan(&item.Code, &item.Name, &item.MapLatitude, &item.MapLongitude, &item.MapZoom, &item.Color)
@@ -70,6 +70,32 @@ func LoadContinentByCode(db *sql.DB, code string) (*ContinentWithMap, error) {
 	return &item, nil
 }
 
+func (s *MySqlStore) InsertContinent(data ContinentWithMap) error {
+	defer trace(traceName(fmt.Sprintf("InsertContinent(%s)", data.Code)))
+	_, err := s.db.Exec(
+		"INSERT INTO continents"+
+			" (code, name, map_lat, map_lng, map_zoom, color)"+
+			" VALUES (?, ?, ?, ?, ?, ?)",
+		data.Code, data.Name, data.MapLatitude, data.MapLongitude, data.MapZoom, data.Color)
+	return err
+}
+
+func (s *MySqlStore) UpdateContinent(originalCode string, data ContinentWithMap) error {
+	defer trace(traceName(fmt.Sprintf("UpdateContinent(%s)", originalCode)))
+	_, err := s.db.Exec(
+		"UPDATE continents"+
+			" SET code=?, name=?, map_lat=?, map_lng=?, map_zoom=?, color=?"+
+			" WHERE code=?",
+		data.Code, data.Name, data.MapLatitude, data.MapLongitude, data.MapZoom, data.Color, originalCode)
+	return err
+}
+
+func (s *MySqlStore) DeleteContinent(code string) error {
+	defer trace(traceName(fmt.Sprintf("DeleteContinent(%s)", code)))
+	_, err := s.db.Exec("DELETE FROM continents WHERE code=?", code)
+	return err
+}
+
 func readContinentListFromRows(rows *sql.Rows) ([]Continent, error) {
 	var list []Continent
 	for rows.Next() {
@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,22 +10,19 @@ import (
 
 // Show a list of countries
 func countryList(w http.ResponseWriter, r *http.Request) {
-	countries, err := LoadCountryList(db)
+	countries, err := store.LoadCountryList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading country list: %v", err), 500)
 		return
 	}
 
 	// Output the result
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/country/list.html")).Execute(w, countries)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "country/list.html", countries)
 }
 
 // List all countries as JSON
 func countryJsonList(w http.ResponseWriter, r *http.Request) {
-	countries, err := LoadCountryList(db)
+	countries, err := store.LoadCountryList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading country list: %v", err), 500)
 		return
@@ -42,14 +38,15 @@ func countryView(w http.ResponseWriter, r *http.Request) {
 	code, err := getPathParam(r, "countryCode", 3)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error parsing countryCode: %v", err), 400)
+		return
 	}
 
-	item, err := LoadCountryByCode(db, code)
+	item, err := store.LoadCountryByCode(code)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading country: %v", err), 500)
 		return
 	}
-	regions, err := LoadRegionsByCountryCode(db, code)
+	regions, err := store.LoadRegionsByCountryCode(code)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading regions: %v", err), 500)
 		return
@@ -63,10 +60,7 @@ func countryView(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Output the result
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/country/view.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "country/view.html", data)
 }
 
 // Add a new country
@@ -89,7 +83,7 @@ func countryAdd(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err := InsertCountry(db, item)
+		err := store.InsertCountry(item)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error inserting country: %v", err), 500)
 			return
@@ -98,7 +92,7 @@ func countryAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	continents, err := LoadContinentList(db)
+	continents, err := store.LoadContinentList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading continents: %v", err), 500)
 		return
@@ -110,10 +104,7 @@ func countryAdd(w http.ResponseWriter, r *http.Request) {
 		continents,
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/country/add.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "country/add.html", data)
 }
 
 // Add a new country
@@ -142,7 +133,7 @@ func countryEdit(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err := UpdateCountry(db, originalCode, item)
+		err := store.UpdateCountry(originalCode, item)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error updating country: %v", err), 500)
 			return
@@ -151,12 +142,12 @@ func countryEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	country, err := LoadCountryByCode(db, originalCode)
+	country, err := store.LoadCountryByCode(originalCode)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading country: %v", err), 500)
 		return
 	}
-	continents, err := LoadContinentList(db)
+	continents, err := store.LoadContinentList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading continents: %v", err), 500)
 		return
@@ -170,10 +161,7 @@ func countryEdit(w http.ResponseWriter, r *http.Request) {
 		country,
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/country/edit.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "country/edit.html", data)
 }
 
 // Delete a country
@@ -183,7 +171,7 @@ func countryDelete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Error parsing countryCode: %v", err), 400)
 	}
 
-	err = DeleteCountryByCode(db, code)
+	err = store.DeleteCountryByCode(code)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error deleting country: %v", err), 500)
 		return
@@ -192,10 +180,10 @@ func countryDelete(w http.ResponseWriter, r *http.Request) {
 }
 
 func addCountryRoutes() {
-	http.HandleFunc("/country/add", countryAdd)
-	http.HandleFunc("/country/edit/", countryEdit)
-	http.HandleFunc("/country/delete/", countryDelete)
-	http.HandleFunc("/country/list", countryList)
-	http.HandleFunc("/country/json/list", countryJsonList)
-	http.HandleFunc("/country/view/", countryView)
+	http.HandleFunc("/country/add", requireRole(RoleEditor, countryAdd))
+	http.HandleFunc("/country/edit/", requireRole(RoleEditor, countryEdit))
+	http.HandleFunc("/country/delete/", requireRole(RoleAdmin, requirePost(countryDelete)))
+	http.HandleFunc("/country/list", requireRole(RoleViewer, countryList))
+	http.HandleFunc("/country/json/list", requireRole(RoleViewer, countryJsonList))
+	http.HandleFunc("/country/view/", requireRole(RoleViewer, countryView))
 }
@@ -37,9 +37,9 @@ func (c *Country) PopulationFormatted() string {
 	return message.NewPrinter(language.English).Sprint(c.Population)
 }
 
-func LoadCountriesByContinentCode(db *sql.DB, continentCode string) ([]Country, error) {
+func (s *MySqlStore) LoadCountriesByContinentCode(continentCode string) ([]Country, error) {
 	defer trace(traceName(fmt.Sprintf("LoadCountriesByContinentCode(%s)", continentCode)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT co.code, co.name, co.gdp, co.population, co.has_region_icons,"+
 			" ci.city_id, ci.city_name, ci.region_id, ci.region_code,"+
 			" ct.code, ct.name"+
@@ -55,9 +55,9 @@ func LoadCountriesByContinentCode(db *sql.DB, continentCode string) ([]Country,
 	return readCountryListFromRows(rows)
 }
 
-func LoadCountryByCode(db *sql.DB, code string) (*Country, error) {
+func (s *MySqlStore) LoadCountryByCode(code string) (*Country, error) {
 	defer trace(traceName(fmt.Sprintf("LoadCountryBycode(%s)", code)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT co.code, co.name, co.gdp, co.population, co.has_region_icons,"+
 			" ci.city_id, ci.city_name, ci.region_id, ci.region_code,"+
 			" ct.code, ct.name"+
@@ -73,13 +73,13 @@ func LoadCountryByCode(db *sql.DB, code string) (*Country, error) {
 	if rows.Next() {
 		return readCountryFromRows(rows)
 	} else {
-		return nil, fmt.Errorf("Country not found. Code: %s", code)
+		return nil, notFoundf("Country not found. Code: %s", code)
 	}
 }
 
-func LoadCountryList(db *sql.DB) ([]Country, error) {
+func (s *MySqlStore) LoadCountryList() ([]Country, error) {
 	defer trace(traceName("LoadCountryList"))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT co.code, co.name, co.gdp, co.population, co.has_region_icons," +
 			" ci.city_id, ci.city_name, ci.region_id, ci.region_code," +
 			" ct.code, ct.name" +
@@ -130,10 +130,10 @@ func readCountryFromRows(rows *sql.Rows) (*Country, error) {
 	return &country, nil
 }
 
-func DeleteCountryByCode(db *sql.DB, code string) error {
+func (s *MySqlStore) DeleteCountryByCode(code string) error {
 	defer trace(traceName(fmt.Sprintf("DeleteCountryByCode(%s)", code)))
 	// Delete the row
-	res, err := db.Exec("DELETE FROM countries WHERE code=?", code)
+	res, err := s.db.Exec("DELETE FROM countries WHERE code=?", code)
 	if err != nil {
 		return err
 	}
@@ -143,18 +143,18 @@ func DeleteCountryByCode(db *sql.DB, code string) error {
 		return err
 	}
 	if numAffected == 0 {
-		return fmt.Errorf("Country not found, code: %s", code)
+		return notFoundf("Country not found, code: %s", code)
 	}
 	return nil
 }
 
-func InsertCountry(db *sql.DB, data CountryData) error {
+func (s *MySqlStore) InsertCountry(data CountryData) error {
 	log.Printf("Insert country (data: %v)", data)
 	nullableCapitalCityId := sql.NullInt64{
 		Int64: int64(data.CapitalCityId),
 		Valid: data.CapitalCityId > 0,
 	}
-	res, err := db.Exec("INSERT INTO countries"+
+	res, err := s.db.Exec("INSERT INTO countries"+
 		" (name, code, capital_city_id, gdp, population, has_region_icons, continent_code)"+
 		" VALUES(?, ?, ?, ?, ?, ?, ?)",
 		data.Name, data.Code, nullableCapitalCityId, data.Gdp, data.Population, data.HasRegionIcons, data.ContinentCode)
@@ -165,13 +165,13 @@ func InsertCountry(db *sql.DB, data CountryData) error {
 	return err
 }
 
-func UpdateCountry(db *sql.DB, originalCode string, data CountryData) error {
+func (s *MySqlStore) UpdateCountry(originalCode string, data CountryData) error {
 	log.Printf("Update country (originalCode: %s, data: %v)", originalCode, data)
 	nullableCapitalCityId := sql.NullInt64{
 		Int64: int64(data.CapitalCityId),
 		Valid: data.CapitalCityId > 0,
 	}
-	_, err := db.Exec("UPDATE countries "+
+	_, err := s.db.Exec("UPDATE countries "+
 		"SET name=?, code=?, capital_city_id=?, gdp=?, population=?, has_region_icons=?, continent_code=? "+
 		"WHERE code=?",
 		data.Name, data.Code, nullableCapitalCityId, data.Gdp, data.Population, data.HasRegionIcons, data.ContinentCode,
@@ -5,15 +5,9 @@ import (
 	"fmt"
 	"html/template"
 	"time"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ses"
 )
 
-func GetEmailBodies(name string, startDate time.Time, events []CalendarEvent) (template.HTML, string) {
+func GetEmailBodies(name string, startDate time.Time, events []CalendarEvent) (template.HTML, string, error) {
 	data := struct {
 		Name      string
 		Events    []CalendarEvent
@@ -25,83 +19,31 @@ func GetEmailBodies(name string, startDate time.Time, events []CalendarEvent) (t
 	}
 
 	var htmlBuffer, textBuffer bytes.Buffer
-	err := template.Must(template.ParseFiles("tmpl/cron/email.html")).Execute(&htmlBuffer, data)
+	err := templates.Execute(&htmlBuffer, "cron/email.html", nil, "", data)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
-	err = template.Must(template.ParseFiles("tmpl/cron/email.txt")).Execute(&textBuffer, data)
+	err = templates.Execute(&textBuffer, "cron/email.txt", nil, "", data)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
-	return template.HTML(htmlBuffer.String()), textBuffer.String()
+	return template.HTML(htmlBuffer.String()), textBuffer.String(), nil
 }
 
-func SendReminderEmail(startDate time.Time, events []CalendarEvent) {
-	defer trace(traceName(fmt.Sprintf("SendReminderEmail(%v, %v)", startDate, events)))
-
-	SendReminderEmailToUser(startDate, events, "Sarah", "sarahvictoriayoung@gmail.com")
-	SendReminderEmailToUser(startDate, events, "Ian", "ian@lucidhelix.com")
-}
-
-func SendReminderEmailToUser(startDate time.Time, events []CalendarEvent, name string, email string) {
+func SendReminderEmailToUser(startDate time.Time, events []CalendarEvent, name string, email string) error {
 	defer trace(traceName(fmt.Sprintf("SendReminderEmailToUser(%v, %v, %s, %s)", startDate, events, name, email)))
 
-	subject := fmt.Sprintf("Family Reminders - %s", startDate.Format("Mon, Jan 2, 2006"))
-	htmlBody, textBody := GetEmailBodies(name, startDate, events)
-
-	awsConfig := aws.NewConfig().WithRegion("us-east-1").WithCredentials(credentials.NewStaticCredentials(config.awsAccessKey, config.awsSecret, ""))
-
-	svc := ses.New(session.New(awsConfig))
-	input := &ses.SendEmailInput{
-		Destination: &ses.Destination{
-			ToAddresses: []*string{
-				aws.String(email),
-			},
-		},
-		Message: &ses.Message{
-			Body: &ses.Body{
-				Html: &ses.Content{
-					Charset: aws.String("UTF-8"),
-					Data:    aws.String(string(htmlBody)),
-				},
-				Text: &ses.Content{
-					Charset: aws.String("UTF-8"),
-					Data:    aws.String(textBody),
-				},
-			},
-			Subject: &ses.Content{
-				Charset: aws.String("UTF-8"),
-				Data:    aws.String(subject),
-			},
-		},
-		Source: aws.String("family@icadev.com"),
-	}
-
-	result, err := svc.SendEmail(input)
+	htmlBody, textBody, err := GetEmailBodies(name, startDate, events)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case ses.ErrCodeMessageRejected:
-				fmt.Println(ses.ErrCodeMessageRejected, aerr.Error())
-			case ses.ErrCodeMailFromDomainNotVerifiedException:
-				fmt.Println(ses.ErrCodeMailFromDomainNotVerifiedException, aerr.Error())
-			case ses.ErrCodeConfigurationSetDoesNotExistException:
-				fmt.Println(ses.ErrCodeConfigurationSetDoesNotExistException, aerr.Error())
-			case ses.ErrCodeConfigurationSetSendingPausedException:
-				fmt.Println(ses.ErrCodeConfigurationSetSendingPausedException, aerr.Error())
-			case ses.ErrCodeAccountSendingPausedException:
-				fmt.Println(ses.ErrCodeAccountSendingPausedException, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
-		return
+		return err
 	}
-
-	fmt.Println(result)
+	return mailer.Send(EmailMessage{
+		From:     config.mailFrom,
+		ToName:   name,
+		ToEmail:  email,
+		Subject:  fmt.Sprintf("Family Reminders - %s", startDate.Format("Mon, Jan 2, 2006")),
+		HtmlBody: string(htmlBody),
+		TextBody: textBody,
+	})
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (s *MySqlStore) LoadFavoritePeople(userId int) ([]PersonLite, error) {
+	defer trace(traceName(fmt.Sprintf("LoadFavoritePeople(%d)", userId)))
+	rows, err := s.db.Query(
+		"SELECT p.id, p.first_name, p.middle_name, p.last_name, p.nick_name, p.gender, p.mother_id, p.father_id"+
+			" FROM people p"+
+			"   INNER JOIN favorite_people fp ON fp.person_id = p.id"+
+			" WHERE fp.user_id = ?"+
+			" ORDER BY last_name, first_name",
+		userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return readPersonLiteListFromRows(rows)
+}
+
+// Load the ids of a user's favorite people, for marking them in lists
+func (s *MySqlStore) LoadFavoritePersonIds(userId int) (map[int]bool, error) {
+	defer trace(traceName(fmt.Sprintf("LoadFavoritePersonIds(%d)", userId)))
+	rows, err := s.db.Query("SELECT person_id FROM favorite_people WHERE user_id = ?", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lookup := make(map[int]bool)
+	for rows.Next() {
+		var personId int
+		err = rows.Scan(&personId)
+		if err != nil {
+			return nil, err
+		}
+		lookup[personId] = true
+	}
+	return lookup, nil
+}
+
+func (s *MySqlStore) InsertFavoritePerson(userId int, personId int) error {
+	defer trace(traceName(fmt.Sprintf("InsertFavoritePerson(%d, %d)", userId, personId)))
+	_, err := s.db.Exec("INSERT IGNORE INTO favorite_people (user_id, person_id) VALUES (?, ?)", userId, personId)
+	return err
+}
+
+func (s *MySqlStore) DeleteFavoritePerson(userId int, personId int) error {
+	defer trace(traceName(fmt.Sprintf("DeleteFavoritePerson(%d, %d)", userId, personId)))
+	_, err := s.db.Exec("DELETE FROM favorite_people WHERE user_id = ? AND person_id = ?", userId, personId)
+	return err
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type GedcomExportOptions struct {
+	// Export only this person and their descendants (0 exports everyone)
+	RootId int
+	// Number of generations below the root to export (0 is unlimited)
+	Depth int
+	// Write tags as NOTE lines rather than custom _TAG lines
+	TagsAsNotes bool
+}
+
+type gedcomFamilyKey struct {
+	HusbandId int
+	WifeId    int
+}
+
+type gedcomFamily struct {
+	XRef     string
+	Key      gedcomFamilyKey
+	Spouse   *SpouseLite
+	Children []int
+}
+
+// Write people, their spouses and their tags as a GEDCOM 5.5.1 file
+func ExportGedcom(store Store, writer io.Writer, options GedcomExportOptions) error {
+	defer trace(traceName(fmt.Sprintf("ExportGedcom(%+v)", options)))
+
+	people, err := store.LoadPersonList()
+	if err != nil {
+		return err
+	}
+	spouses, err := store.LoadSpouseList()
+	if err != nil {
+		return err
+	}
+	tags, err := store.LoadTagsByPerson()
+	if err != nil {
+		return err
+	}
+
+	included, err := gedcomExportedPeople(people, spouses, options)
+	if err != nil {
+		return err
+	}
+
+	// Build the families, couples first so children land in their parents' family
+	var families []*gedcomFamily
+	familyLookup := make(map[gedcomFamilyKey]*gedcomFamily)
+	for i := range spouses {
+		spouse := &spouses[i]
+		if !included[spouse.Person1.Id] || !included[spouse.Person2.Id] {
+			continue
+		}
+		key := gedcomFamilyKey{HusbandId: spouse.Person1.Id, WifeId: spouse.Person2.Id}
+		if spouse.Person1.Gender == "Female" && spouse.Person2.Gender == "Male" {
+			key = gedcomFamilyKey{HusbandId: spouse.Person2.Id, WifeId: spouse.Person1.Id}
+		}
+		family := &gedcomFamily{Key: key, Spouse: spouse}
+		families = append(families, family)
+		familyLookup[key] = family
+	}
+	for _, person := range people {
+		if !included[person.Id] {
+			continue
+		}
+		var key gedcomFamilyKey
+		if person.Father != nil && included[person.Father.Id] {
+			key.HusbandId = person.Father.Id
+		}
+		if person.Mother != nil && included[person.Mother.Id] {
+			key.WifeId = person.Mother.Id
+		}
+		if key.HusbandId == 0 && key.WifeId == 0 {
+			continue
+		}
+		family, found := familyLookup[key]
+		if !found {
+			family = &gedcomFamily{Key: key}
+			families = append(families, family)
+			familyLookup[key] = family
+		}
+		family.Children = append(family.Children, person.Id)
+	}
+
+	childFamily := make(map[int]string)
+	spouseFamilies := make(map[int][]string)
+	for i, family := range families {
+		family.XRef = fmt.Sprintf("@F%d@", i+1)
+		for _, childId := range family.Children {
+			childFamily[childId] = family.XRef
+		}
+		if family.Key.HusbandId > 0 {
+			spouseFamilies[family.Key.HusbandId] = append(spouseFamilies[family.Key.HusbandId], family.XRef)
+		}
+		if family.Key.WifeId > 0 {
+			spouseFamilies[family.Key.WifeId] = append(spouseFamilies[family.Key.WifeId], family.XRef)
+		}
+	}
+
+	out := bufio.NewWriter(writer)
+	fmt.Fprint(out, "0 HEAD\n")
+	fmt.Fprint(out, "1 SOUR FAMILY\n")
+	fmt.Fprintf(out, "1 DATE %s\n", formatGedcomDate(time.Now()))
+	fmt.Fprint(out, "1 GEDC\n")
+	fmt.Fprint(out, "2 VERS 5.5.1\n")
+	fmt.Fprint(out, "2 FORM LINEAGE-LINKED\n")
+	fmt.Fprint(out, "1 CHAR UTF-8\n")
+
+	for i := range people {
+		person := &people[i]
+		if !included[person.Id] {
+			continue
+		}
+		fmt.Fprintf(out, "0 @I%d@ INDI\n", person.Id)
+		given := strings.TrimSpace(person.FirstName + " " + person.MiddleName)
+		writeGedcomText(out, 1, "NAME", fmt.Sprintf("%s /%s/", given, person.LastName))
+		writeGedcomText(out, 2, "GIVN", given)
+		if person.LastName != "" {
+			writeGedcomText(out, 2, "SURN", person.LastName)
+		}
+		if person.NickName != "" {
+			writeGedcomText(out, 2, "NICK", person.NickName)
+		}
+		fmt.Fprintf(out, "1 SEX %s\n", GetGenderCode(person.Gender))
+		if person.HasBirthDate() || person.BirthCity != nil {
+			fmt.Fprint(out, "1 BIRT\n")
+			if person.HasBirthDate() {
+				qualifier := ""
+				if person.IsBirthYearGuess {
+					qualifier = "ABT "
+				}
+				fmt.Fprintf(out, "2 DATE %s%s\n", qualifier, formatGedcomDate(person.BirthDate))
+			}
+			if person.BirthCity != nil {
+				writeGedcomText(out, 2, "PLAC", person.BirthCity.Format())
+			}
+		}
+		if !person.IsAlive {
+			if person.HasDeathDate() || person.DeathCity != nil {
+				fmt.Fprint(out, "1 DEAT\n")
+				if person.HasDeathDate() {
+					qualifier := ""
+					if person.IsDeathYearGuess {
+						qualifier = "ABT "
+					}
+					fmt.Fprintf(out, "2 DATE %s%s\n", qualifier, formatGedcomDate(person.DeathDate))
+				}
+				if person.DeathCity != nil {
+					writeGedcomText(out, 2, "PLAC", person.DeathCity.Format())
+				}
+			} else {
+				fmt.Fprint(out, "1 DEAT Y\n")
+			}
+		}
+		if person.BurialCity != nil {
+			fmt.Fprint(out, "1 BURI\n")
+			writeGedcomText(out, 2, "PLAC", person.BurialCity.Format())
+		}
+		if person.HomeCity != nil {
+			fmt.Fprint(out, "1 RESI\n")
+			writeGedcomText(out, 2, "PLAC", person.HomeCity.Format())
+		}
+		if xref, found := childFamily[person.Id]; found {
+			fmt.Fprintf(out, "1 FAMC %s\n", xref)
+		}
+		for _, xref := range spouseFamilies[person.Id] {
+			fmt.Fprintf(out, "1 FAMS %s\n", xref)
+		}
+		for _, tag := range tags[person.Id] {
+			if options.TagsAsNotes {
+				writeGedcomText(out, 1, "NOTE", "Tag: "+tag.Label)
+			} else {
+				writeGedcomText(out, 1, "_TAG", tag.Label)
+			}
+		}
+	}
+
+	for _, family := range families {
+		fmt.Fprintf(out, "0 %s FAM\n", family.XRef)
+		if family.Key.HusbandId > 0 {
+			fmt.Fprintf(out, "1 HUSB @I%d@\n", family.Key.HusbandId)
+		}
+		if family.Key.WifeId > 0 {
+			fmt.Fprintf(out, "1 WIFE @I%d@\n", family.Key.WifeId)
+		}
+		if spouse := family.Spouse; spouse != nil {
+			if spouse.Status != 2 {
+				fmt.Fprint(out, "1 MARR\n")
+				if !spouse.MarriedDate.IsZero() {
+					fmt.Fprintf(out, "2 DATE %s\n", formatGedcomDate(spouse.MarriedDate))
+				}
+			}
+			if spouse.Status == 3 {
+				fmt.Fprint(out, "1 DIV Y\n")
+			}
+			fmt.Fprintf(out, "1 _STAT %s\n", strings.ToUpper(spouse.StatusFormatted()))
+		}
+		for _, childId := range family.Children {
+			fmt.Fprintf(out, "1 CHIL @I%d@\n", childId)
+		}
+	}
+
+	fmt.Fprint(out, "0 TRLR\n")
+	return out.Flush()
+}
+
+// Work out which people are exported: everyone, or the root, their
+// descendants down to the requested depth and the spouses of all of them.
+func gedcomExportedPeople(people []Person, spouses []SpouseLite, options GedcomExportOptions) (map[int]bool, error) {
+	included := make(map[int]bool)
+	if options.RootId == 0 {
+		for _, person := range people {
+			included[person.Id] = true
+		}
+		return included, nil
+	}
+
+	children := make(map[int][]int)
+	found := false
+	for _, person := range people {
+		if person.Id == options.RootId {
+			found = true
+		}
+		if person.Mother != nil {
+			children[person.Mother.Id] = append(children[person.Mother.Id], person.Id)
+		}
+		if person.Father != nil {
+			children[person.Father.Id] = append(children[person.Father.Id], person.Id)
+		}
+	}
+	if !found {
+		return nil, notFoundf("Person not found with id: %d", options.RootId)
+	}
+
+	generation := []int{options.RootId}
+	included[options.RootId] = true
+	for depth := 1; len(generation) > 0 && (options.Depth <= 0 || depth <= options.Depth); depth++ {
+		var next []int
+		for _, personId := range generation {
+			for _, childId := range children[personId] {
+				if !included[childId] {
+					included[childId] = true
+					next = append(next, childId)
+				}
+			}
+		}
+		generation = next
+	}
+
+	var partners []int
+	for _, spouse := range spouses {
+		if included[spouse.Person1.Id] {
+			partners = append(partners, spouse.Person2.Id)
+		}
+		if included[spouse.Person2.Id] {
+			partners = append(partners, spouse.Person1.Id)
+		}
+	}
+	for _, personId := range partners {
+		included[personId] = true
+	}
+	return included, nil
+}
+
+// Longest value written on one line.  GEDCOM lines are at most 255
+// characters, so longer values go on CONC lines after it.
+const gedcomMaxValue = 200
+
+// Write a line of free text, doubling any @ so it isn't read as a cross
+// reference, and splitting it onto CONT lines at newlines and CONC lines
+// when it's long
+func writeGedcomText(out io.Writer, level int, tag string, value string) {
+	value = strings.ReplaceAll(value, "@", "@@")
+	for i, line := range strings.Split(value, "\n") {
+		lineLevel, lineTag := level, tag
+		if i > 0 {
+			lineLevel, lineTag = level+1, "CONT"
+		}
+		for {
+			var rest string
+			line, rest = splitGedcomValue(line)
+			if line == "" {
+				fmt.Fprintf(out, "%d %s\n", lineLevel, lineTag)
+			} else {
+				fmt.Fprintf(out, "%d %s %s\n", lineLevel, lineTag, line)
+			}
+			if rest == "" {
+				break
+			}
+			line = rest
+			lineLevel, lineTag = level+1, "CONC"
+		}
+	}
+}
+
+// Split a value at gedcomMaxValue bytes, moving back so the split isn't
+// inside a character, next to a space (some readers drop spaces at the ends
+// of lines) or between the two halves of an @@
+func splitGedcomValue(value string) (string, string) {
+	if len(value) <= gedcomMaxValue {
+		return value, ""
+	}
+	isSplit := func(cut int) bool {
+		return utf8.RuneStart(value[cut]) && value[cut] != ' ' && value[cut-1] != ' ' &&
+			!(value[cut] == '@' && strings.Count(value[:cut], "@")%2 == 1)
+	}
+	cut := gedcomMaxValue
+	for cut > gedcomMaxValue/2 && !isSplit(cut) {
+		cut--
+	}
+	if cut == gedcomMaxValue/2 {
+		cut = gedcomMaxValue
+		for !utf8.RuneStart(value[cut]) || (value[cut] == '@' && strings.Count(value[:cut], "@")%2 == 1) {
+			cut--
+		}
+	}
+	return value[:cut], value[cut:]
+}
+
+func formatGedcomDate(date time.Time) string {
+	return strings.ToUpper(date.Format("2 Jan 2006"))
+}
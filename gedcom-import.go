@@ -0,0 +1,480 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	GedcomActionCreate = "create"
+	GedcomActionMatch  = "match"
+	GedcomActionSkip   = "skip"
+)
+
+type GedcomPersonResult struct {
+	XRef      string
+	Name      string
+	Action    string
+	Reason    string
+	PersonId  int
+	BirthCity *CityLite
+	HomeCity  *CityLite
+	Tags      []string
+	data      PersonData
+	motherRef string
+	fatherRef string
+}
+
+type GedcomSpouseResult struct {
+	XRef        string
+	Person1     string
+	Person2     string
+	Status      int
+	MarriedDate string
+	Action      string
+	Reason      string
+	person1Ref  string
+	person2Ref  string
+}
+
+type GedcomImportReport struct {
+	DryRun   bool
+	People   []GedcomPersonResult
+	Spouses  []GedcomSpouseResult
+	Warnings []string
+	// Approximate dates, and what they were stored as
+	Estimated []string
+}
+
+func (r *GedcomImportReport) CountPeople(action string) int {
+	count := 0
+	for _, item := range r.People {
+		if item.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *GedcomImportReport) CountSpouses(action string) int {
+	count := 0
+	for _, item := range r.Spouses {
+		if item.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *GedcomImportReport) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (r *GedcomImportReport) estimated(format string, args ...interface{}) {
+	r.Estimated = append(r.Estimated, fmt.Sprintf(format, args...))
+}
+
+// Import the INDI and FAM records of a GEDCOM file.  People are matched
+// against existing rows by name and birth date; when dryRun is set nothing is
+// written and the report describes what would have been done.  Everything is
+// written in one transaction, so a file that fails part way imports nothing.
+// The _TAG and _STAT tags our own export writes bring back tags and spouse
+// statuses.
+func ImportGedcom(store Store, reader io.Reader, dryRun bool) (*GedcomImportReport, error) {
+	defer trace(traceName(fmt.Sprintf("ImportGedcom(dryRun: %v)", dryRun)))
+
+	records, err := ParseGedcom(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	report := GedcomImportReport{DryRun: dryRun}
+	cities := gedcomCityMatcher{store: store, cache: make(map[string]*CityLite)}
+	personIndex := make(map[string]int)
+
+	for _, record := range records {
+		if record.Tag != "INDI" {
+			continue
+		}
+		item, err := planGedcomPerson(store, &cities, &report, record)
+		if err != nil {
+			return nil, err
+		}
+		personIndex[item.XRef] = len(report.People)
+		report.People = append(report.People, *item)
+	}
+
+	for _, record := range records {
+		if record.Tag != "FAM" {
+			continue
+		}
+		husbandRef := record.ChildValue("HUSB")
+		wifeRef := record.ChildValue("WIFE")
+
+		for _, child := range record.ChildrenWithTag("CHIL") {
+			index, found := personIndex[child.Value]
+			if !found {
+				report.warn("Family %s refers to unknown child %s", record.XRef, child.Value)
+				continue
+			}
+			report.People[index].motherRef = wifeRef
+			report.People[index].fatherRef = husbandRef
+		}
+
+		if husbandRef == "" || wifeRef == "" {
+			continue
+		}
+		spouse, err := planGedcomSpouse(store, &report, personIndex, record, husbandRef, wifeRef)
+		if err != nil {
+			return nil, err
+		}
+		report.Spouses = append(report.Spouses, *spouse)
+	}
+
+	if dryRun {
+		return &report, nil
+	}
+	err = store.InTransaction(func(tx Store) error {
+		return applyGedcomImport(tx, &report, personIndex)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%v, nothing was imported", err)
+	}
+	return &report, nil
+}
+
+func planGedcomPerson(store Store, cities *gedcomCityMatcher, report *GedcomImportReport, record *GedcomRecord) (*GedcomPersonResult, error) {
+	item := GedcomPersonResult{XRef: record.XRef}
+	data := &item.data
+
+	nameRecord := record.Child("NAME")
+	if nameRecord != nil {
+		given, surname := ParseGedcomName(nameRecord.Value)
+		if value := nameRecord.ChildValue("GIVN"); value != "" {
+			given = value
+		}
+		if value := nameRecord.ChildValue("SURN"); value != "" {
+			surname = value
+		}
+		givenNames := strings.Fields(given)
+		if len(givenNames) > 0 {
+			data.FirstName = givenNames[0]
+			data.MiddleName = strings.Join(givenNames[1:], " ")
+		}
+		data.LastName = surname
+		data.NickName = nameRecord.ChildValue("NICK")
+	}
+	item.Name = BuildFullName(data.FirstName, data.MiddleName, data.LastName, data.NickName)
+	if data.FirstName == "" {
+		item.Action = GedcomActionSkip
+		item.Reason = "missing given name"
+		return &item, nil
+	}
+
+	switch strings.ToUpper(record.ChildValue("SEX")) {
+	case "M":
+		data.Gender = "M"
+	case "F":
+		data.Gender = "F"
+	default:
+		data.Gender = "U"
+	}
+
+	data.IsAlive = record.Child("DEAT") == nil
+	if birth := record.Child("BIRT"); birth != nil {
+		if value := birth.ChildValue("DATE"); value != "" {
+			date, isGuess, ok := ParseGedcomDate(value)
+			if ok {
+				data.BirthDate = date
+				data.IsBirthYearGuess = isGuess
+				if isGuess {
+					report.estimated("%s (%s) birth date %s stored as %s, with the year marked as a guess", item.Name, item.XRef, value, date)
+				}
+			} else {
+				report.warn("%s (%s) has a birth date that isn't a whole day, month and year, so it was left out: %s", item.Name, item.XRef, value)
+			}
+		}
+		if place := birth.ChildValue("PLAC"); place != "" {
+			city, err := cities.Match(report, place)
+			if err != nil {
+				return nil, err
+			}
+			if city != nil {
+				item.BirthCity = city
+				data.BirthCityId = city.Id
+			}
+		}
+	}
+	if death := record.Child("DEAT"); death != nil {
+		if value := death.ChildValue("DATE"); value != "" {
+			date, isGuess, ok := ParseGedcomDate(value)
+			if ok {
+				data.DeathDate = date
+				data.IsDeathYearGuess = isGuess
+				if isGuess {
+					report.estimated("%s (%s) death date %s stored as %s, with the year marked as a guess", item.Name, item.XRef, value, date)
+				}
+			} else {
+				report.warn("%s (%s) has a death date that isn't a whole day, month and year, so it was left out: %s", item.Name, item.XRef, value)
+			}
+		}
+		if place := death.ChildValue("PLAC"); place != "" {
+			city, err := cities.Match(report, place)
+			if err != nil {
+				return nil, err
+			}
+			if city != nil {
+				data.DeathCityId = city.Id
+			}
+		}
+	}
+	if burial := record.Child("BURI"); burial != nil {
+		if place := burial.ChildValue("PLAC"); place != "" {
+			city, err := cities.Match(report, place)
+			if err != nil {
+				return nil, err
+			}
+			if city != nil {
+				data.BurialCityId = city.Id
+			}
+		}
+	}
+	residences := record.ChildrenWithTag("RESI")
+	if len(residences) > 0 {
+		if place := residences[len(residences)-1].ChildValue("PLAC"); place != "" {
+			city, err := cities.Match(report, place)
+			if err != nil {
+				return nil, err
+			}
+			if city != nil {
+				item.HomeCity = city
+				data.HomeCityId = city.Id
+			}
+		}
+	}
+
+	for _, tag := range record.ChildrenWithTag("_TAG") {
+		if label := strings.TrimSpace(tag.Value); label != "" {
+			item.Tags = append(item.Tags, label)
+		}
+	}
+
+	matches, err := store.LoadPersonLiteListByName(data.FirstName, data.LastName, data.BirthDate)
+	if err != nil {
+		return nil, err
+	}
+	switch len(matches) {
+	case 0:
+		item.Action = GedcomActionCreate
+	case 1:
+		item.Action = GedcomActionMatch
+		item.PersonId = matches[0].Id
+		item.Reason = fmt.Sprintf("matches existing person %d", matches[0].Id)
+	default:
+		item.Action = GedcomActionSkip
+		item.Reason = fmt.Sprintf("ambiguous, matches %d existing people", len(matches))
+	}
+	return &item, nil
+}
+
+func planGedcomSpouse(store Store, report *GedcomImportReport, personIndex map[string]int, record *GedcomRecord, husbandRef string, wifeRef string) (*GedcomSpouseResult, error) {
+	item := GedcomSpouseResult{
+		XRef:       record.XRef,
+		Status:     1,
+		person1Ref: husbandRef,
+		person2Ref: wifeRef,
+	}
+	if record.Child("DIV") != nil {
+		item.Status = 3
+	}
+	// Status written by our own export
+	switch strings.ToUpper(record.ChildValue("_STAT")) {
+	case "MARRIED":
+		item.Status = 1
+	case "DATING":
+		item.Status = 2
+	case "EX-MARRIED":
+		item.Status = 3
+	}
+	if marriage := record.Child("MARR"); marriage != nil {
+		if value := marriage.ChildValue("DATE"); value != "" {
+			date, isGuess, ok := ParseGedcomDate(value)
+			if ok {
+				item.MarriedDate = date
+				if isGuess {
+					report.estimated("Family %s marriage date %s stored as %s", record.XRef, value, date)
+				}
+			} else {
+				report.warn("Family %s has a marriage date that isn't a whole day, month and year, so it was left out: %s", record.XRef, value)
+			}
+		}
+	}
+
+	husbandIndex, husbandFound := personIndex[husbandRef]
+	wifeIndex, wifeFound := personIndex[wifeRef]
+	if !husbandFound || !wifeFound {
+		item.Action = GedcomActionSkip
+		item.Reason = "refers to an unknown person"
+		return &item, nil
+	}
+	husband := report.People[husbandIndex]
+	wife := report.People[wifeIndex]
+	item.Person1 = husband.Name
+	item.Person2 = wife.Name
+
+	if husband.Action == GedcomActionSkip || wife.Action == GedcomActionSkip {
+		item.Action = GedcomActionSkip
+		item.Reason = "one of the spouses is skipped"
+		return &item, nil
+	}
+	if husband.Action == GedcomActionMatch && wife.Action == GedcomActionMatch {
+		exists, err := store.SpouseExists(husband.PersonId, wife.PersonId)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			item.Action = GedcomActionMatch
+			item.Reason = "already recorded as spouses"
+			return &item, nil
+		}
+	}
+	item.Action = GedcomActionCreate
+	return &item, nil
+}
+
+func applyGedcomImport(store Store, report *GedcomImportReport, personIndex map[string]int) error {
+	// Insert everyone first so that parents can refer to people later in the file
+	for i := range report.People {
+		item := &report.People[i]
+		if item.Action != GedcomActionCreate {
+			continue
+		}
+		personId, err := store.InsertPerson(item.data)
+		if err != nil {
+			return fmt.Errorf("Error inserting %s (%s): %v", item.Name, item.XRef, err)
+		}
+		item.PersonId = personId
+	}
+
+	lookupId := func(xref string) int {
+		index, found := personIndex[xref]
+		if !found {
+			return 0
+		}
+		return report.People[index].PersonId
+	}
+
+	for _, item := range report.People {
+		if item.Action != GedcomActionCreate {
+			continue
+		}
+		motherId := lookupId(item.motherRef)
+		fatherId := lookupId(item.fatherRef)
+		problems, err := checkPersonParents(store, item.PersonId, motherId, fatherId)
+		if err != nil {
+			return fmt.Errorf("Error checking parents of %s (%s): %v", item.Name, item.XRef, err)
+		}
+		if problem, found := problems["MotherId"]; found {
+			report.warn("%s (%s) was given no mother: %s", item.Name, item.XRef, problem)
+			motherId = 0
+		}
+		if problem, found := problems["FatherId"]; found {
+			report.warn("%s (%s) was given no father: %s", item.Name, item.XRef, problem)
+			fatherId = 0
+		}
+		if motherId == 0 && fatherId == 0 {
+			continue
+		}
+		err = store.UpdatePersonParents(item.PersonId, motherId, fatherId)
+		if err != nil {
+			return fmt.Errorf("Error setting parents of %s (%s): %v", item.Name, item.XRef, err)
+		}
+	}
+
+	for _, item := range report.People {
+		if item.Action == GedcomActionSkip {
+			continue
+		}
+		for _, label := range item.Tags {
+			tag, err := store.LoadTagByLabel(label)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("Error loading tag %s: %v", label, err)
+			}
+			if tag == nil {
+				tag, err = store.InsertTag(label)
+				if err != nil {
+					return fmt.Errorf("Error inserting tag %s: %v", label, err)
+				}
+			}
+			err = store.InsertPeopleTag(tag.Id, item.PersonId)
+			if err != nil {
+				return fmt.Errorf("Error tagging %s (%s): %v", item.Name, item.XRef, err)
+			}
+		}
+	}
+
+	for _, item := range report.Spouses {
+		if item.Action != GedcomActionCreate {
+			continue
+		}
+		err := store.InsertSpouse(lookupId(item.person1Ref), lookupId(item.person2Ref), item.Status, item.MarriedDate)
+		if err != nil {
+			return fmt.Errorf("Error inserting spouses %s: %v", item.XRef, err)
+		}
+	}
+	return nil
+}
+
+// Matches GEDCOM places ("City, Region, Country") to rows in city_view
+type gedcomCityMatcher struct {
+	store Store
+	cache map[string]*CityLite
+}
+
+func (m *gedcomCityMatcher) Match(report *GedcomImportReport, place string) (*CityLite, error) {
+	if city, found := m.cache[place]; found {
+		return city, nil
+	}
+
+	var parts []string
+	for _, part := range strings.Split(place, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	candidates, err := m.store.LoadCitiesByName(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// Narrow down by the region and country codes when there's more than one
+	if len(candidates) > 1 {
+		var filtered []CityLite
+		for _, city := range candidates {
+			for _, part := range parts[1:] {
+				if strings.EqualFold(part, city.RegionAbbr) || strings.EqualFold(part, city.CountryAbbr) {
+					filtered = append(filtered, city)
+					break
+				}
+			}
+		}
+		candidates = filtered
+	}
+
+	var city *CityLite
+	switch len(candidates) {
+	case 0:
+		report.warn("No city found for place: %s", place)
+	case 1:
+		city = &candidates[0]
+	default:
+		report.warn("More than one city found for place: %s", place)
+	}
+	m.cache[place] = city
+	return city, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+func personImportGedcom(w http.ResponseWriter, r *http.Request) {
+	var report *GedcomImportReport
+	if r.Method == "POST" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error reading uploaded file: %v", err), 400)
+			return
+		}
+		defer file.Close()
+
+		report, err = ImportGedcom(store, file, r.FormValue("dry_run") == "1")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error importing GEDCOM: %v", err), 500)
+			return
+		}
+	}
+
+	renderTemplate(w, r, "person/import.html", report)
+}
+
+func personExportGedcom(w http.ResponseWriter, r *http.Request) {
+	var options GedcomExportOptions
+	var err error
+	if r.FormValue("root") != "" {
+		options.RootId, err = strconv.Atoi(r.FormValue("root"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Could not parse root: %v", err), 400)
+			return
+		}
+	}
+	if r.FormValue("depth") != "" {
+		options.Depth, err = strconv.Atoi(r.FormValue("depth"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Could not parse depth: %v", err), 400)
+			return
+		}
+	}
+	options.TagsAsNotes = r.FormValue("tags") == "note"
+
+	// Render into a buffer so errors can still be reported with a status code
+	var buf bytes.Buffer
+	err = ExportGedcom(store, &buf, options)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error exporting GEDCOM: %v", err), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"family.ged\"")
+	w.Write(buf.Bytes())
+}
+
+func addGedcomRoutes() {
+	http.HandleFunc("/person/import/gedcom", requireRole(RoleEditor, personImportGedcom))
+	http.HandleFunc("/person/export/gedcom", requireRole(RoleViewer, personExportGedcom))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A single GEDCOM line along with the lines nested beneath it
+type GedcomRecord struct {
+	Level    int
+	XRef     string
+	Tag      string
+	Value    string
+	Children []*GedcomRecord
+}
+
+var gedcomMonths = map[string]time.Month{
+	"JAN": time.January,
+	"FEB": time.February,
+	"MAR": time.March,
+	"APR": time.April,
+	"MAY": time.May,
+	"JUN": time.June,
+	"JUL": time.July,
+	"AUG": time.August,
+	"SEP": time.September,
+	"OCT": time.October,
+	"NOV": time.November,
+	"DEC": time.December,
+}
+
+// Find the first child record with the given tag, or nil if there is none
+func (g *GedcomRecord) Child(tag string) *GedcomRecord {
+	for _, child := range g.Children {
+		if child.Tag == tag {
+			return child
+		}
+	}
+	return nil
+}
+
+// Find all of the child records with the given tag
+func (g *GedcomRecord) ChildrenWithTag(tag string) []*GedcomRecord {
+	var list []*GedcomRecord
+	for _, child := range g.Children {
+		if child.Tag == tag {
+			list = append(list, child)
+		}
+	}
+	return list
+}
+
+// Value of the first child record with the given tag, or "" if there is none
+func (g *GedcomRecord) ChildValue(tag string) string {
+	child := g.Child(tag)
+	if child == nil {
+		return ""
+	}
+	return child.Value
+}
+
+// Parse a GEDCOM 5.5.1 stream into its top level (level 0) records
+func ParseGedcom(reader io.Reader) ([]*GedcomRecord, error) {
+	var roots []*GedcomRecord
+	var stack []*GedcomRecord
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		// Trailing spaces are kept since they matter when joining CONC lines
+		line := strings.TrimLeft(strings.TrimRight(scanner.Text(), "\r\n"), " \t")
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		record, err := parseGedcomLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("GEDCOM line %d: %v", lineNumber, err)
+		}
+
+		if record.Level > len(stack) {
+			return nil, fmt.Errorf("GEDCOM line %d: level %d skips a level", lineNumber, record.Level)
+		}
+		stack = stack[:record.Level]
+
+		// Continuation lines are folded into the value of their parent
+		if len(stack) > 0 && (record.Tag == "CONC" || record.Tag == "CONT") {
+			parent := stack[len(stack)-1]
+			if record.Tag == "CONT" {
+				parent.Value += "\n"
+			}
+			parent.Value += record.Value
+			continue
+		}
+
+		if record.Level == 0 {
+			roots = append(roots, record)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, record)
+		}
+		stack = append(stack, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+func parseGedcomLine(line string) (*GedcomRecord, error) {
+	parts := strings.SplitN(line, " ", 2)
+	level, err := strconv.Atoi(parts[0])
+	if err != nil || level < 0 {
+		return nil, fmt.Errorf("invalid level: %s", parts[0])
+	}
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("missing tag")
+	}
+	record := GedcomRecord{Level: level}
+	rest := parts[1]
+	if strings.HasPrefix(rest, "@") {
+		end := strings.Index(rest[1:], "@")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated cross reference: %s", rest)
+		}
+		record.XRef = rest[:end+2]
+		rest = strings.TrimLeft(rest[end+2:], " ")
+	}
+	parts = strings.SplitN(rest, " ", 2)
+	record.Tag = strings.ToUpper(parts[0])
+	if len(parts) > 1 {
+		// A literal @ in a value is written as @@
+		record.Value = strings.ReplaceAll(parts[1], "@@", "@")
+	}
+	if record.Tag == "" {
+		return nil, fmt.Errorf("missing tag")
+	}
+	return &record, nil
+}
+
+// Split a GEDCOM personal name ("John Robert /Smith/") into its given names and surname
+func ParseGedcomName(value string) (string, string) {
+	start := strings.Index(value, "/")
+	if start < 0 {
+		return strings.TrimSpace(value), ""
+	}
+	given := strings.TrimSpace(value[:start])
+	surname := value[start+1:]
+	end := strings.Index(surname, "/")
+	if end >= 0 {
+		surname = surname[:end]
+	}
+	return given, strings.TrimSpace(surname)
+}
+
+// Parse a GEDCOM date value into a "2006-01-02" date string.  Approximate dates
+// ("ABT 3 MAR 1950") set isGuess.  Dates missing a day or month ("1950",
+// "ABT MAR 1950") aren't ok, as a made up day would show up as a real
+// birthday or anniversary.
+func ParseGedcomDate(value string) (date string, isGuess bool, ok bool) {
+	words := strings.Fields(strings.ToUpper(value))
+	if len(words) == 0 {
+		return "", false, false
+	}
+	switch words[0] {
+	case "ABT", "CAL", "EST", "BEF", "AFT":
+		isGuess = true
+		words = words[1:]
+	case "BET", "FROM":
+		// Ranges use the first date of the range
+		isGuess = true
+		words = words[1:]
+		for i, word := range words {
+			if word == "AND" || word == "TO" {
+				words = words[:i]
+				break
+			}
+		}
+	}
+	if len(words) != 3 {
+		return "", isGuess, false
+	}
+	year, err := strconv.Atoi(words[2])
+	if err != nil {
+		return "", isGuess, false
+	}
+	month, found := gedcomMonths[words[1]]
+	if !found {
+		return "", isGuess, false
+	}
+	day, err := strconv.Atoi(words[0])
+	if err != nil {
+		return "", isGuess, false
+	}
+	parsed := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if parsed.Day() != day {
+		return "", isGuess, false
+	}
+	return parsed.Format("2006-01-02"), isGuess, true
+}
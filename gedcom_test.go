@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGedcomDate(t *testing.T) {
+	cases := []struct {
+		value   string
+		date    string
+		isGuess bool
+		ok      bool
+	}{
+		{"3 MAR 1950", "1950-03-03", false, true},
+		{"3 mar 1950", "1950-03-03", false, true},
+		{"ABT 3 MAR 1950", "1950-03-03", true, true},
+		{"BEF 29 FEB 2000", "2000-02-29", true, true},
+		{"BET 1 JAN 1900 AND 31 DEC 1905", "1900-01-01", true, true},
+		{"FROM 5 JUN 1944 TO 8 MAY 1945", "1944-06-05", true, true},
+		// Partial dates are left out rather than given a made up day
+		{"1950", "", false, false},
+		{"MAR 1950", "", false, false},
+		{"ABT MAR 1950", "", true, false},
+		{"BET 1900 AND 1905", "", true, false},
+		{"29 FEB 1999", "", false, false},
+		{"32 JAN 1950", "", false, false},
+		{"3 MARCH 1950", "", false, false},
+		{"", "", false, false},
+	}
+	for _, c := range cases {
+		date, isGuess, ok := ParseGedcomDate(c.value)
+		if date != c.date || isGuess != c.isGuess || ok != c.ok {
+			t.Errorf("ParseGedcomDate(%q) = %q, %v, %v, want %q, %v, %v", c.value, date, isGuess, ok, c.date, c.isGuess, c.ok)
+		}
+	}
+}
+
+func TestImportGedcomLeavesOutPartialDates(t *testing.T) {
+	store := NewMemoryStore()
+	file := strings.Join([]string{
+		"0 HEAD",
+		"0 @I1@ INDI",
+		"1 NAME Ann /Tester/",
+		"1 SEX F",
+		"1 BIRT",
+		"2 DATE ABT 1950",
+		"1 DEAT",
+		"2 DATE ABT 4 JUL 2010",
+		"0 TRLR",
+	}, "\n")
+	report, err := ImportGedcom(store, strings.NewReader(file), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.People) != 1 || report.People[0].PersonId == 0 {
+		t.Fatalf("Expected Ann to be created: %+v", report.People)
+	}
+	person, err := store.LoadPersonDataById(report.People[0].PersonId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person.BirthDate != "" {
+		t.Errorf("Birth date ABT 1950 was stored as %s", person.BirthDate)
+	}
+	if person.DeathDate != "2010-07-04" || !person.IsDeathYearGuess {
+		t.Errorf("Death date ABT 4 JUL 2010 was stored as %s, guess %v", person.DeathDate, person.IsDeathYearGuess)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], "ABT 1950") {
+		t.Errorf("Expected a warning about the birth date: %v", report.Warnings)
+	}
+}
+
+func TestFormatGedcomDate(t *testing.T) {
+	cases := []struct {
+		date  string
+		value string
+	}{
+		{"1950-03-03", "3 MAR 1950"},
+		{"2000-02-29", "29 FEB 2000"},
+		{"1899-12-31", "31 DEC 1899"},
+	}
+	for _, c := range cases {
+		date, err := time.Parse("2006-01-02", c.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := formatGedcomDate(date)
+		if value != c.value {
+			t.Errorf("formatGedcomDate(%s) = %q, want %q", c.date, value, c.value)
+		}
+		// What's written reads back as the same day
+		parsed, isGuess, ok := ParseGedcomDate(value)
+		if parsed != c.date || isGuess || !ok {
+			t.Errorf("ParseGedcomDate(%q) = %q, %v, %v", value, parsed, isGuess, ok)
+		}
+	}
+}
+
+func TestWriteGedcomText(t *testing.T) {
+	long := strings.Repeat("x", gedcomMaxValue+50)
+	words := strings.Repeat("word ", 60) + "end"
+	cases := []struct {
+		value string
+		lines string
+	}{
+		{"Plain", "1 NOTE Plain"},
+		{"", "1 NOTE"},
+		{"me@example.com", "1 NOTE me@@example.com"},
+		{"One\nTwo\n\nFour", "1 NOTE One\n2 CONT Two\n2 CONT\n2 CONT Four"},
+		{long, "1 NOTE " + long[:gedcomMaxValue] + "\n2 CONC " + long[gedcomMaxValue:]},
+		// Long values aren't split next to a space or inside a character
+		{words, ""},
+		{strings.Repeat("é", gedcomMaxValue), ""},
+		{strings.Repeat("a@", gedcomMaxValue/2), ""},
+	}
+	for _, c := range cases {
+		var out strings.Builder
+		writeGedcomText(&out, 1, "NOTE", c.value)
+		lines := strings.TrimSuffix(out.String(), "\n")
+		if c.lines != "" && lines != c.lines {
+			t.Errorf("writeGedcomText(%q) wrote\n%s\nwant\n%s", c.value, lines, c.lines)
+		}
+		for _, line := range strings.Split(lines, "\n") {
+			if len(line) > 255 || strings.HasSuffix(line, " ") {
+				t.Errorf("writeGedcomText(%q) wrote the line %q", c.value, line)
+			}
+		}
+
+		records, err := ParseGedcom(strings.NewReader("0 @N1@ NOTE\n" + out.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value := records[0].ChildValue("NOTE"); value != c.value {
+			t.Errorf("writeGedcomText(%q) reads back as %q", c.value, value)
+		}
+	}
+}
+
+func TestExportGedcom(t *testing.T) {
+	store := NewMemoryStore()
+	ann := insertTestPerson(t, store, PersonData{FirstName: "Ann", LastName: "Tester", Gender: "F", BirthDate: "1950-03-03", IsBirthYearGuess: true, DeathDate: "2010-07-04"})
+	bob := insertTestPerson(t, store, PersonData{FirstName: "Bob", MiddleName: "Robert", LastName: "Tester", Gender: "M", IsAlive: true, BirthDate: "1948-01-02"})
+	err := store.InsertSpouse(bob, ann, 1, "1970-06-07")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := insertTestPerson(t, store, PersonData{FirstName: "Cal", LastName: "Tester", Gender: "M", IsAlive: true, BirthDate: "1972-02-29", FatherId: bob, MotherId: ann})
+	childWife := insertTestPerson(t, store, PersonData{FirstName: "Dee", LastName: "Other", Gender: "F", IsAlive: true})
+	err = store.InsertSpouse(childWife, child, 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grandchild := insertTestPerson(t, store, PersonData{FirstName: "Eve", LastName: "Tester", Gender: "F", IsAlive: true, FatherId: child, MotherId: childWife})
+	uncle := insertTestPerson(t, store, PersonData{FirstName: "Fred", LastName: "Tester", Gender: "M"})
+	tag, err := store.InsertTag("Favorites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.InsertPeopleTag(tag.Id, ann)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	err = ExportGedcom(store, &out, GedcomExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := out.String()
+	for _, lines := range []string{
+		fmt.Sprintf("0 @I%d@ INDI\n1 NAME Ann /Tester/\n2 GIVN Ann\n2 SURN Tester\n1 SEX F\n1 BIRT\n2 DATE ABT 3 MAR 1950\n1 DEAT\n2 DATE 4 JUL 2010\n", ann),
+		fmt.Sprintf("0 @I%d@ INDI\n1 NAME Bob Robert /Tester/\n2 GIVN Bob Robert\n2 SURN Tester\n1 SEX M\n1 BIRT\n2 DATE 2 JAN 1948\n1 FAMS ", bob),
+		"1 BIRT\n2 DATE 29 FEB 1972\n",
+		fmt.Sprintf("0 @I%d@ INDI\n1 NAME Fred /Tester/\n2 GIVN Fred\n2 SURN Tester\n1 SEX M\n1 DEAT Y\n", uncle),
+		"1 _TAG Favorites\n",
+		fmt.Sprintf("1 HUSB @I%d@\n1 WIFE @I%d@\n1 MARR\n2 DATE 7 JUN 1970\n1 _STAT MARRIED\n1 CHIL @I%d@\n", bob, ann, child),
+		fmt.Sprintf("1 HUSB @I%d@\n1 WIFE @I%d@\n1 MARR\n1 DIV Y\n1 _STAT EX-MARRIED\n1 CHIL @I%d@\n", child, childWife, grandchild),
+	} {
+		if !strings.Contains(file, lines) {
+			t.Errorf("Export is missing\n%s\nin\n%s", lines, file)
+		}
+	}
+
+	// What's exported imports again with the same dates
+	imported := NewMemoryStore()
+	report, err := ImportGedcom(imported, strings.NewReader(file), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("Importing the export warned: %v", report.Warnings)
+	}
+	births := make(map[string]string)
+	for _, result := range report.People {
+		person, err := imported.LoadPersonDataById(result.PersonId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		births[person.FirstName] = fmt.Sprintf("%s %v", person.BirthDate, person.IsBirthYearGuess)
+	}
+	for name, birth := range map[string]string{"Ann": "1950-03-03 true", "Bob": "1948-01-02 false", "Cal": "1972-02-29 false", "Eve": " false"} {
+		if births[name] != birth {
+			t.Errorf("%s was born %q after importing, want %q", name, births[name], birth)
+		}
+	}
+	if len(report.Spouses) != 2 {
+		t.Errorf("Importing the export found spouses %+v", report.Spouses)
+	}
+
+	cases := []struct {
+		options GedcomExportOptions
+		people  []int
+	}{
+		{GedcomExportOptions{RootId: child}, []int{child, childWife, grandchild}},
+		{GedcomExportOptions{RootId: bob, Depth: 1}, []int{ann, bob, child, childWife}},
+		{GedcomExportOptions{RootId: ann}, []int{ann, bob, child, childWife, grandchild}},
+		{GedcomExportOptions{RootId: uncle}, []int{uncle}},
+	}
+	for _, c := range cases {
+		var out strings.Builder
+		err := ExportGedcom(store, &out, c.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := ParseGedcom(strings.NewReader(out.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var people []string
+		for _, record := range records {
+			if record.Tag == "INDI" {
+				people = append(people, record.XRef)
+			}
+		}
+		var want []string
+		for _, id := range c.people {
+			want = append(want, fmt.Sprintf("@I%d@", id))
+		}
+		if strings.Join(people, " ") != strings.Join(want, " ") {
+			t.Errorf("Exporting with %+v gave %v, want %v", c.options, people, want)
+		}
+	}
+
+	err = ExportGedcom(store, &out, GedcomExportOptions{RootId: 9999})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Exporting from a missing person gave %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Longest a readiness check waits on the database
+const readyCheckTimeout = 2 * time.Second
+
+// Set once the server starts shutting down, so the load balancer stops
+// sending it requests while the ones in flight finish
+var shuttingDown atomic.Bool
+
+// The process is up and serving.  Kept at /health for older checks.
+func healthLive(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "OK")
+}
+
+// The server can handle requests: it isn't shutting down, and the database
+// answers and has every migration applied
+func healthReady(w http.ResponseWriter, r *http.Request) {
+	err := checkReady(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintf(w, "OK")
+}
+
+func checkReady(ctx context.Context) error {
+	if shuttingDown.Load() {
+		return fmt.Errorf("Shutting down")
+	}
+	if db == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+	err := db.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("Database unreachable: %v", err)
+	}
+	pending, err := countPendingMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("Error checking migrations: %v", err)
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations not applied", pending)
+	}
+	return nil
+}
+
+// Embedded migrations the database doesn't have yet.  Unlike
+// LoadMigrationStatus this only reads, as it runs on every check.
+func countPendingMigrations(ctx context.Context) (int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		err = rows.Scan(&version)
+		if err != nil {
+			return 0, err
+		}
+		applied[version] = true
+	}
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, migration := range migrations {
+		if !applied[migration.Version] {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func addHealthRoutes() {
+	http.HandleFunc("/health", healthLive)
+	http.HandleFunc("/health/live", healthLive)
+	http.HandleFunc("/health/ready", healthReady)
+}
@@ -2,27 +2,250 @@ package main
 
 import (
 	"fmt"
-	"html/template"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// A choice in one of the holiday form's drop downs
+type holidayOption struct {
+	Value int
+	Name  string
+}
+
 func holidayList(w http.ResponseWriter, r *http.Request) {
 	now := time.Now()
+	countryCode := r.FormValue("country")
 
-	holidays, err := LoadHolidaysByYear(db, now.Year())
+	years, err := LoadHolidaysByYear(store, now.Year(), countryCode)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading holidays: %v", err), 500)
+		return
+	}
+	definitions, err := store.LoadHolidayList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading holidays: %v", err), 500)
 		return
 	}
+	countries, err := store.LoadCountryList()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading countries: %v", err), 500)
+		return
+	}
+
+	data := struct {
+		Holidays    []HolidayDefinition
+		Years       []YearHolidays
+		Countries   []Country
+		CountryCode string
+	}{
+		Years:       years,
+		Countries:   countries,
+		CountryCode: countryCode,
+	}
+	for _, definition := range definitions {
+		if countryCode == "" || definition.CountryCode == "" || definition.CountryCode == countryCode {
+			data.Holidays = append(data.Holidays, definition)
+		}
+	}
 
 	// Output the result
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/holiday/list.html")).Execute(w, holidays)
+	renderTemplate(w, r, "holiday/list.html", data)
+}
+
+// Read the holiday from the add and edit forms.  Only the fields the chosen
+// rule uses are kept.
+func parseHolidayForm(r *http.Request) (*HolidayData, error) {
+	data := HolidayData{
+		Name:        strings.TrimSpace(r.FormValue("name")),
+		Rule:        HolidayRule(r.FormValue("rule")),
+		CountryCode: strings.TrimSpace(r.FormValue("country_code")),
+	}
+	var err error
+	formInt := func(name string) int {
+		if err != nil {
+			return 0
+		}
+		var value int
+		value, err = strconv.Atoi(strings.TrimSpace(r.FormValue(name)))
+		if err != nil {
+			err = fmt.Errorf("Invalid %s: %s", name, r.FormValue(name))
+		}
+		return value
+	}
+	switch data.Rule {
+	case HolidayRuleFixed:
+		data.Month = formInt("fixed_month")
+		data.Day = formInt("fixed_day")
+	case HolidayRuleWeekday:
+		data.Month = formInt("weekday_month")
+		data.Weekday = formInt("weekday")
+		data.Week = formInt("week")
+	case HolidayRuleEaster:
+		data.EasterOffset = formInt("easter_offset")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = data.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func renderHolidayForm(w http.ResponseWriter, r *http.Request, holiday *HolidayDefinition) {
+	countries, err := store.LoadCountryList()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading countries: %v", err), 500)
+		return
+	}
+	data := struct {
+		Holiday   *HolidayDefinition
+		Countries []Country
+		Months    []holidayOption
+		Weekdays  []holidayOption
+		Weeks     []holidayOption
+	}{
+		Holiday:   holiday,
+		Countries: countries,
+		Weeks: []holidayOption{
+			{1, "1st"}, {2, "2nd"}, {3, "3rd"}, {4, "4th"}, {5, "5th"}, {-1, "Last"},
+		},
+	}
+	for month := time.January; month <= time.December; month++ {
+		data.Months = append(data.Months, holidayOption{int(month), month.String()})
+	}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		data.Weekdays = append(data.Weekdays, holidayOption{int(weekday), weekday.String()})
+	}
+	renderTemplate(w, r, "holiday/edit.html", data)
+}
+
+func holidayAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		data, err := parseHolidayForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		holidayId, err := SaveHoliday(store, 0, *data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error creating holiday: %v", err), 500)
+			return
+		}
+		// Holidays with dates set by hand need them added next
+		if data.Rule == HolidayRuleDates {
+			http.Redirect(w, r, fmt.Sprintf("/holiday/edit/%d", holidayId), 302)
+			return
+		}
+		http.Redirect(w, r, "/holiday/list", 302)
+		return
+	}
+
+	renderHolidayForm(w, r, &HolidayDefinition{
+		HolidayData: HolidayData{Rule: HolidayRuleFixed, Month: 1, Day: 1, Week: 1},
+	})
+}
+
+func holidayEdit(w http.ResponseWriter, r *http.Request) {
+	holidayId, err := getIntPathParam(r, "holidayId", 3 /* index */)
 	if err != nil {
-		panic(err)
+		http.Error(w, fmt.Sprintf("Error parsing holidayId: %v", err), 400)
+		return
+	}
+
+	if r.Method == "POST" {
+		data, err := parseHolidayForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		_, err = SaveHoliday(store, holidayId, *data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error updating holiday: %v", err), 500)
+			return
+		}
+		http.Redirect(w, r, "/holiday/list", 302)
+		return
+	}
+
+	holiday, err := store.LoadHolidayById(holidayId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading holiday: %v", err), 500)
+		return
+	}
+	renderHolidayForm(w, r, holiday)
+}
+
+func holidayDelete(w http.ResponseWriter, r *http.Request) {
+	holidayId, err := getIntPathParam(r, "holidayId", 3 /* index */)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing holidayId: %v", err), 400)
+		return
+	}
+	err = store.DeleteHoliday(holidayId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting holiday (id: %d): %v", holidayId, err), 500)
+		return
+	}
+	http.Redirect(w, r, "/holiday/list", 302)
+}
+
+// Add a date to a holiday whose dates are set by hand
+func holidayDateAdd(w http.ResponseWriter, r *http.Request) {
+	holidayId, err := getIntPathParam(r, "holidayId", 4 /* index */)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing holidayId: %v", err), 400)
+		return
+	}
+	date, err := time.Parse("2006-01-02", r.FormValue("date"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing date: %v", err), 400)
+		return
+	}
+	holiday, err := store.LoadHolidayById(holidayId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading holiday: %v", err), 404)
+		return
+	}
+	if holiday.Rule != HolidayRuleDates {
+		http.Error(w, "The holiday's dates come from its rule", 400)
+		return
+	}
+	err = store.InsertHolidayItem(holidayId, date)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error adding holiday date: %v", err), 500)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/holiday/edit/%d", holidayId), 302)
+}
+
+func holidayDateDelete(w http.ResponseWriter, r *http.Request) {
+	holidayId, err := getIntPathParam(r, "holidayId", 4 /* index */)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing holidayId: %v", err), 400)
+		return
+	}
+	date, err := time.Parse("2006-01-02", r.FormValue("date"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing date: %v", err), 400)
+		return
+	}
+	err = store.DeleteHolidayItem(holidayId, date)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting holiday date: %v", err), 500)
+		return
 	}
+	http.Redirect(w, r, fmt.Sprintf("/holiday/edit/%d", holidayId), 302)
 }
 
 func addHolidayRoutes() {
-	http.HandleFunc("/holiday/list", holidayList)
+	http.HandleFunc("/holiday/list", requireRole(RoleViewer, holidayList))
+	http.HandleFunc("/holiday/add", requireRole(RoleEditor, holidayAdd))
+	http.HandleFunc("/holiday/edit/", requireRole(RoleEditor, holidayEdit))
+	http.HandleFunc("/holiday/delete/", requireRole(RoleAdmin, requirePost(holidayDelete)))
+	http.HandleFunc("/holiday/date/add/", requireRole(RoleEditor, requirePost(holidayDateAdd)))
+	http.HandleFunc("/holiday/date/delete/", requireRole(RoleEditor, requirePost(holidayDateDelete)))
 }
@@ -3,13 +3,15 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 )
 
 type Holiday struct {
-	Id   int
-	Date time.Time
-	Name string
+	Id          int
+	Date        time.Time
+	Name        string
+	CountryCode string
 }
 
 type YearHolidays struct {
@@ -17,16 +19,239 @@ type YearHolidays struct {
 	Holidays []Holiday
 }
 
-func LoadHolidaysByYear(db *sql.DB, startYear int) ([]YearHolidays, error) {
-	defer trace(traceName(fmt.Sprintf("LoadHolidaysByYear(%d)", startYear)))
+// How a holiday's dates are worked out
+type HolidayRule string
+
+const (
+	// Each date is added by hand
+	HolidayRuleDates HolidayRule = "dates"
+	// The same month and day every year, eg. December 25th
+	HolidayRuleFixed HolidayRule = "fixed"
+	// The nth weekday of a month, eg. the 4th Thursday of November
+	HolidayRuleWeekday HolidayRule = "weekday"
+	// A number of days from Easter Sunday, eg. 2 days before for Good Friday
+	HolidayRuleEaster HolidayRule = "easter"
+)
+
+var HolidayRules = []HolidayRule{HolidayRuleDates, HolidayRuleFixed, HolidayRuleWeekday, HolidayRuleEaster}
+
+// Easter offsets are limited so the holiday stays in the same year as Easter
+const (
+	minEasterOffset = -80
+	maxEasterOffset = 250
+)
+
+type HolidayData struct {
+	Name string
+	Rule HolidayRule
+	// Month and day for the fixed rule, and month for the weekday rule
+	Month int
+	Day   int
+	// Day of the week (0 is Sunday) and which one in the month (1 to 5, or
+	// -1 for the last) for the weekday rule
+	Weekday int
+	Week    int
+	// Days after Easter Sunday for the easter rule, negative for before
+	EasterOffset int
+	// Country the holiday is for, or empty when it's for everyone
+	CountryCode string
+}
+
+// A holiday along with all its dates
+type HolidayDefinition struct {
+	Id int
+	HolidayData
+	Dates []time.Time
+}
 
-	holidays, err := LoadHolidays(db, startYear)
+func (d *HolidayData) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("Empty name")
+	}
+	switch d.Rule {
+	case HolidayRuleDates:
+	case HolidayRuleFixed:
+		if d.Month < 1 || d.Month > 12 {
+			return fmt.Errorf("Invalid month: %d", d.Month)
+		}
+		// Checked against a leap year so February 29th is allowed
+		if d.Day < 1 || d.Day > daysInMonth(2000, time.Month(d.Month)) {
+			return fmt.Errorf("Invalid day: %d", d.Day)
+		}
+	case HolidayRuleWeekday:
+		if d.Month < 1 || d.Month > 12 {
+			return fmt.Errorf("Invalid month: %d", d.Month)
+		}
+		if d.Weekday < 0 || d.Weekday > 6 {
+			return fmt.Errorf("Invalid weekday: %d", d.Weekday)
+		}
+		if d.Week != -1 && (d.Week < 1 || d.Week > 5) {
+			return fmt.Errorf("Invalid week: %d", d.Week)
+		}
+	case HolidayRuleEaster:
+		if d.EasterOffset < minEasterOffset || d.EasterOffset > maxEasterOffset {
+			return fmt.Errorf("Days from Easter must be between %d and %d", minEasterOffset, maxEasterOffset)
+		}
+	default:
+		return fmt.Errorf("Invalid rule: %s", d.Rule)
+	}
+	return nil
+}
+
+// The date of the holiday in the given year, and false when its rule doesn't
+// give one, either because its dates are added by hand or because the year
+// doesn't have it (eg. February 29th or a 5th Monday)
+func (d *HolidayData) DateInYear(year int) (time.Time, bool) {
+	switch d.Rule {
+	case HolidayRuleFixed:
+		if d.Day > daysInMonth(year, time.Month(d.Month)) {
+			return time.Time{}, false
+		}
+		return time.Date(year, time.Month(d.Month), d.Day, 0, 0, 0, 0, time.UTC), true
+	case HolidayRuleWeekday:
+		return nthWeekday(year, time.Month(d.Month), time.Weekday(d.Weekday), d.Week)
+	case HolidayRuleEaster:
+		return easterSunday(year).AddDate(0, 0, d.EasterOffset), true
+	}
+	return time.Time{}, false
+}
+
+// Describe the rule, eg. "4th Thursday of November"
+func (d *HolidayData) RuleDescription() string {
+	switch d.Rule {
+	case HolidayRuleFixed:
+		return fmt.Sprintf("%s %d", time.Month(d.Month), d.Day)
+	case HolidayRuleWeekday:
+		week := "Last"
+		if d.Week > 0 {
+			week = ordinalNumber(d.Week)
+		}
+		return fmt.Sprintf("%s %s of %s", week, time.Weekday(d.Weekday), time.Month(d.Month))
+	case HolidayRuleEaster:
+		switch {
+		case d.EasterOffset == 0:
+			return "Easter Sunday"
+		case d.EasterOffset == 1:
+			return "1 day after Easter"
+		case d.EasterOffset == -1:
+			return "1 day before Easter"
+		case d.EasterOffset < 0:
+			return fmt.Sprintf("%d days before Easter", -d.EasterOffset)
+		default:
+			return fmt.Sprintf("%d days after Easter", d.EasterOffset)
+		}
+	}
+	return "Set dates"
+}
+
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// The nth weekday of the month, or the last one when n is -1
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) (time.Time, bool) {
+	if n == -1 {
+		last := time.Date(year, month, daysInMonth(year, month), 0, 0, 0, 0, time.UTC)
+		return last.AddDate(0, 0, -((int(last.Weekday()) - int(weekday) + 7) % 7)), true
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	day := 1 + (int(weekday)-int(first.Weekday())+7)%7 + (n-1)*7
+	if day > daysInMonth(year, month) {
+		return time.Time{}, false
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), true
+}
+
+// Western Easter Sunday, using the anonymous Gregorian algorithm
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// Add the dates of the rule based holidays for the years from startYear to
+// endYear that don't have them yet, so they load like hand added dates.  The
+// dates already there are checked in the store each time, as another server
+// may have added them or a rule change removed them.  Two servers adding the
+// same date at once is fine, as each date is only stored once.
+func ensureHolidayItems(store Store, startYear int, endYear int) error {
+	defer trace(traceName(fmt.Sprintf("ensureHolidayItems(%d, %d)", startYear, endYear)))
+
+	definitions, err := store.LoadHolidayList()
+	if err != nil {
+		return err
+	}
+	for _, definition := range definitions {
+		if definition.Rule == HolidayRuleDates {
+			continue
+		}
+		hasYear := make(map[int]bool)
+		for _, date := range definition.Dates {
+			hasYear[date.Year()] = true
+		}
+		for year := startYear; year <= endYear; year++ {
+			if hasYear[year] {
+				continue
+			}
+			date, ok := definition.DateInYear(year)
+			if !ok {
+				continue
+			}
+			err = store.InsertHolidayItem(definition.Id, date)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Save a holiday, replacing the dates a rule made when there is one
+func SaveHoliday(store Store, id int, data HolidayData) (int, error) {
+	var err error
+	if id == 0 {
+		id, err = store.InsertHoliday(data)
+	} else {
+		err = store.UpdateHoliday(id, data)
+		if err == nil && data.Rule != HolidayRuleDates {
+			err = store.DeleteHolidayItems(id)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Load the holidays from the start of startYear on, grouped by year.  When
+// countryCode isn't empty only the holidays for everyone and that country
+// are included.
+func LoadHolidaysByYear(store Store, startYear int, countryCode string) ([]YearHolidays, error) {
+	defer trace(traceName(fmt.Sprintf("LoadHolidaysByYear(%d, %s)", startYear, countryCode)))
+
+	err := ensureHolidayItems(store, startYear, startYear+1)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := store.LoadHolidays(startYear)
 	if err != nil {
 		return nil, err
 	}
 	var result []YearHolidays
 	var year *YearHolidays
-	for _, value := range holidays {
+	for _, value := range filterHolidaysByCountry(holidays, countryCode) {
 		if year == nil || year.Year != value.Date.Year() {
 			result = append(result, YearHolidays{})
 			year = &result[len(result)-1]
@@ -37,10 +262,25 @@ func LoadHolidaysByYear(db *sql.DB, startYear int) ([]YearHolidays, error) {
 	return result, nil
 }
 
-func LoadHolidays(db *sql.DB, startYear int) ([]Holiday, error) {
+// Keep the holidays for everyone and for countryCode, or all of them when
+// countryCode is empty
+func filterHolidaysByCountry(holidays []Holiday, countryCode string) []Holiday {
+	if countryCode == "" {
+		return holidays
+	}
+	var result []Holiday
+	for _, value := range holidays {
+		if value.CountryCode == "" || value.CountryCode == countryCode {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func (s *MySqlStore) LoadHolidays(startYear int) ([]Holiday, error) {
 	defer trace(traceName(fmt.Sprintf("LoadHolidays(%d)", startYear)))
-	rows, err := db.Query(
-		"SELECT h.id, hi.date, h.name"+
+	rows, err := s.db.Query(
+		"SELECT h.id, hi.date, h.name, h.country_code"+
 			" FROM holidays h "+
 			" INNER JOIN holiday_items hi ON h.id = hi.holiday_id"+
 			" WHERE hi.date >=?"+
@@ -53,6 +293,24 @@ func LoadHolidays(db *sql.DB, startYear int) ([]Holiday, error) {
 	return readHolidaysFromRows(rows)
 }
 
+func (s *MySqlStore) LoadHolidaysInRange(startTime time.Time, endTime time.Time) ([]Holiday, error) {
+	defer trace(traceName(fmt.Sprintf("LoadHolidaysInRange(%v, %v)", startTime, endTime)))
+
+	rows, err := s.db.Query(
+		"SELECT h.id, hi.date, h.name, h.country_code"+
+			" FROM holidays h"+
+			" INNER JOIN holiday_items hi ON hi.holiday_id = h.id"+
+			" WHERE hi.date >= ? AND hi.date < ?"+
+			" ORDER BY hi.date ASC",
+		startTime.Format("2006-01-02"), endTime.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return readHolidaysFromRows(rows)
+}
+
 func readHolidaysFromRows(rows *sql.Rows) ([]Holiday, error) {
 	var holidays []Holiday
 	for rows.Next() {
@@ -68,10 +326,174 @@ func readHolidaysFromRows(rows *sql.Rows) ([]Holiday, error) {
 func readHolidayFromRows(rows *sql.Rows) (*Holiday, error) {
 	var holiday Holiday
 	var holidayDateString string
-	err := rows.Scan(&holiday.Id, &holidayDateString, &holiday.Name)
+	var countryCode sql.NullString
+	err := rows.Scan(&holiday.Id, &holidayDateString, &holiday.Name, &countryCode)
 	if err != nil {
 		return nil, err
 	}
 	holiday.Date, _ = time.Parse("2006-01-02", holidayDateString)
+	holiday.CountryCode = countryCode.String
 	return &holiday, nil
 }
+
+// Load every holiday with its dates, ordered by name
+func (s *MySqlStore) LoadHolidayList() ([]HolidayDefinition, error) {
+	defer trace(traceName("LoadHolidayList"))
+	rows, err := s.db.Query(
+		"SELECT id, name, rule, month, day, weekday, week, easter_offset, country_code" +
+			" FROM holidays" +
+			" ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var definitions []HolidayDefinition
+	indexes := make(map[int]int)
+	for rows.Next() {
+		definition, err := readHolidayDefinitionFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		indexes[definition.Id] = len(definitions)
+		definitions = append(definitions, *definition)
+	}
+	rows.Close()
+
+	itemRows, err := s.db.Query("SELECT holiday_id, date FROM holiday_items ORDER BY date")
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var holidayId int
+		var dateString string
+		err = itemRows.Scan(&holidayId, &dateString)
+		if err != nil {
+			return nil, err
+		}
+		index, found := indexes[holidayId]
+		if !found {
+			continue
+		}
+		date, _ := time.Parse("2006-01-02", dateString)
+		definitions[index].Dates = append(definitions[index].Dates, date)
+	}
+	return definitions, nil
+}
+
+func (s *MySqlStore) LoadHolidayById(id int) (*HolidayDefinition, error) {
+	defer trace(traceName(fmt.Sprintf("LoadHolidayById(%d)", id)))
+	rows, err := s.db.Query(
+		"SELECT id, name, rule, month, day, weekday, week, easter_offset, country_code"+
+			" FROM holidays"+
+			" WHERE id=?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, notFoundf("Holiday not found. Id: %d", id)
+	}
+	definition, err := readHolidayDefinitionFromRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	itemRows, err := s.db.Query("SELECT date FROM holiday_items WHERE holiday_id=? ORDER BY date", id)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var dateString string
+		err = itemRows.Scan(&dateString)
+		if err != nil {
+			return nil, err
+		}
+		date, _ := time.Parse("2006-01-02", dateString)
+		definition.Dates = append(definition.Dates, date)
+	}
+	return definition, nil
+}
+
+func readHolidayDefinitionFromRows(rows *sql.Rows) (*HolidayDefinition, error) {
+	var definition HolidayDefinition
+	var rule string
+	var countryCode sql.NullString
+	err := rows.Scan(&definition.Id, &definition.Name, &rule, &definition.Month, &definition.Day,
+		&definition.Weekday, &definition.Week, &definition.EasterOffset, &countryCode)
+	if err != nil {
+		return nil, err
+	}
+	definition.Rule = HolidayRule(rule)
+	definition.CountryCode = countryCode.String
+	return &definition, nil
+}
+
+func (s *MySqlStore) InsertHoliday(data HolidayData) (int, error) {
+	defer trace(traceName(fmt.Sprintf("InsertHoliday(%s)", data.Name)))
+	res, err := s.db.Exec(
+		"INSERT INTO holidays (name, rule, month, day, weekday, week, easter_offset, country_code)"+
+			" VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		data.Name, string(data.Rule), data.Month, data.Day, data.Weekday, data.Week, data.EasterOffset,
+		getNullableString(data.CountryCode))
+	if err != nil {
+		return 0, err
+	}
+	holidayId, err := res.LastInsertId()
+	return int(holidayId), err
+}
+
+func (s *MySqlStore) UpdateHoliday(id int, data HolidayData) error {
+	defer trace(traceName(fmt.Sprintf("UpdateHoliday(%d)", id)))
+	_, err := s.db.Exec(
+		"UPDATE holidays"+
+			" SET name=?, rule=?, month=?, day=?, weekday=?, week=?, easter_offset=?, country_code=?"+
+			" WHERE id=?",
+		data.Name, string(data.Rule), data.Month, data.Day, data.Weekday, data.Week, data.EasterOffset,
+		getNullableString(data.CountryCode), id)
+	return err
+}
+
+func (s *MySqlStore) DeleteHoliday(id int) error {
+	defer trace(traceName(fmt.Sprintf("DeleteHoliday(%d)", id)))
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM holiday_items WHERE holiday_id=?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM holidays WHERE id=?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MySqlStore) InsertHolidayItem(holidayId int, date time.Time) error {
+	defer trace(traceName(fmt.Sprintf("InsertHolidayItem(%d, %v)", holidayId, date)))
+	_, err := s.db.Exec(
+		"INSERT IGNORE INTO holiday_items (holiday_id, date) VALUES (?, ?)",
+		holidayId, date.Format("2006-01-02"))
+	return err
+}
+
+func (s *MySqlStore) DeleteHolidayItem(holidayId int, date time.Time) error {
+	defer trace(traceName(fmt.Sprintf("DeleteHolidayItem(%d, %v)", holidayId, date)))
+	_, err := s.db.Exec(
+		"DELETE FROM holiday_items WHERE holiday_id=? AND date=?",
+		holidayId, date.Format("2006-01-02"))
+	return err
+}
+
+func (s *MySqlStore) DeleteHolidayItems(holidayId int) error {
+	defer trace(traceName(fmt.Sprintf("DeleteHolidayItems(%d)", holidayId)))
+	_, err := s.db.Exec("DELETE FROM holiday_items WHERE holiday_id=?", holidayId)
+	return err
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHolidaysFilteredByCountry(t *testing.T) {
+	store := NewMemoryStore()
+	for _, data := range []HolidayData{
+		{Name: "Everyone Day", Rule: HolidayRuleFixed, Month: 3, Day: 6},
+		{Name: "Home Day", Rule: HolidayRuleFixed, Month: 3, Day: 7, CountryCode: "AU"},
+		{Name: "Away Day", Rule: HolidayRuleFixed, Month: 3, Day: 8, CountryCode: "NZ"},
+	} {
+		_, err := SaveHoliday(store, 0, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "Everyone Day, Home Day"
+
+	start := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	events, err := LoadReminderEvents(store, start, start.AddDate(0, 0, 7), &ReminderFilter{Holidays: true, CountryCode: "AU"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, event := range events {
+		names = append(names, string(event.Caption))
+	}
+	if strings.Join(names, ", ") != want {
+		t.Errorf("Reminders have %v, want %s", names, want)
+	}
+
+	calendar, err := LoadPeopleCalendar(store, 2024, false, "AU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	for _, event := range calendar.Months[time.March-1].Events {
+		names = append(names, string(event.Caption))
+	}
+	if strings.Join(names, ", ") != want {
+		t.Errorf("Calendar has %v, want %s", names, want)
+	}
+
+	feed, err := BuildCalendarFeed(store, "Family", "http://localhost", nil, true, "AU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(feed, "Everyone Day") || !strings.Contains(feed, "Home Day") || strings.Contains(feed, "Away Day") {
+		t.Errorf("Feed should have Everyone Day and Home Day but not Away Day:\n%s", feed)
+	}
+
+	years, err := LoadHolidaysByYear(store, 2024, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(years) == 0 || len(years[0].Holidays) != 3 {
+		t.Errorf("Without a country every holiday should be listed: %+v", years)
+	}
+}
+
+func TestHolidayDateInYear(t *testing.T) {
+	weekday := func(week int, weekday time.Weekday, month time.Month) HolidayData {
+		return HolidayData{Rule: HolidayRuleWeekday, Month: int(month), Weekday: int(weekday), Week: week}
+	}
+	easter := func(offset int) HolidayData {
+		return HolidayData{Rule: HolidayRuleEaster, EasterOffset: offset}
+	}
+	cases := []struct {
+		data HolidayData
+		year int
+		date string
+	}{
+		{HolidayData{Rule: HolidayRuleFixed, Month: 12, Day: 25}, 2024, "2024-12-25"},
+		{HolidayData{Rule: HolidayRuleFixed, Month: 2, Day: 29}, 2024, "2024-02-29"},
+		{HolidayData{Rule: HolidayRuleFixed, Month: 2, Day: 29}, 2023, ""},
+		{HolidayData{Rule: HolidayRuleDates}, 2024, ""},
+		{weekday(4, time.Thursday, time.November), 2024, "2024-11-28"},
+		{weekday(4, time.Thursday, time.November), 2023, "2023-11-23"},
+		{weekday(1, time.Monday, time.September), 2024, "2024-09-02"},
+		{weekday(1, time.Monday, time.September), 2025, "2025-09-01"},
+		{weekday(5, time.Monday, time.September), 2024, "2024-09-30"},
+		{weekday(5, time.Monday, time.February), 2024, ""},
+		{weekday(-1, time.Monday, time.May), 2024, "2024-05-27"},
+		{weekday(-1, time.Monday, time.May), 2021, "2021-05-31"},
+		{weekday(-1, time.Thursday, time.February), 2024, "2024-02-29"},
+		{weekday(-1, time.Friday, time.February), 2024, "2024-02-23"},
+		{easter(0), 2024, "2024-03-31"},
+		{easter(0), 2025, "2025-04-20"},
+		{easter(0), 2000, "2000-04-23"},
+		{easter(0), 2038, "2038-04-25"},
+		{easter(0), 2285, "2285-03-22"},
+		{easter(-2), 2024, "2024-03-29"},
+		{easter(1), 2025, "2025-04-21"},
+		{easter(-46), 2024, "2024-02-14"},
+		{easter(39), 2024, "2024-05-09"},
+		{easter(49), 2024, "2024-05-19"},
+	}
+	for _, c := range cases {
+		date, ok := c.data.DateInYear(c.year)
+		if c.date == "" {
+			if ok {
+				t.Errorf("%s in %d is %s, want none", c.data.RuleDescription(), c.year, date.Format("2006-01-02"))
+			}
+			continue
+		}
+		if !ok || date.Format("2006-01-02") != c.date {
+			t.Errorf("%s in %d is %s, %v, want %s", c.data.RuleDescription(), c.year, date.Format("2006-01-02"), ok, c.date)
+		}
+	}
+}
+
+func TestHolidayRuleDescription(t *testing.T) {
+	cases := []struct {
+		data        HolidayData
+		description string
+	}{
+		{HolidayData{Rule: HolidayRuleDates}, "Set dates"},
+		{HolidayData{Rule: HolidayRuleFixed, Month: 1, Day: 26}, "January 26"},
+		{HolidayData{Rule: HolidayRuleWeekday, Month: 11, Weekday: 4, Week: 4}, "4th Thursday of November"},
+		{HolidayData{Rule: HolidayRuleWeekday, Month: 5, Weekday: 1, Week: -1}, "Last Monday of May"},
+		{HolidayData{Rule: HolidayRuleEaster}, "Easter Sunday"},
+		{HolidayData{Rule: HolidayRuleEaster, EasterOffset: -2}, "2 days before Easter"},
+		{HolidayData{Rule: HolidayRuleEaster, EasterOffset: 1}, "1 day after Easter"},
+	}
+	for _, c := range cases {
+		if description := c.data.RuleDescription(); description != c.description {
+			t.Errorf("%+v is described as %q, want %q", c.data, description, c.description)
+		}
+	}
+}
+
+func TestHolidayDataValidate(t *testing.T) {
+	cases := []struct {
+		data HolidayData
+		ok   bool
+	}{
+		{HolidayData{Name: "Leap Day", Rule: HolidayRuleFixed, Month: 2, Day: 29}, true},
+		{HolidayData{Name: "Bad", Rule: HolidayRuleFixed, Month: 2, Day: 30}, false},
+		{HolidayData{Name: "Bad", Rule: HolidayRuleFixed, Month: 13, Day: 1}, false},
+		{HolidayData{Name: "Last", Rule: HolidayRuleWeekday, Month: 5, Weekday: 1, Week: -1}, true},
+		{HolidayData{Name: "Bad", Rule: HolidayRuleWeekday, Month: 5, Weekday: 7, Week: 1}, false},
+		{HolidayData{Name: "Bad", Rule: HolidayRuleWeekday, Month: 5, Weekday: 1, Week: 6}, false},
+		{HolidayData{Name: "Bad", Rule: HolidayRuleWeekday, Month: 5, Weekday: 1, Week: 0}, false},
+		{HolidayData{Name: "Good Friday", Rule: HolidayRuleEaster, EasterOffset: -2}, true},
+		{HolidayData{Name: "Bad", Rule: HolidayRuleEaster, EasterOffset: maxEasterOffset + 1}, false},
+		{HolidayData{Name: " ", Rule: HolidayRuleDates}, false},
+		{HolidayData{Name: "Bad", Rule: "monthly"}, false},
+	}
+	for _, c := range cases {
+		err := c.data.Validate()
+		if (err == nil) != c.ok {
+			t.Errorf("Validating %+v gave %v", c.data, err)
+		}
+	}
+}
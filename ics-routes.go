@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Address of the site as the browser sees it, for links that leave the site
+func requestBaseUrl(r *http.Request) string {
+	scheme := "http"
+	if isSecureRequest(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func writeCalendarFeed(w http.ResponseWriter, fileName string, feed string) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", fileName))
+	fmt.Fprint(w, feed)
+}
+
+func calendarIcs(w http.ResponseWriter, r *http.Request) {
+	feed, err := BuildCalendarFeed(store, "Family", requestBaseUrl(r), nil, true, r.FormValue("country"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error building calendar: %v", err), 500)
+		return
+	}
+	writeCalendarFeed(w, "family.ics", feed)
+}
+
+func tagIcs(w http.ResponseWriter, r *http.Request) {
+	tagLabel, err := getPathParam(r, "tagLabel", 3)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing tagLabel: %v", err), 400)
+		return
+	}
+	tagLabel = strings.TrimSuffix(tagLabel, ".ics")
+
+	tag, err := store.LoadTagByLabel(tagLabel)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading tag: %v", err), 404)
+		return
+	}
+	people, err := store.LoadPersonLiteListWithTag(tag.Label)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading person list: %v", err), 500)
+		return
+	}
+	personIds := make(map[int]bool)
+	for _, person := range people {
+		personIds[person.Id] = true
+	}
+
+	// Holidays are left to the main feed, so subscribing to both doesn't show them twice
+	feed, err := BuildCalendarFeed(store, "Family: "+tag.Label, requestBaseUrl(r), personIds, false, "")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error building calendar: %v", err), 500)
+		return
+	}
+	writeCalendarFeed(w, tag.Label+".ics", feed)
+}
+
+// Shows the links for subscribing to the feeds.  Posting makes a new token,
+// which stops any old links working, and the main feed's holidays can be
+// limited to a country.
+func calendarSubscribe(w http.ResponseWriter, r *http.Request) {
+	tags, err := store.LoadTagsListByPrefix("")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading tags: %v", err), 500)
+		return
+	}
+	countries, err := store.LoadCountryList()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading countries: %v", err), 500)
+		return
+	}
+	type feedLink struct {
+		Name string
+		Url  string
+	}
+	data := struct {
+		Token       string
+		Feeds       []feedLink
+		Countries   []Country
+		CountryCode string
+	}{
+		Countries:   countries,
+		CountryCode: r.FormValue("country"),
+	}
+
+	if r.Method == "POST" {
+		data.Token, err = NewCalendarToken(CurrentUser(r).Id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error making calendar token: %v", err), 500)
+			return
+		}
+		query := "?token=" + url.QueryEscape(data.Token)
+		baseUrl := requestBaseUrl(r)
+		everyoneQuery := query
+		if data.CountryCode != "" {
+			everyoneQuery += "&country=" + url.QueryEscape(data.CountryCode)
+		}
+		data.Feeds = append(data.Feeds, feedLink{"Everyone", baseUrl + "/calendar.ics" + everyoneQuery})
+		for _, tag := range tags {
+			data.Feeds = append(data.Feeds, feedLink{tag.Label, baseUrl + "/tag/ics/" + url.PathEscape(tag.Label) + ".ics" + query})
+		}
+	}
+
+	renderTemplate(w, r, "person/subscribe.html", data)
+}
+
+func addCalendarFeedRoutes() {
+	http.HandleFunc("/calendar.ics", requireRole(RoleViewer, calendarIcs))
+	http.HandleFunc("/tag/ics/", requireRole(RoleViewer, tagIcs))
+	http.HandleFunc("/person/calendar/subscribe", requireRole(RoleViewer, calendarSubscribe))
+}
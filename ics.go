@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Domain used in event UIDs.  UIDs only depend on ids, so calendar apps
+// replace an event when it changes instead of adding a second copy.
+const icsUidDomain = "family.icadev.com"
+
+// Writes an iCalendar (RFC 5545) feed
+type icsWriter struct {
+	builder strings.Builder
+	stamp   string
+}
+
+func newIcsWriter(name string) *icsWriter {
+	w := &icsWriter{stamp: time.Now().UTC().Format("20060102T150405Z")}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//icadev//family//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:" + icsEscape(name))
+	return w
+}
+
+// Write a content line, folding it at 75 bytes without splitting characters
+func (w *icsWriter) line(value string) {
+	for len(value) > 75 {
+		cut := 75
+		for cut > 0 && !isRuneStart(value[cut]) {
+			cut--
+		}
+		w.builder.WriteString(value[:cut] + "\r\n")
+		value = " " + value[cut:]
+	}
+	w.builder.WriteString(value + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// Add an all day event.  Yearly events repeat on the date every year from
+// the first one.
+func (w *icsWriter) event(uid string, date time.Time, summary string, url string, yearly bool) {
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + uid + "@" + icsUidDomain)
+	w.line("DTSTAMP:" + w.stamp)
+	w.line("DTSTART;VALUE=DATE:" + date.Format("20060102"))
+	w.line("DTEND;VALUE=DATE:" + date.AddDate(0, 0, 1).Format("20060102"))
+	if yearly {
+		if date.Month() == time.February && date.Day() == 29 {
+			// Celebrated on the last day of February outside leap years
+			w.line("RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1")
+		} else {
+			w.line("RRULE:FREQ=YEARLY")
+		}
+	}
+	w.line("SUMMARY:" + icsEscape(summary))
+	if url != "" {
+		w.line("URL:" + url)
+	}
+	w.line("TRANSP:TRANSPARENT")
+	w.line("END:VEVENT")
+}
+
+func (w *icsWriter) String() string {
+	return w.builder.String() + "END:VCALENDAR\r\n"
+}
+
+func icsEscape(value string) string {
+	return strings.NewReplacer(
+		"\\", "\\\\",
+		";", "\\;",
+		",", "\\,",
+		"\r\n", "\\n",
+		"\n", "\\n",
+	).Replace(value)
+}
+
+// Build a feed of yearly birthdays and anniversaries, and the dated holidays
+// from this year on when includeHolidays is set, limited to the ones for
+// everyone and countryCode when it isn't empty.  When personIds isn't nil
+// only those people's birthdays and anniversaries are included.  baseUrl is
+// used to link each event to the person's page.
+func BuildCalendarFeed(store Store, name string, baseUrl string, personIds map[int]bool, includeHolidays bool, countryCode string) (string, error) {
+	defer trace(traceName(fmt.Sprintf("BuildCalendarFeed(%s)", name)))
+
+	includesPerson := func(personId int) bool {
+		return personIds == nil || personIds[personId]
+	}
+	feed := newIcsWriter(name)
+
+	personLookup, err := loadPeopleByBirthMonth(store)
+	if err != nil {
+		return "", err
+	}
+	for _, month := range *personLookup {
+		for _, item := range month {
+			if !includesPerson(item.Person.Id) {
+				continue
+			}
+			feed.event(
+				fmt.Sprintf("birthday-%d", item.Person.Id),
+				item.BirthDate,
+				fmt.Sprintf("%s's birthday", item.Person.Name),
+				fmt.Sprintf("%s/person/view/%d", baseUrl, item.Person.Id),
+				true)
+		}
+	}
+
+	anniversaryLookup, err := loadAnniversariesByMonth(store)
+	if err != nil {
+		return "", err
+	}
+	for _, month := range *anniversaryLookup {
+		for _, item := range month {
+			if !includesPerson(item.Person1.Id) && !includesPerson(item.Person2.Id) {
+				continue
+			}
+			feed.event(
+				fmt.Sprintf("anniversary-%d-%d", item.Person1.Id, item.Person2.Id),
+				item.MarriedDate,
+				fmt.Sprintf("%s & %s's anniversary", item.Person1.Name, item.Person2.Name),
+				fmt.Sprintf("%s/person/view/%d", baseUrl, item.Person1.Id),
+				true)
+		}
+	}
+
+	if includeHolidays {
+		thisYear := time.Now().Year()
+		err = ensureHolidayItems(store, thisYear, thisYear+1)
+		if err != nil {
+			return "", err
+		}
+		holidays, err := store.LoadHolidays(thisYear)
+		if err != nil {
+			return "", err
+		}
+		for _, item := range filterHolidaysByCountry(holidays, countryCode) {
+			feed.event(
+				fmt.Sprintf("holiday-%d-%s", item.Id, item.Date.Format("20060102")),
+				item.Date,
+				item.Name,
+				"",
+				false)
+		}
+	}
+	return feed.String(), nil
+}
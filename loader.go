@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Something people can be looked up in, either the store or a PersonLoader
+type PersonLiteLoader interface {
+	LoadPersonLiteById(id int) (*PersonLite, error)
+	LoadPersonLiteListByIds(ids []int) ([]PersonLite, error)
+}
+
+// Loads people and cities for one request.  Lookups of several ids take a
+// single query, and everything loaded is remembered, so a page that needs the
+// same person in a few places only reads them once.  Not safe to share
+// between goroutines, and as it never forgets it shouldn't outlive a request.
+type PersonLoader struct {
+	store  Store
+	people map[int]*PersonLite
+	cities map[int]*CityLite
+	// Ids that have been looked up and don't exist
+	missingPeople map[int]bool
+	missingCities map[int]bool
+}
+
+func NewPersonLoader(store Store) *PersonLoader {
+	return &PersonLoader{
+		store:         store,
+		people:        make(map[int]*PersonLite),
+		cities:        make(map[int]*CityLite),
+		missingPeople: make(map[int]bool),
+		missingCities: make(map[int]bool),
+	}
+}
+
+const personLoaderContextKey contextKey = "personLoader"
+
+// Add a loader for the request to its context
+func withPersonLoader(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), personLoaderContextKey, NewPersonLoader(store)))
+}
+
+// The request's loader, or a new one when it doesn't have one
+func RequestPersonLoader(r *http.Request) *PersonLoader {
+	if loader, ok := r.Context().Value(personLoaderContextKey).(*PersonLoader); ok {
+		return loader
+	}
+	return NewPersonLoader(store)
+}
+
+// Remember people loaded some other way
+func (l *PersonLoader) Prime(people ...PersonLite) {
+	for i := range people {
+		person := people[i]
+		l.people[person.Id] = &person
+		delete(l.missingPeople, person.Id)
+	}
+}
+
+// Load any of the people that haven't been loaded yet in one query
+func (l *PersonLoader) loadPeople(ids []int) error {
+	var missing []int
+	for _, id := range uniqueInts(ids) {
+		if id > 0 && l.people[id] == nil && !l.missingPeople[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	people, err := l.store.LoadPersonLiteListByIds(missing)
+	if err != nil {
+		return err
+	}
+	l.Prime(people...)
+	for _, id := range missing {
+		if l.people[id] == nil {
+			l.missingPeople[id] = true
+		}
+	}
+	return nil
+}
+
+func (l *PersonLoader) LoadPersonLiteById(id int) (*PersonLite, error) {
+	err := l.loadPeople([]int{id})
+	if err != nil {
+		return nil, err
+	}
+	person := l.people[id]
+	if person == nil {
+		return nil, notFoundf("Person not found with id: %d", id)
+	}
+	item := *person
+	return &item, nil
+}
+
+// The people with the given ids, in the order of the ids.  Missing people are
+// left out.
+func (l *PersonLoader) LoadPersonLiteListByIds(ids []int) ([]PersonLite, error) {
+	err := l.loadPeople(ids)
+	if err != nil {
+		return nil, err
+	}
+	var list []PersonLite
+	for _, id := range ids {
+		if person := l.people[id]; person != nil {
+			list = append(list, *person)
+		}
+	}
+	return list, nil
+}
+
+// The cities with the given ids that exist, by id, loaded in one query
+func (l *PersonLoader) LoadCities(ids []int) (map[int]*CityLite, error) {
+	var missing []int
+	for _, id := range uniqueInts(ids) {
+		if id > 0 && l.cities[id] == nil && !l.missingCities[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		cities, err := l.store.LoadCitiesByIds(missing)
+		if err != nil {
+			return nil, err
+		}
+		for i := range cities {
+			l.cities[cities[i].Id] = &cities[i]
+		}
+		for _, id := range missing {
+			if l.cities[id] == nil {
+				l.missingCities[id] = true
+			}
+		}
+	}
+
+	found := make(map[int]*CityLite)
+	for _, id := range ids {
+		if city := l.cities[id]; city != nil {
+			item := *city
+			found[id] = &item
+		}
+	}
+	return found, nil
+}
+
+// Load a person with their parents, cities, children, spouses and siblings.
+// It takes the same few queries however big the family is, and fewer when
+// the people have already been loaded.
+func (l *PersonLoader) LoadPerson(id int) (*Person, error) {
+	defer trace(traceName(fmt.Sprintf("PersonLoader.LoadPerson(%d)", id)))
+	data, err := l.store.LoadPersonDataById(id)
+	if err != nil {
+		return nil, err
+	}
+	person := Person{
+		Id:               id,
+		FirstName:        data.FirstName,
+		MiddleName:       data.MiddleName,
+		LastName:         data.LastName,
+		NickName:         data.NickName,
+		Gender:           GetGenderName(data.Gender),
+		IsAlive:          data.IsAlive,
+		BirthDate:        parseDateOrZero(data.BirthDate),
+		IsBirthYearGuess: data.IsBirthYearGuess,
+		DeathDate:        parseDateOrZero(data.DeathDate),
+		IsDeathYearGuess: data.IsDeathYearGuess,
+	}
+	l.Prime(PersonLite{
+		Id:       id,
+		Name:     BuildFullName(data.FirstName, data.MiddleName, data.LastName, data.NickName),
+		Gender:   person.Gender,
+		MotherId: data.MotherId,
+		FatherId: data.FatherId,
+	})
+
+	// Children and siblings both come from the children of the person and
+	// their parents.  Siblings share both parents.
+	parentIds := []int{id}
+	if data.MotherId > 0 && data.FatherId > 0 {
+		parentIds = append(parentIds, data.MotherId, data.FatherId)
+	}
+	family, err := l.store.LoadChildrenPersonLiteByParentIds(parentIds)
+	if err != nil {
+		return nil, err
+	}
+	l.Prime(family...)
+	for _, relative := range family {
+		if relative.MotherId == id || relative.FatherId == id {
+			person.Children = append(person.Children, relative)
+		} else if relative.Id != id && data.MotherId > 0 && data.FatherId > 0 &&
+			relative.MotherId == data.MotherId && relative.FatherId == data.FatherId {
+			person.Siblings = append(person.Siblings, relative)
+		}
+	}
+
+	spouses, err := l.store.LoadSpousesByPersonIds([]int{id})
+	if err != nil {
+		return nil, err
+	}
+	person.Spouses = spousesOf(id, spouses)
+	for _, spouse := range person.Spouses {
+		l.Prime(spouse.Person1, spouse.Person2)
+	}
+
+	err = l.loadPeople([]int{data.MotherId, data.FatherId})
+	if err != nil {
+		return nil, err
+	}
+	if mother := l.people[data.MotherId]; mother != nil {
+		item := *mother
+		person.Mother = &item
+	}
+	if father := l.people[data.FatherId]; father != nil {
+		item := *father
+		person.Father = &item
+	}
+
+	cities, err := l.LoadCities([]int{data.BirthCityId, data.DeathCityId, data.BurialCityId, data.HomeCityId})
+	if err != nil {
+		return nil, err
+	}
+	person.BirthCity = cities[data.BirthCityId]
+	person.DeathCity = cities[data.DeathCityId]
+	person.BurialCity = cities[data.BurialCityId]
+	person.HomeCity = cities[data.HomeCityId]
+	return &person, nil
+}
+
+// The relationships a person is in, turned around where needed so the person
+// is always Person1
+func spousesOf(personId int, spouses []SpouseLite) []SpouseLite {
+	var list []SpouseLite
+	for _, spouse := range spouses {
+		if spouse.Person2.Id == personId {
+			spouse.Person1, spouse.Person2 = spouse.Person2, spouse.Person1
+		}
+		if spouse.Person1.Id == personId {
+			list = append(list, spouse)
+		}
+	}
+	return list
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+)
+
+// An email with both html and plain text bodies
+type EmailMessage struct {
+	From     string
+	ToName   string
+	ToEmail  string
+	Subject  string
+	HtmlBody string
+	TextBody string
+}
+
+// Sends emails.  Which one is used is picked by the -mailer flag.
+type Mailer interface {
+	Send(message EmailMessage) error
+}
+
+// Mailer used for reminder emails
+var mailer Mailer
+
+func NewMailerFromConfig() (Mailer, error) {
+	switch config.mailer {
+	case "ses":
+		return &SesMailer{
+			Region:    config.awsRegion,
+			AccessKey: config.awsAccessKey,
+			Secret:    config.awsSecret,
+		}, nil
+	case "smtp":
+		if config.smtpHost == "" {
+			return nil, fmt.Errorf("-smtpHost is needed to send mail with smtp")
+		}
+		return &SmtpMailer{
+			Host:     config.smtpHost,
+			Port:     config.smtpPort,
+			Username: config.smtpUsername,
+			Password: config.smtpPassword,
+			StartTLS: config.smtpStartTLS,
+		}, nil
+	case "file":
+		return &FileMailer{Dir: config.mailDir}, nil
+	default:
+		return nil, fmt.Errorf("Unknown mailer: %s", config.mailer)
+	}
+}
+
+// Sends through Amazon SES
+type SesMailer struct {
+	Region    string
+	AccessKey string
+	Secret    string
+}
+
+func (m *SesMailer) Send(message EmailMessage) error {
+	awsConfig := aws.NewConfig().WithRegion(m.Region).WithCredentials(credentials.NewStaticCredentials(m.AccessKey, m.Secret, ""))
+	svc := ses.New(session.New(awsConfig))
+	input := &ses.SendEmailInput{
+		Destination: &ses.Destination{
+			ToAddresses: []*string{
+				aws.String(formatAddress(message.ToName, message.ToEmail)),
+			},
+		},
+		Message: &ses.Message{
+			Body: &ses.Body{
+				Html: &ses.Content{
+					Charset: aws.String("UTF-8"),
+					Data:    aws.String(message.HtmlBody),
+				},
+				Text: &ses.Content{
+					Charset: aws.String("UTF-8"),
+					Data:    aws.String(message.TextBody),
+				},
+			},
+			Subject: &ses.Content{
+				Charset: aws.String("UTF-8"),
+				Data:    aws.String(message.Subject),
+			},
+		},
+		Source: aws.String(message.From),
+	}
+	_, err := svc.SendEmail(input)
+	return err
+}
+
+// Sends through an SMTP server, upgrading the connection with STARTTLS
+// before logging in
+type SmtpMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Refuse to send unless the server supports STARTTLS
+	StartTLS bool
+}
+
+func (m *SmtpMailer) Send(message EmailMessage) error {
+	body, err := buildMimeMessage(message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.Dial(net.JoinHostPort(m.Host, strconv.Itoa(m.Port)))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: m.Host})
+		if err != nil {
+			return err
+		}
+	} else if m.StartTLS {
+		return fmt.Errorf("SMTP server %s doesn't support STARTTLS", m.Host)
+	}
+	if m.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	from, err := mail.ParseAddress(message.From)
+	if err != nil {
+		return err
+	}
+	err = client.Mail(from.Address)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(message.ToEmail)
+	if err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(body)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Writes each email to a .eml file in a directory instead of sending it, for
+// checking how emails look without a mail server
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(message EmailMessage) error {
+	now := time.Now()
+	body, err := buildMimeMessage(message, now)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(m.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	// Timestamped so the files sort in the order they were sent
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102-150405.000000"), safeFileName(message.ToEmail))
+	return os.WriteFile(filepath.Join(m.Dir, name), body, 0644)
+}
+
+func safeFileName(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '@' {
+			return r
+		}
+		return '_'
+	}, value)
+}
+
+func formatAddress(name string, email string) string {
+	return (&mail.Address{Name: name, Address: email}).String()
+}
+
+// Build a multipart/alternative message with the text and html bodies
+func buildMimeMessage(message EmailMessage, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if from, err := mail.ParseAddress(message.From); err == nil {
+		domain = from.Address[strings.LastIndex(from.Address, "@")+1:]
+	}
+
+	header := []string{
+		"From: " + message.From,
+		"To: " + formatAddress(message.ToName, message.ToEmail),
+		"Subject: " + mime.QEncoding.Encode("utf-8", message.Subject),
+		"Date: " + date.Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%s@%s>", hex.EncodeToString(id), domain),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + writer.Boundary(),
+	}
+	buf.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", message.TextBody},
+		{"text/html; charset=utf-8", message.HtmlBody},
+	} {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(partWriter)
+		_, err = encoder.Write([]byte(part.body))
+		if err != nil {
+			return nil, err
+		}
+		err = encoder.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -1,61 +1,260 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 var config struct {
+	configFile               string
+	listenAddress            string
+	storeType                string
 	databaseConnectionString string
 	mapsApiKey               string
 	awsAccessKey             string
 	awsSecret                string
+	awsRegion                string
+	mailer                   string
+	mailFrom                 string
+	smtpHost                 string
+	smtpPort                 int
+	smtpUsername             string
+	smtpPassword             string
+	smtpStartTLS             bool
+	mailDir                  string
+	reminderSchedule         string
+	autoMigrate              bool
+	secureCookies            bool
+	dev                      bool
+	filesDir                 string
+	readTimeout              time.Duration
+	writeTimeout             time.Duration
+	idleTimeout              time.Duration
+	shutdownDelay            time.Duration
+	shutdownTimeout          time.Duration
+	dbMaxOpenConns           int
+	dbMaxIdleConns           int
+	dbConnMaxLifetime        time.Duration
+	dbConnMaxIdleTime        time.Duration
 }
 
-// Connection to the MySQL database
+// Connection to the MySQL database, nil when running with the memory store
 var db *sql.DB
 
-func health(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "OK")
-}
+// Storage used by the handlers
+var store Store
 
-func loadFlags() {
+func loadFlags() error {
+	flag.StringVar(&config.configFile, "config", "", "JSON file of settings keyed by flag name, eg. {\"smtpPort\": 25}.  Flags and environment variables override it.")
+	flag.StringVar(&config.listenAddress, "listen", ":8090", "address to serve http on")
+	flag.DurationVar(&config.readTimeout, "readTimeout", 15*time.Second, "longest a client can take to send a request")
+	flag.DurationVar(&config.writeTimeout, "writeTimeout", 60*time.Second, "longest a response can take, from the end of reading the request")
+	flag.DurationVar(&config.idleTimeout, "idleTimeout", 120*time.Second, "how long an idle keep-alive connection is kept open")
+	flag.DurationVar(&config.shutdownDelay, "shutdownDelay", 0, "how long to keep serving after SIGTERM while /health/ready fails, so the load balancer can stop sending requests first")
+	flag.DurationVar(&config.shutdownTimeout, "shutdownTimeout", 30*time.Second, "how long requests in flight and a scheduled reminder run get to finish on shutdown")
+	flag.StringVar(&config.storeType, "store", "mysql", "storage to use: mysql, or memory for local runs without a database")
 	flag.StringVar(&config.databaseConnectionString, "database", "", "dsn for connecting to a mysql database")
+	flag.IntVar(&config.dbMaxOpenConns, "dbMaxOpenConns", 10, "most connections open to the database at once, 0 for no limit")
+	flag.IntVar(&config.dbMaxIdleConns, "dbMaxIdleConns", 5, "most idle connections kept open to the database")
+	flag.DurationVar(&config.dbConnMaxLifetime, "dbConnMaxLifetime", 5*time.Minute, "how long a database connection is reused before being replaced, 0 for forever")
+	flag.DurationVar(&config.dbConnMaxIdleTime, "dbConnMaxIdleTime", time.Minute, "how long an idle database connection is kept, 0 for forever")
 	flag.StringVar(&config.mapsApiKey, "mapsApiKey", "", "API Key for connecting to Google Static Maps API")
 	flag.StringVar(&config.awsAccessKey, "awsAccessKey", "", "API Key for connecting to Amazon AWS")
 	flag.StringVar(&config.awsSecret, "awsSecret", "", "Secret Key for connecting to Amazon AWS")
-	flag.Parse()
+	flag.StringVar(&config.awsRegion, "awsRegion", "us-east-1", "AWS region to send email through SES in")
+	flag.StringVar(&config.mailer, "mailer", "ses", "how to send email: ses, smtp, or file to write .eml files to -mailDir")
+	flag.StringVar(&config.mailFrom, "mailFrom", "family@icadev.com", "address emails are sent from")
+	flag.StringVar(&config.smtpHost, "smtpHost", "", "SMTP server to send email through")
+	flag.IntVar(&config.smtpPort, "smtpPort", 587, "SMTP server port")
+	flag.StringVar(&config.smtpUsername, "smtpUsername", "", "SMTP login, leave empty to send without logging in")
+	flag.StringVar(&config.smtpPassword, "smtpPassword", "", "SMTP password")
+	flag.BoolVar(&config.smtpStartTLS, "smtpStartTLS", true, "refuse to send unless the SMTP server supports STARTTLS")
+	flag.StringVar(&config.mailDir, "mailDir", "mail", "directory the file mailer writes .eml files to")
+	flag.StringVar(&config.reminderSchedule, "reminderSchedule", "", "cron expression for sending the weekly reminder emails in local time, eg. \"0 8 * * 1\" for 8am on Mondays.  Empty to not send them.")
+	flag.IntVar(&defaultReminderLeadDays, "reminderLeadDays", defaultReminderLeadDays, "days of upcoming events a reminder covers for new recipients")
+	flag.IntVar(&milestoneLeadDays, "reminderMilestoneDays", milestoneLeadDays, "days ahead milestone birthdays and anniversaries are reminded about")
+	flag.BoolVar(&config.autoMigrate, "autoMigrate", true, "apply pending schema migrations on startup")
+	flag.BoolVar(&config.secureCookies, "secureCookies", false, "always mark session cookies secure, even when not behind https")
+	flag.BoolVar(&config.dev, "dev", false, "read assets and templates from the working directory unless -files is given, reload templates when they change and don't cache assets")
+	flag.StringVar(&config.filesDir, "files", "", "directory to read assets/ and tmpl/ from instead of the copies built into the binary")
+	err := loadConfig(os.Args[1:])
+	if err != nil {
+		return err
+	}
+	if defaultReminderLeadDays < 1 || defaultReminderLeadDays > maxReminderLeadDays {
+		return fmt.Errorf("-reminderLeadDays must be between 1 and %d", maxReminderLeadDays)
+	}
+	if milestoneLeadDays < 0 || milestoneLeadDays > maxReminderLeadDays {
+		return fmt.Errorf("-reminderMilestoneDays must be between 0 and %d", maxReminderLeadDays)
+	}
+	return nil
 }
 
 func main() {
-	loadFlags()
+	err := loadFlags()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for _, warning := range secretFlagWarnings() {
+		fmt.Println(warning)
+	}
 
-	// Connect to the MySQL database
-	fmt.Printf("Connecting to database: %s\n", config.databaseConnectionString)
-	var err error
-	db, err = sql.Open("mysql", config.databaseConnectionString)
+	filesDir := config.filesDir
+	if config.dev && filesDir == "" {
+		filesDir = "."
+	}
+	files := siteFiles(filesDir)
+	templates, err = NewTemplateRegistry(files, config.dev)
 	if err != nil {
-		panic(err)
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	assetServer, err = NewAssetServer(files, config.dev)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	switch config.storeType {
+	case "mysql":
+		// Connect to the MySQL database
+		fmt.Printf("Connecting to database: %s\n", redactDsn(config.databaseConnectionString))
+		db, err = sql.Open("mysql", config.databaseConnectionString)
+		if err != nil {
+			panic(err)
+		}
+		defer db.Close()
+		db.SetMaxOpenConns(config.dbMaxOpenConns)
+		db.SetMaxIdleConns(config.dbMaxIdleConns)
+		db.SetConnMaxLifetime(config.dbConnMaxLifetime)
+		db.SetConnMaxIdleTime(config.dbConnMaxIdleTime)
+		store = NewMySqlStore(db)
+	case "memory":
+		fmt.Println("Using in-memory store, nothing will be saved")
+		store, err = NewMemoryStoreWithUser("dev@localhost", "development")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println("Log in as dev@localhost with password: development")
+	default:
+		fmt.Printf("Unknown store: %s\n", config.storeType)
+		os.Exit(1)
 	}
-	defer db.Close()
 
-	// Setup static finals
-	http.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("assets/"))))
+	mailer, err = NewMailerFromConfig()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// Run a subcommand rather than the server if one was given
+	if flag.NArg() > 0 {
+		err = runCommand(flag.Args())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Setup routes
-	http.HandleFunc("/health", health)
+	// Bring the schema up to date
+	if db != nil && config.autoMigrate {
+		err = MigrateUp(db)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// Setup static files and routes
+	addRoutes()
+
+	// Stop on SIGTERM or ctrl-c
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Send the reminder emails on a schedule.  schedulerDone is closed once
+	// the scheduler has stopped, after any run it's in the middle of.
+	schedulerDone := make(chan struct{})
+	if config.reminderSchedule != "" {
+		schedule, err := ParseCronSchedule(config.reminderSchedule)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		go func() {
+			defer close(schedulerDone)
+			RunScheduler(ctx, "reminders", schedule, runScheduledReminders)
+		}()
+	} else {
+		close(schedulerDone)
+	}
+
+	server := &http.Server{
+		Addr:         config.listenAddress,
+		Handler:      requireLogin(http.DefaultServeMux),
+		ReadTimeout:  config.readTimeout,
+		WriteTimeout: config.writeTimeout,
+		IdleTimeout:  config.idleTimeout,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	fmt.Printf("Listening on %s\n", config.listenAddress)
+
+	select {
+	case err = <-serverErr:
+		fmt.Println(err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// Fail readiness checks so no new requests are sent here, then let the
+	// ones in flight finish.  A second signal stops straight away.
+	stop()
+	shuttingDown.Store(true)
+	fmt.Println("Shutting down")
+	time.Sleep(config.shutdownDelay)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.shutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		fmt.Printf("Error shutting down: %v\n", err)
+	}
+	select {
+	case <-schedulerDone:
+	case <-shutdownCtx.Done():
+		fmt.Println("Stopping in the middle of sending reminders")
+	}
+}
+
+// Register every route on the default mux
+func addRoutes() {
+	http.Handle("/assets/", http.StripPrefix("/assets/", assetServer))
+	addHealthRoutes()
 	addCountryRoutes()
 	addRegionRoutes()
 	addCityRoutes()
 	addPersonRoutes()
+	addChartRoutes()
+	addRelationshipRoutes()
 	addSpouseRoutes()
 	addHolidayRoutes()
 	addTagRoutes()
-
-	http.ListenAndServe(":8090", nil)
+	addGedcomRoutes()
+	addUserRoutes()
+	addReminderRoutes()
+	addCalendarFeedRoutes()
+	addApiRoutes()
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var addTestRoutes sync.Once
+
+// The whole app on a new memory store, with an admin test@localhost whose
+// password is "password"
+func newTestServer(t *testing.T) http.Handler {
+	t.Helper()
+	var err error
+	store, err = NewMemoryStoreWithUser("test@localhost", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err = NewTemplateRegistry(siteFiles(""), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assetServer, err = NewAssetServer(siteFiles(""), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestRoutes.Do(addRoutes)
+	return requireLogin(http.DefaultServeMux)
+}
+
+// Log in as test@localhost, returning the session cookie and its CSRF token
+func loginTestUser(t *testing.T, handler http.Handler) (*http.Cookie, string) {
+	t.Helper()
+	form := url.Values{"email": {"test@localhost"}, "password": {"password"}}
+	request := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == sessionCookieName {
+			return cookie, csrfTokenForSession(cookie.Value)
+		}
+	}
+	t.Fatalf("Logging in gave %d with no session cookie", response.Code)
+	return nil, ""
+}
@@ -0,0 +1,1735 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryPerson struct {
+	Id   int
+	Data PersonData
+}
+
+type memorySpouse struct {
+	Person1Id   int
+	Person2Id   int
+	Status      int
+	MarriedDate string
+}
+
+type memoryCity struct {
+	Id       int
+	Name     string
+	RegionId int
+	Lat      float32
+	Lng      float32
+}
+
+type memorySession struct {
+	UserId    int
+	ExpiresAt time.Time
+}
+
+type memoryReminderEmail struct {
+	RunId     int
+	ClaimedAt time.Time
+	SentAt    *time.Time
+}
+
+type memoryHolidayItem struct {
+	HolidayId int
+	Date      time.Time
+}
+
+// Store that keeps everything in memory, for running the app and its tests
+// without a MySQL server.  Nothing is persisted.
+type MemoryStore struct {
+	mutex  sync.RWMutex
+	nextId int
+	memoryData
+}
+
+// Everything a memory store holds, apart from its id counter, so it can be
+// copied to roll a transaction back
+type memoryData struct {
+	people       map[int]*memoryPerson
+	altNames     map[int]*AlternateName
+	spouses      []memorySpouse
+	cities       map[int]*memoryCity
+	regions      map[int]*RegionData
+	countries    map[string]*CountryData
+	continents   map[string]*ContinentWithMap
+	tags         map[int]*Tag
+	peopleTags   map[[2]int]bool
+	holidays     map[int]*HolidayData
+	holidayItems []memoryHolidayItem
+	users        map[int]*User
+	sessions     map[string]memorySession
+	favorites    map[[2]int]bool
+	recipients   map[int]*ReminderRecipientData
+	runs         []ReminderRun
+	runEmails    map[string]memoryReminderEmail
+	feedTokens   map[string]int
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{memoryData: memoryData{
+		people:     make(map[int]*memoryPerson),
+		altNames:   make(map[int]*AlternateName),
+		cities:     make(map[int]*memoryCity),
+		regions:    make(map[int]*RegionData),
+		countries:  make(map[string]*CountryData),
+		continents: make(map[string]*ContinentWithMap),
+		tags:       make(map[int]*Tag),
+		peopleTags: make(map[[2]int]bool),
+		holidays:   make(map[int]*HolidayData),
+		users:      make(map[int]*User),
+		sessions:   make(map[string]memorySession),
+		favorites:  make(map[[2]int]bool),
+		recipients: make(map[int]*ReminderRecipientData),
+		runEmails:  make(map[string]memoryReminderEmail),
+		feedTokens: make(map[string]int),
+	}}
+	for _, continent := range []ContinentWithMap{
+		{Code: "AF", Name: "Africa", MapLatitude: 2, MapLongitude: 17, MapZoom: 3, Color: "F4A460"},
+		{Code: "AN", Name: "Antarctica", MapLatitude: -80, MapLongitude: 0, MapZoom: 2, Color: "E0FFFF"},
+		{Code: "AS", Name: "Asia", MapLatitude: 34, MapLongitude: 100, MapZoom: 2, Color: "FFD700"},
+		{Code: "EU", Name: "Europe", MapLatitude: 54, MapLongitude: 15, MapZoom: 3, Color: "6495ED"},
+		{Code: "NA", Name: "North America", MapLatitude: 45, MapLongitude: -100, MapZoom: 2, Color: "3CB371"},
+		{Code: "OC", Name: "Oceania", MapLatitude: -25, MapLongitude: 140, MapZoom: 3, Color: "BA55D3"},
+		{Code: "SA", Name: "South America", MapLatitude: -15, MapLongitude: -60, MapZoom: 3, Color: "FF6347"},
+	} {
+		item := continent
+		s.continents[item.Code] = &item
+	}
+	return s
+}
+
+// Memory store with a single admin user, so the app can be logged in to locally
+func NewMemoryStoreWithUser(email string, password string) (*MemoryStore, error) {
+	s := NewMemoryStore()
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.InsertUser(email, "Developer", RoleAdmin, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Copy of a map whose values are copies too, so changing one doesn't change
+// the other
+func clonePointerMap[K comparable, V any](m map[K]*V) map[K]*V {
+	clone := make(map[K]*V, len(m))
+	for key, value := range m {
+		item := *value
+		clone[key] = &item
+	}
+	return clone
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	clone := make(map[K]V, len(m))
+	for key, value := range m {
+		clone[key] = value
+	}
+	return clone
+}
+
+func (d *memoryData) clone() memoryData {
+	return memoryData{
+		people:       clonePointerMap(d.people),
+		altNames:     clonePointerMap(d.altNames),
+		spouses:      append([]memorySpouse(nil), d.spouses...),
+		cities:       clonePointerMap(d.cities),
+		regions:      clonePointerMap(d.regions),
+		countries:    clonePointerMap(d.countries),
+		continents:   clonePointerMap(d.continents),
+		tags:         clonePointerMap(d.tags),
+		peopleTags:   cloneMap(d.peopleTags),
+		holidays:     clonePointerMap(d.holidays),
+		holidayItems: append([]memoryHolidayItem(nil), d.holidayItems...),
+		users:        clonePointerMap(d.users),
+		sessions:     cloneMap(d.sessions),
+		favorites:    cloneMap(d.favorites),
+		recipients:   clonePointerMap(d.recipients),
+		runs:         append([]ReminderRun(nil), d.runs...),
+		runEmails:    cloneMap(d.runEmails),
+		feedTokens:   cloneMap(d.feedTokens),
+	}
+}
+
+// Runs fn against the store itself and puts everything back as it was if
+// it fails.  Changes made by other requests while fn runs are undone too,
+// which is fine for a store that's only for local runs and tests.
+func (s *MemoryStore) InTransaction(fn func(store Store) error) error {
+	s.mutex.RLock()
+	saved := s.memoryData.clone()
+	s.mutex.RUnlock()
+
+	err := fn(s)
+	if err != nil {
+		s.mutex.Lock()
+		s.memoryData = saved
+		s.mutex.Unlock()
+	}
+	return err
+}
+
+func (s *MemoryStore) newId() int {
+	s.nextId++
+	return s.nextId
+}
+
+func hasPrefixFold(value string, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(value), strings.ToLower(prefix))
+}
+
+func parseDateOrZero(value string) time.Time {
+	date, _ := time.Parse("2006-01-02", strings.TrimSpace(value))
+	return date
+}
+
+// Month and day of a date, for ordering by MONTH(date), DAY(date)
+func monthDayOrder(date time.Time) int {
+	return int(date.Month())*100 + date.Day()
+}
+
+// Person
+
+func (s *MemoryStore) personLite(p *memoryPerson) PersonLite {
+	return PersonLite{
+		Id:       p.Id,
+		Name:     BuildFullName(p.Data.FirstName, p.Data.MiddleName, p.Data.LastName, p.Data.NickName),
+		Gender:   GetGenderName(p.Data.Gender),
+		MotherId: p.Data.MotherId,
+		FatherId: p.Data.FatherId,
+	}
+}
+
+// People matching the filter, sorted by last, first and middle name
+func (s *MemoryStore) personLiteList(filter func(p *memoryPerson) bool) []PersonLite {
+	var matches []*memoryPerson
+	for _, p := range s.people {
+		if filter(p) {
+			matches = append(matches, p)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i].Data, matches[j].Data
+		if a.LastName != b.LastName {
+			return a.LastName < b.LastName
+		}
+		if a.FirstName != b.FirstName {
+			return a.FirstName < b.FirstName
+		}
+		if a.MiddleName != b.MiddleName {
+			return a.MiddleName < b.MiddleName
+		}
+		return matches[i].Id < matches[j].Id
+	})
+	var list []PersonLite
+	for _, p := range matches {
+		list = append(list, s.personLite(p))
+	}
+	return list
+}
+
+// People matching the filter, sorted by birth date
+func (s *MemoryStore) personLiteListByBirthDate(filter func(p *memoryPerson) bool) []PersonLite {
+	var matches []*memoryPerson
+	for _, p := range s.people {
+		if filter(p) {
+			matches = append(matches, p)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i].Data.BirthDate, matches[j].Data.BirthDate
+		if a != b {
+			return a < b
+		}
+		return matches[i].Id < matches[j].Id
+	})
+	var list []PersonLite
+	for _, p := range matches {
+		list = append(list, s.personLite(p))
+	}
+	return list
+}
+
+func (s *MemoryStore) LoadPersonLiteById(id int) (*PersonLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.loadPersonLiteById(id)
+}
+
+func (s *MemoryStore) loadPersonLiteById(id int) (*PersonLite, error) {
+	p, found := s.people[id]
+	if !found {
+		return nil, notFoundf("Person not found with id: %d", id)
+	}
+	item := s.personLite(p)
+	return &item, nil
+}
+
+func (s *MemoryStore) LoadPersonById(id int) (*Person, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	p, found := s.people[id]
+	if !found {
+		return nil, notFoundf("Person not found with id: %d", id)
+	}
+	item := s.person(p)
+	var err error
+	if p.Data.BirthCityId > 0 {
+		item.BirthCity, err = s.loadCityById(p.Data.BirthCityId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	item.Children = s.personLiteListByBirthDate(func(c *memoryPerson) bool {
+		return c.Data.MotherId == id || c.Data.FatherId == id
+	})
+	item.Spouses, err = s.loadSpousesByPersonId(id)
+	if err != nil {
+		return nil, err
+	}
+	item.Siblings = s.loadSiblingsPersonLite(id)
+	return &item, nil
+}
+
+func (s *MemoryStore) LoadPersonDataById(id int) (*PersonData, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	p, found := s.people[id]
+	if !found {
+		return nil, notFoundf("Person not found with id: %d", id)
+	}
+	data := p.Data
+	return &data, nil
+}
+
+// Person with their parents and cities filled in
+func (s *MemoryStore) person(p *memoryPerson) Person {
+	item := Person{
+		Id:               p.Id,
+		FirstName:        p.Data.FirstName,
+		MiddleName:       p.Data.MiddleName,
+		LastName:         p.Data.LastName,
+		NickName:         p.Data.NickName,
+		Gender:           GetGenderName(p.Data.Gender),
+		IsAlive:          p.Data.IsAlive,
+		BirthDate:        parseDateOrZero(p.Data.BirthDate),
+		IsBirthYearGuess: p.Data.IsBirthYearGuess,
+		DeathDate:        parseDateOrZero(p.Data.DeathDate),
+		IsDeathYearGuess: p.Data.IsDeathYearGuess,
+	}
+	if p.Data.MotherId > 0 {
+		item.Mother, _ = s.loadPersonLiteById(p.Data.MotherId)
+	}
+	if p.Data.FatherId > 0 {
+		item.Father, _ = s.loadPersonLiteById(p.Data.FatherId)
+	}
+	if p.Data.BirthCityId > 0 {
+		item.BirthCity, _ = s.loadCityById(p.Data.BirthCityId)
+	}
+	if p.Data.HomeCityId > 0 {
+		item.HomeCity, _ = s.loadCityById(p.Data.HomeCityId)
+	}
+	if p.Data.DeathCityId > 0 {
+		item.DeathCity, _ = s.loadCityById(p.Data.DeathCityId)
+	}
+	if p.Data.BurialCityId > 0 {
+		item.BurialCity, _ = s.loadCityById(p.Data.BurialCityId)
+	}
+	return item
+}
+
+func (s *MemoryStore) LoadPersonList() ([]Person, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var ids []int
+	for id := range s.people {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var list []Person
+	for _, id := range ids {
+		list = append(list, s.person(s.people[id]))
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) LoadPersonLiteList() ([]PersonLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.personLiteList(func(p *memoryPerson) bool { return true }), nil
+}
+
+func (s *MemoryStore) LoadPersonLiteListByIds(ids []int) ([]PersonLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	lookup := intSet(ids)
+	list := s.personLiteList(func(p *memoryPerson) bool { return lookup[p.Id] })
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list, nil
+}
+
+func (s *MemoryStore) LoadPersonLiteListByHomeCityId(cityId int) ([]PersonLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.personLiteList(func(p *memoryPerson) bool { return p.Data.HomeCityId == cityId }), nil
+}
+
+func (s *MemoryStore) LoadChildrenPersonLiteByParentIds(parentIds []int) ([]PersonLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	lookup := intSet(parentIds)
+	return s.personLiteListByBirthDate(func(p *memoryPerson) bool {
+		return lookup[p.Data.MotherId] || lookup[p.Data.FatherId]
+	}), nil
+}
+
+// Siblings share both parents, matching the MySQL query
+func (s *MemoryStore) loadSiblingsPersonLite(personId int) []PersonLite {
+	person, found := s.people[personId]
+	if !found || person.Data.MotherId == 0 || person.Data.FatherId == 0 {
+		return nil
+	}
+	return s.personLiteListByBirthDate(func(p *memoryPerson) bool {
+		return p.Id != personId && p.Data.MotherId == person.Data.MotherId && p.Data.FatherId == person.Data.FatherId
+	})
+}
+
+func (s *MemoryStore) LoadPersonLiteListWithTag(tagLabel string) ([]PersonLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	tag := s.loadTagByLabel(tagLabel)
+	if tag == nil {
+		return nil, nil
+	}
+	return s.personLiteList(func(p *memoryPerson) bool { return s.peopleTags[[2]int{p.Id, tag.Id}] }), nil
+}
+
+func (s *MemoryStore) LoadPersonSearchNames() ([]PersonSearchNames, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var list []PersonSearchNames
+	for _, p := range s.people {
+		list = append(list, PersonSearchNames{
+			PersonLite:     s.personLite(p),
+			FirstName:      p.Data.FirstName,
+			MiddleName:     p.Data.MiddleName,
+			LastName:       p.Data.LastName,
+			NickName:       p.Data.NickName,
+			AlternateNames: s.alternateNames(p.Id),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list, nil
+}
+
+func (s *MemoryStore) LoadPersonLiteListByName(firstName string, lastName string, birthDate string) ([]PersonLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	list := s.personLiteListByBirthDate(func(p *memoryPerson) bool {
+		return strings.EqualFold(p.Data.FirstName, firstName) && strings.EqualFold(p.Data.LastName, lastName) &&
+			(birthDate == "" || p.Data.BirthDate == "" || p.Data.BirthDate == birthDate)
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list, nil
+}
+
+func (s *MemoryStore) LoadBirthdays() ([]CalendarPerson, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var people []CalendarPerson
+	for _, p := range s.people {
+		birthDate := parseDateOrZero(p.Data.BirthDate)
+		if !p.Data.IsAlive || birthDate.IsZero() {
+			continue
+		}
+		people = append(people, CalendarPerson{BirthDate: birthDate, IsBirthYearGuess: p.Data.IsBirthYearGuess, Person: s.personLite(p)})
+	}
+	sort.Slice(people, func(i, j int) bool {
+		a, b := monthDayOrder(people[i].BirthDate), monthDayOrder(people[j].BirthDate)
+		if a != b {
+			return a < b
+		}
+		return people[i].Person.Id < people[j].Person.Id
+	})
+	return people, nil
+}
+
+func (s *MemoryStore) LoadMemorials() ([]CalendarMemorial, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var memorials []CalendarMemorial
+	for _, p := range s.people {
+		deathDate := parseDateOrZero(p.Data.DeathDate)
+		if p.Data.IsAlive || deathDate.IsZero() {
+			continue
+		}
+		memorials = append(memorials, CalendarMemorial{DeathDate: deathDate, Person: s.personLite(p)})
+	}
+	sort.Slice(memorials, func(i, j int) bool {
+		a, b := monthDayOrder(memorials[i].DeathDate), monthDayOrder(memorials[j].DeathDate)
+		if a != b {
+			return a < b
+		}
+		return memorials[i].Person.Id < memorials[j].Person.Id
+	})
+	return memorials, nil
+}
+
+func (s *MemoryStore) InsertPerson(data PersonData) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data.BirthDate = strings.TrimSpace(data.BirthDate)
+	data.DeathDate = strings.TrimSpace(data.DeathDate)
+	item := memoryPerson{Id: s.newId(), Data: data}
+	s.people[item.Id] = &item
+	return item.Id, nil
+}
+
+func (s *MemoryStore) UpdatePerson(personId int, data PersonData) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p, found := s.people[personId]
+	if !found {
+		return nil
+	}
+	data.BirthDate = strings.TrimSpace(data.BirthDate)
+	data.DeathDate = strings.TrimSpace(data.DeathDate)
+	p.Data = data
+	return nil
+}
+
+func (s *MemoryStore) UpdatePersonParents(personId int, motherId int, fatherId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p, found := s.people[personId]
+	if !found {
+		return nil
+	}
+	p.Data.MotherId = motherId
+	p.Data.FatherId = fatherId
+	return nil
+}
+
+func (s *MemoryStore) DeletePerson(personId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.people, personId)
+	for id, name := range s.altNames {
+		if name.PersonId == personId {
+			delete(s.altNames, id)
+		}
+	}
+	return nil
+}
+
+// Alternate name
+
+func (s *MemoryStore) alternateNames(personId int) []AlternateName {
+	var list []AlternateName
+	for _, name := range s.altNames {
+		if name.PersonId == personId {
+			list = append(list, *name)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list
+}
+
+func (s *MemoryStore) LoadAlternateNames(personId int) ([]AlternateName, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.alternateNames(personId), nil
+}
+
+func (s *MemoryStore) InsertAlternateName(personId int, kind AlternateNameKind, name string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	id := s.newId()
+	s.altNames[id] = &AlternateName{Id: id, PersonId: personId, Kind: kind, Name: name}
+	return id, nil
+}
+
+func (s *MemoryStore) DeleteAlternateName(personId int, id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if name, found := s.altNames[id]; found && name.PersonId == personId {
+		delete(s.altNames, id)
+	}
+	return nil
+}
+
+// Spouse
+
+func orderedPair(person1Id int, person2Id int) (int, int) {
+	if person2Id < person1Id {
+		return person2Id, person1Id
+	}
+	return person1Id, person2Id
+}
+
+func (s *MemoryStore) LoadSpousesByPersonId(personId int) ([]SpouseLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.loadSpousesByPersonId(personId)
+}
+
+func (s *MemoryStore) loadSpousesByPersonId(personId int) ([]SpouseLite, error) {
+	person1, err := s.loadPersonLiteById(personId)
+	if err != nil {
+		return nil, err
+	}
+	var rows []memorySpouse
+	for _, row := range s.spouses {
+		if row.Person1Id == personId || row.Person2Id == personId {
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Status < rows[j].Status })
+
+	var spouseList []SpouseLite
+	for _, row := range rows {
+		otherId := row.Person2Id
+		if otherId == personId {
+			otherId = row.Person1Id
+		}
+		person2, err := s.loadPersonLiteById(otherId)
+		if err != nil {
+			return nil, err
+		}
+		spouseList = append(spouseList, SpouseLite{
+			Person1:     *person1,
+			Person2:     *person2,
+			Status:      row.Status,
+			MarriedDate: parseDateOrZero(row.MarriedDate),
+		})
+	}
+	return spouseList, nil
+}
+
+func (s *MemoryStore) LoadSpousesByPersonIds(personIds []int) ([]SpouseLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	lookup := intSet(personIds)
+	spouseList := s.spouseList(func(row memorySpouse) bool { return lookup[row.Person1Id] || lookup[row.Person2Id] })
+	sort.SliceStable(spouseList, func(i, j int) bool { return spouseList[i].Status < spouseList[j].Status })
+	return spouseList, nil
+}
+
+func (s *MemoryStore) LoadSpouseList() ([]SpouseLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.spouseList(func(row memorySpouse) bool { return true }), nil
+}
+
+// Relationships matching the filter, ordered by person1 and person2
+func (s *MemoryStore) spouseList(filter func(row memorySpouse) bool) []SpouseLite {
+	var spouseList []SpouseLite
+	for _, row := range s.spouses {
+		if !filter(row) {
+			continue
+		}
+		person1, found1 := s.people[row.Person1Id]
+		person2, found2 := s.people[row.Person2Id]
+		if !found1 || !found2 {
+			continue
+		}
+		spouseList = append(spouseList, SpouseLite{
+			Person1:     s.personLite(person1),
+			Person2:     s.personLite(person2),
+			Status:      row.Status,
+			MarriedDate: parseDateOrZero(row.MarriedDate),
+		})
+	}
+	sort.Slice(spouseList, func(i, j int) bool {
+		if spouseList[i].Person1.Id != spouseList[j].Person1.Id {
+			return spouseList[i].Person1.Id < spouseList[j].Person1.Id
+		}
+		return spouseList[i].Person2.Id < spouseList[j].Person2.Id
+	})
+	return spouseList
+}
+
+func (s *MemoryStore) LoadAnniversaries() ([]CalendarAnniversary, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var anniversaries []CalendarAnniversary
+	for _, row := range s.spouses {
+		person1, found1 := s.people[row.Person1Id]
+		person2, found2 := s.people[row.Person2Id]
+		marriedDate := parseDateOrZero(row.MarriedDate)
+		if !found1 || !found2 || row.Status != 1 || marriedDate.IsZero() {
+			continue
+		}
+		anniversaries = append(anniversaries, CalendarAnniversary{
+			MarriedDate: marriedDate,
+			Person1:     s.personLite(person1),
+			Person2:     s.personLite(person2),
+		})
+	}
+	sort.Slice(anniversaries, func(i, j int) bool {
+		return monthDayOrder(anniversaries[i].MarriedDate) < monthDayOrder(anniversaries[j].MarriedDate)
+	})
+	return anniversaries, nil
+}
+
+func (s *MemoryStore) SpouseExists(person1Id int, person2Id int) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	person1Id, person2Id = orderedPair(person1Id, person2Id)
+	for _, row := range s.spouses {
+		if row.Person1Id == person1Id && row.Person2Id == person2Id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) DeleteSpouse(person1Id int, person2Id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	person1Id, person2Id = orderedPair(person1Id, person2Id)
+	for i, row := range s.spouses {
+		if row.Person1Id == person1Id && row.Person2Id == person2Id {
+			s.spouses = append(s.spouses[:i], s.spouses[i+1:]...)
+			return nil
+		}
+	}
+	return notFoundf("Spouse not found with person1_id: %d, person2_id: %d", person1Id, person2Id)
+}
+
+func (s *MemoryStore) InsertSpouse(person1Id int, person2Id int, status int, marriedDate string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	person1Id, person2Id = orderedPair(person1Id, person2Id)
+	for _, row := range s.spouses {
+		if row.Person1Id == person1Id && row.Person2Id == person2Id {
+			return fmt.Errorf("Spouse already exists with person1_id: %d, person2_id: %d", person1Id, person2Id)
+		}
+	}
+	s.spouses = append(s.spouses, memorySpouse{
+		Person1Id:   person1Id,
+		Person2Id:   person2Id,
+		Status:      status,
+		MarriedDate: marriedDate,
+	})
+	return nil
+}
+
+func (s *MemoryStore) UpdateSpouse(person1Id int, person2Id int, status int, marriedDate string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	person1Id, person2Id = orderedPair(person1Id, person2Id)
+	for i, row := range s.spouses {
+		if row.Person1Id == person1Id && row.Person2Id == person2Id {
+			s.spouses[i].Status = status
+			s.spouses[i].MarriedDate = marriedDate
+			return nil
+		}
+	}
+	return notFoundf("Spouse not found with person1_id: %d, person2_id: %d", person1Id, person2Id)
+}
+
+// City
+
+// City joined with its region and country, like city_view.  Cities without
+// a region or country are hidden, as they are by the view's inner joins.
+func (s *MemoryStore) cityLite(city *memoryCity) (*CityLite, bool) {
+	region, found := s.regions[city.RegionId]
+	if !found {
+		return nil, false
+	}
+	if _, found := s.countries[region.CountryCode]; !found {
+		return nil, false
+	}
+	return &CityLite{
+		Id:          city.Id,
+		Name:        city.Name,
+		RegionId:    city.RegionId,
+		RegionAbbr:  region.Code,
+		CountryAbbr: region.CountryCode,
+		Latitude:    float64(city.Lat),
+		Longitude:   float64(city.Lng),
+	}, true
+}
+
+func (s *MemoryStore) cityList(filter func(city *CityLite) bool, less func(a *CityLite, b *CityLite) bool) []CityLite {
+	var cities []CityLite
+	for _, city := range s.cities {
+		item, visible := s.cityLite(city)
+		if visible && filter(item) {
+			cities = append(cities, *item)
+		}
+	}
+	sort.Slice(cities, func(i, j int) bool {
+		if less(&cities[i], &cities[j]) {
+			return true
+		}
+		if less(&cities[j], &cities[i]) {
+			return false
+		}
+		return cities[i].Id < cities[j].Id
+	})
+	return cities
+}
+
+func cityByName(a *CityLite, b *CityLite) bool {
+	return a.Name < b.Name
+}
+
+func (s *MemoryStore) LoadCityById(id int) (*CityLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.loadCityById(id)
+}
+
+func (s *MemoryStore) loadCityById(id int) (*CityLite, error) {
+	city, found := s.cities[id]
+	if found {
+		if item, visible := s.cityLite(city); visible {
+			return item, nil
+		}
+	}
+	return nil, notFoundf("Error, city not found (id: %d)", id)
+}
+
+func (s *MemoryStore) LoadCitiesByIds(ids []int) ([]CityLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	lookup := intSet(ids)
+	return s.cityList(func(city *CityLite) bool { return lookup[city.Id] }, func(a *CityLite, b *CityLite) bool { return false }), nil
+}
+
+func (s *MemoryStore) LoadCityList() ([]CityLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cityList(func(city *CityLite) bool { return true }, cityByName), nil
+}
+
+func (s *MemoryStore) LoadCitiesByRegionId(regionId int) ([]CityLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cityList(func(city *CityLite) bool { return city.RegionId == regionId }, cityByName), nil
+}
+
+func (s *MemoryStore) LoadCitiesByCountryCode(countryCode string) ([]CityLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cityList(
+		func(city *CityLite) bool { return strings.EqualFold(city.CountryAbbr, countryCode) },
+		func(a *CityLite, b *CityLite) bool {
+			if a.RegionAbbr != b.RegionAbbr {
+				return a.RegionAbbr < b.RegionAbbr
+			}
+			return a.Name < b.Name
+		}), nil
+}
+
+func (s *MemoryStore) LoadCitiesByName(name string) ([]CityLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.cityList(
+		func(city *CityLite) bool { return strings.EqualFold(city.Name, name) },
+		func(a *CityLite, b *CityLite) bool {
+			if a.CountryAbbr != b.CountryAbbr {
+				return a.CountryAbbr < b.CountryAbbr
+			}
+			return a.RegionAbbr < b.RegionAbbr
+		}), nil
+}
+
+func (s *MemoryStore) LoadCitiesByPrefix(prefix string, offset int) ([]CityLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	cities := s.cityList(
+		func(city *CityLite) bool { return hasPrefixFold(city.Name, prefix) },
+		func(a *CityLite, b *CityLite) bool {
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			if a.RegionAbbr != b.RegionAbbr {
+				return a.RegionAbbr < b.RegionAbbr
+			}
+			return a.CountryAbbr < b.CountryAbbr
+		})
+	return pageOf(cities, offset, 10), nil
+}
+
+func (s *MemoryStore) DeleteCity(id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.cities[id]; !found {
+		return notFoundf("City not found (id: %d)", id)
+	}
+	delete(s.cities, id)
+	return nil
+}
+
+func (s *MemoryStore) InsertCity(name string, regionId int, lat float32, lng float32) (*CityLite, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	city := memoryCity{Id: s.newId(), Name: name, RegionId: regionId, Lat: lat, Lng: lng}
+	s.cities[city.Id] = &city
+	return s.loadCityById(city.Id)
+}
+
+func (s *MemoryStore) UpdateCity(cityId int, name string, regionId int, lat float32, lng float32) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if city, found := s.cities[cityId]; found {
+		city.Name = name
+		city.RegionId = regionId
+		city.Lat = lat
+		city.Lng = lng
+	}
+	return nil
+}
+
+// Region
+
+// Region joined with its country, like region_view
+func (s *MemoryStore) regionLite(id int, region *RegionData) (*RegionLite, bool) {
+	country, found := s.countries[region.CountryCode]
+	if !found {
+		return nil, false
+	}
+	return &RegionLite{
+		Id:            id,
+		Code:          region.Code,
+		Name:          region.Name,
+		CountryCode:   country.Code,
+		CountryName:   country.Name,
+		HasRegionIcon: country.HasRegionIcons,
+	}, true
+}
+
+func (s *MemoryStore) regionList(filter func(region *RegionLite) bool) []RegionLite {
+	var regions []RegionLite
+	for id, region := range s.regions {
+		item, visible := s.regionLite(id, region)
+		if visible && filter(item) {
+			regions = append(regions, *item)
+		}
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].CountryName != regions[j].CountryName {
+			return regions[i].CountryName < regions[j].CountryName
+		}
+		if regions[i].Name != regions[j].Name {
+			return regions[i].Name < regions[j].Name
+		}
+		return regions[i].Id < regions[j].Id
+	})
+	return regions
+}
+
+func (s *MemoryStore) LoadRegionById(id int) (*RegionLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.loadRegionById(id)
+}
+
+func (s *MemoryStore) loadRegionById(id int) (*RegionLite, error) {
+	region, found := s.regions[id]
+	if found {
+		if item, visible := s.regionLite(id, region); visible {
+			return item, nil
+		}
+	}
+	return nil, notFoundf("Region not found (id: %d)", id)
+}
+
+func (s *MemoryStore) LoadRegionsByCountryCode(countryCode string) ([]RegionLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.regionList(func(region *RegionLite) bool { return strings.EqualFold(region.CountryCode, countryCode) }), nil
+}
+
+func (s *MemoryStore) LoadRegionList() ([]RegionLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.regionList(func(region *RegionLite) bool { return true }), nil
+}
+
+func (s *MemoryStore) DeleteRegion(regionId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.regions, regionId)
+	return nil
+}
+
+func (s *MemoryStore) InsertRegion(data RegionData) (*RegionLite, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	id := s.newId()
+	s.regions[id] = &data
+	return s.loadRegionById(id)
+}
+
+func (s *MemoryStore) UpdateRegion(regionId int, data RegionData) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.regions[regionId]; found {
+		s.regions[regionId] = &data
+	}
+	return nil
+}
+
+// Country
+
+// Country joined with its continent and capital city.  Countries without a
+// continent are hidden, as they are by the inner join in the MySQL queries.
+func (s *MemoryStore) country(data *CountryData) (*Country, bool) {
+	continent, found := s.continents[data.ContinentCode]
+	if !found {
+		return nil, false
+	}
+	item := Country{
+		Code:           data.Code,
+		Name:           data.Name,
+		Gdp:            data.Gdp,
+		Population:     data.Population,
+		HasRegionIcons: data.HasRegionIcons,
+		Continent:      &Continent{Code: continent.Code, Name: continent.Name},
+	}
+	if capital, err := s.loadCityById(data.CapitalCityId); err == nil {
+		item.CapitalCity = &CityLite{
+			Id:         capital.Id,
+			Name:       capital.Name,
+			RegionId:   capital.RegionId,
+			RegionAbbr: capital.RegionAbbr,
+		}
+	}
+	return &item, true
+}
+
+func (s *MemoryStore) countryList(filter func(country *Country) bool) []Country {
+	var countries []Country
+	for _, data := range s.countries {
+		item, visible := s.country(data)
+		if visible && filter(item) {
+			countries = append(countries, *item)
+		}
+	}
+	sort.Slice(countries, func(i, j int) bool { return countries[i].Name < countries[j].Name })
+	return countries
+}
+
+func (s *MemoryStore) LoadCountriesByContinentCode(continentCode string) ([]Country, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.countryList(func(country *Country) bool { return strings.EqualFold(country.Continent.Code, continentCode) }), nil
+}
+
+func (s *MemoryStore) LoadCountryByCode(code string) (*Country, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, data := range s.countries {
+		if strings.EqualFold(data.Code, code) {
+			if item, visible := s.country(data); visible {
+				return item, nil
+			}
+		}
+	}
+	return nil, notFoundf("Country not found. Code: %s", code)
+}
+
+func (s *MemoryStore) LoadCountryList() ([]Country, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.countryList(func(country *Country) bool { return true }), nil
+}
+
+func (s *MemoryStore) DeleteCountryByCode(code string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.countries[code]; !found {
+		return notFoundf("Country not found, code: %s", code)
+	}
+	delete(s.countries, code)
+	return nil
+}
+
+func (s *MemoryStore) InsertCountry(data CountryData) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.countries[data.Code]; found {
+		return fmt.Errorf("Country already exists, code: %s", data.Code)
+	}
+	s.countries[data.Code] = &data
+	return nil
+}
+
+func (s *MemoryStore) UpdateCountry(originalCode string, data CountryData) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.countries[originalCode]; !found {
+		return nil
+	}
+	delete(s.countries, originalCode)
+	s.countries[data.Code] = &data
+	return nil
+}
+
+// Continent
+
+func (s *MemoryStore) LoadContinentList() ([]Continent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var list []Continent
+	for _, continent := range s.continents {
+		list = append(list, Continent{Code: continent.Code, Name: continent.Name, Color: continent.Color})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (s *MemoryStore) LoadContinentByCode(code string) (*ContinentWithMap, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	continent, found := s.continents[code]
+	if !found {
+		return nil, notFoundf("Error continent not found. Code: %s", code)
+	}
+	item := *continent
+	return &item, nil
+}
+
+func (s *MemoryStore) InsertContinent(data ContinentWithMap) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.continents[data.Code]; found {
+		return fmt.Errorf("Continent already exists. Code: %s", data.Code)
+	}
+	s.continents[data.Code] = &data
+	return nil
+}
+
+func (s *MemoryStore) UpdateContinent(originalCode string, data ContinentWithMap) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.continents[originalCode]; !found {
+		return notFoundf("Error continent not found. Code: %s", originalCode)
+	}
+	delete(s.continents, originalCode)
+	s.continents[data.Code] = &data
+	return nil
+}
+
+func (s *MemoryStore) DeleteContinent(code string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.continents, code)
+	return nil
+}
+
+// Tag
+
+func (s *MemoryStore) InsertTag(label string) (*Tag, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.loadTagByLabel(label) != nil {
+		return nil, fmt.Errorf("Tag already exists (%+v)", label)
+	}
+	tag := Tag{Id: s.newId(), Label: label}
+	s.tags[tag.Id] = &tag
+	item := tag
+	return &item, nil
+}
+
+func (s *MemoryStore) LoadTagById(tagId int) (*Tag, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	tag, found := s.tags[tagId]
+	if !found {
+		return nil, notFoundf("Tag not found (%+v)", tagId)
+	}
+	item := *tag
+	return &item, nil
+}
+
+func (s *MemoryStore) LoadTagByLabel(label string) (*Tag, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	tag := s.loadTagByLabel(label)
+	if tag == nil {
+		return nil, notFoundf("Tag not found (%+v)", label)
+	}
+	item := *tag
+	return &item, nil
+}
+
+func (s *MemoryStore) loadTagByLabel(label string) *Tag {
+	for _, tag := range s.tags {
+		if strings.EqualFold(tag.Label, label) {
+			return tag
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) InsertPeopleTag(tagId int, personId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.peopleTags[[2]int{personId, tagId}] = true
+	return nil
+}
+
+func (s *MemoryStore) tagList(filter func(tag *Tag) bool) []Tag {
+	var tags []Tag
+	for _, tag := range s.tags {
+		if filter(tag) {
+			tags = append(tags, *tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Label < tags[j].Label })
+	return tags
+}
+
+func (s *MemoryStore) LoadTagsListByPrefix(labelPrefix string) ([]Tag, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.tagList(func(tag *Tag) bool { return hasPrefixFold(tag.Label, labelPrefix) }), nil
+}
+
+func (s *MemoryStore) LoadTagsForPerson(personId int) ([]Tag, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.tagList(func(tag *Tag) bool { return s.peopleTags[[2]int{personId, tag.Id}] }), nil
+}
+
+func (s *MemoryStore) LoadTagsByPerson() (map[int][]Tag, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	lookup := make(map[int][]Tag)
+	for _, tag := range s.tagList(func(tag *Tag) bool { return true }) {
+		for key := range s.peopleTags {
+			if key[1] == tag.Id {
+				lookup[key[0]] = append(lookup[key[0]], tag)
+			}
+		}
+	}
+	return lookup, nil
+}
+
+func (s *MemoryStore) DeletePeopleTag(tagId int, personId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.peopleTags, [2]int{personId, tagId})
+	return nil
+}
+
+func (s *MemoryStore) UpdateTag(tagId int, label string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tag, found := s.tags[tagId]
+	if !found {
+		return notFoundf("Tag not found (%+v)", tagId)
+	}
+	if other := s.loadTagByLabel(label); other != nil && other.Id != tagId {
+		return fmt.Errorf("Tag already exists (%+v)", label)
+	}
+	tag.Label = label
+	return nil
+}
+
+func (s *MemoryStore) DeleteTag(tagId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.tags, tagId)
+	for key := range s.peopleTags {
+		if key[1] == tagId {
+			delete(s.peopleTags, key)
+		}
+	}
+	return nil
+}
+
+// Holiday
+
+func (s *MemoryStore) holidayList(filter func(date time.Time) bool) []Holiday {
+	var holidays []Holiday
+	for _, item := range s.holidayItems {
+		data, found := s.holidays[item.HolidayId]
+		if found && filter(item.Date) {
+			holidays = append(holidays, Holiday{Id: item.HolidayId, Date: item.Date, Name: data.Name, CountryCode: data.CountryCode})
+		}
+	}
+	sort.SliceStable(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays
+}
+
+func (s *MemoryStore) LoadHolidays(startYear int) ([]Holiday, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.holidayList(func(date time.Time) bool { return date.Year() >= startYear }), nil
+}
+
+func (s *MemoryStore) LoadHolidaysInRange(startTime time.Time, endTime time.Time) ([]Holiday, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	start := startTime.Format("2006-01-02")
+	end := endTime.Format("2006-01-02")
+	return s.holidayList(func(date time.Time) bool {
+		day := date.Format("2006-01-02")
+		return day >= start && day < end
+	}), nil
+}
+
+func (s *MemoryStore) holidayDefinition(id int, data *HolidayData) HolidayDefinition {
+	definition := HolidayDefinition{Id: id, HolidayData: *data}
+	for _, item := range s.holidayItems {
+		if item.HolidayId == id {
+			definition.Dates = append(definition.Dates, item.Date)
+		}
+	}
+	sort.Slice(definition.Dates, func(i, j int) bool { return definition.Dates[i].Before(definition.Dates[j]) })
+	return definition
+}
+
+func (s *MemoryStore) LoadHolidayList() ([]HolidayDefinition, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var definitions []HolidayDefinition
+	for id, data := range s.holidays {
+		definitions = append(definitions, s.holidayDefinition(id, data))
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		if definitions[i].Name != definitions[j].Name {
+			return definitions[i].Name < definitions[j].Name
+		}
+		return definitions[i].Id < definitions[j].Id
+	})
+	return definitions, nil
+}
+
+func (s *MemoryStore) LoadHolidayById(id int) (*HolidayDefinition, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	data, found := s.holidays[id]
+	if !found {
+		return nil, notFoundf("Holiday not found. Id: %d", id)
+	}
+	definition := s.holidayDefinition(id, data)
+	return &definition, nil
+}
+
+func (s *MemoryStore) InsertHoliday(data HolidayData) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	id := s.newId()
+	s.holidays[id] = &data
+	return id, nil
+}
+
+func (s *MemoryStore) UpdateHoliday(id int, data HolidayData) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.holidays[id]; !found {
+		return notFoundf("Holiday not found. Id: %d", id)
+	}
+	s.holidays[id] = &data
+	return nil
+}
+
+func (s *MemoryStore) DeleteHoliday(id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.holidays, id)
+	s.deleteHolidayItems(func(item memoryHolidayItem) bool { return item.HolidayId == id })
+	return nil
+}
+
+func (s *MemoryStore) deleteHolidayItems(matches func(item memoryHolidayItem) bool) {
+	items := s.holidayItems[:0]
+	for _, item := range s.holidayItems {
+		if !matches(item) {
+			items = append(items, item)
+		}
+	}
+	s.holidayItems = items
+}
+
+func (s *MemoryStore) InsertHolidayItem(holidayId int, date time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	day := date.Format("2006-01-02")
+	for _, item := range s.holidayItems {
+		if item.HolidayId == holidayId && item.Date.Format("2006-01-02") == day {
+			return nil
+		}
+	}
+	s.holidayItems = append(s.holidayItems, memoryHolidayItem{HolidayId: holidayId, Date: date})
+	return nil
+}
+
+func (s *MemoryStore) DeleteHolidayItem(holidayId int, date time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	day := date.Format("2006-01-02")
+	s.deleteHolidayItems(func(item memoryHolidayItem) bool {
+		return item.HolidayId == holidayId && item.Date.Format("2006-01-02") == day
+	})
+	return nil
+}
+
+func (s *MemoryStore) DeleteHolidayItems(holidayId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deleteHolidayItems(func(item memoryHolidayItem) bool { return item.HolidayId == holidayId })
+	return nil
+}
+
+// User
+
+func (s *MemoryStore) LoadUserById(id int) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	user, found := s.users[id]
+	if !found {
+		return nil, notFoundf("User not found with id: %d", id)
+	}
+	item := *user
+	item.passwordHash = ""
+	return &item, nil
+}
+
+func (s *MemoryStore) LoadUserByEmail(email string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			item := *user
+			return &item, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) LoadUserList() ([]User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var users []User
+	for _, user := range s.users {
+		item := *user
+		item.passwordHash = ""
+		users = append(users, item)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Name != users[j].Name {
+			return users[i].Name < users[j].Name
+		}
+		return users[i].Email < users[j].Email
+	})
+	return users, nil
+}
+
+func (s *MemoryStore) InsertUser(email string, name string, role Role, passwordHash string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			return 0, fmt.Errorf("User already exists with email: %s", email)
+		}
+	}
+	user := User{Id: s.newId(), Email: email, Name: name, Role: role, passwordHash: passwordHash}
+	s.users[user.Id] = &user
+	return user.Id, nil
+}
+
+func (s *MemoryStore) UpdateUserPassword(userId int, passwordHash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if user, found := s.users[userId]; found {
+		user.passwordHash = passwordHash
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateUserRole(userId int, role Role) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if user, found := s.users[userId]; found {
+		user.Role = role
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateUserCalendarToken(userId int, tokenHash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for hash, id := range s.feedTokens {
+		if id == userId {
+			delete(s.feedTokens, hash)
+		}
+	}
+	s.feedTokens[tokenHash] = userId
+	return nil
+}
+
+func (s *MemoryStore) LoadUserByCalendarToken(tokenHash string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	userId, found := s.feedTokens[tokenHash]
+	if !found {
+		return nil, nil
+	}
+	user, found := s.users[userId]
+	if !found {
+		return nil, nil
+	}
+	item := *user
+	item.passwordHash = ""
+	return &item, nil
+}
+
+func (s *MemoryStore) InsertSession(tokenHash string, userId int, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sessions[tokenHash] = memorySession{UserId: userId, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryStore) LoadUserBySession(tokenHash string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	session, found := s.sessions[tokenHash]
+	if !found || !session.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	user, found := s.users[session.UserId]
+	if !found {
+		return nil, nil
+	}
+	item := *user
+	item.passwordHash = ""
+	return &item, nil
+}
+
+func (s *MemoryStore) DeleteSession(tokenHash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.sessions, tokenHash)
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpiredSessions() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for tokenHash, session := range s.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(s.sessions, tokenHash)
+		}
+	}
+	return nil
+}
+
+// Favorite
+
+func (s *MemoryStore) LoadFavoritePeople(userId int) ([]PersonLite, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.personLiteList(func(p *memoryPerson) bool { return s.favorites[[2]int{userId, p.Id}] }), nil
+}
+
+func (s *MemoryStore) LoadFavoritePersonIds(userId int) (map[int]bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	lookup := make(map[int]bool)
+	for key := range s.favorites {
+		if key[0] == userId {
+			lookup[key[1]] = true
+		}
+	}
+	return lookup, nil
+}
+
+func (s *MemoryStore) InsertFavoritePerson(userId int, personId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.favorites[[2]int{userId, personId}] = true
+	return nil
+}
+
+func (s *MemoryStore) DeleteFavoritePerson(userId int, personId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.favorites, [2]int{userId, personId})
+	return nil
+}
+
+func intSet(values []int) map[int]bool {
+	set := make(map[int]bool)
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// The page of items starting at offset, like LIMIT offset, count
+func pageOf[T any](items []T, offset int, count int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	end := offset + count
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
+
+// Reminder recipient
+
+func (s *MemoryStore) reminderRecipient(id int, data *ReminderRecipientData) ReminderRecipient {
+	recipient := ReminderRecipient{Id: id, ReminderRecipientData: *data}
+	recipient.TagIds = append([]int(nil), data.TagIds...)
+	recipient.BranchPersonIds = append([]int(nil), data.BranchPersonIds...)
+	tagIds := intSet(data.TagIds)
+	recipient.Tags = s.tagList(func(tag *Tag) bool { return tagIds[tag.Id] })
+	for _, personId := range data.BranchPersonIds {
+		if p, found := s.people[personId]; found {
+			recipient.Branches = append(recipient.Branches, s.personLite(p))
+		}
+	}
+	return recipient
+}
+
+func (s *MemoryStore) LoadReminderRecipientList() ([]ReminderRecipient, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var recipients []ReminderRecipient
+	for id, data := range s.recipients {
+		recipients = append(recipients, s.reminderRecipient(id, data))
+	}
+	sort.Slice(recipients, func(i, j int) bool {
+		if recipients[i].Name != recipients[j].Name {
+			return recipients[i].Name < recipients[j].Name
+		}
+		return recipients[i].Email < recipients[j].Email
+	})
+	return recipients, nil
+}
+
+func (s *MemoryStore) LoadReminderRecipientById(id int) (*ReminderRecipient, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	data, found := s.recipients[id]
+	if !found {
+		return nil, notFoundf("Reminder recipient not found with id: %d", id)
+	}
+	recipient := s.reminderRecipient(id, data)
+	return &recipient, nil
+}
+
+func (s *MemoryStore) InsertReminderRecipient(data ReminderRecipientData) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	id := s.newId()
+	data.TagIds = uniqueInts(data.TagIds)
+	data.BranchPersonIds = uniqueInts(data.BranchPersonIds)
+	s.recipients[id] = &data
+	return id, nil
+}
+
+func (s *MemoryStore) UpdateReminderRecipient(id int, data ReminderRecipientData) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.recipients[id]; found {
+		data.TagIds = uniqueInts(data.TagIds)
+		data.BranchPersonIds = uniqueInts(data.BranchPersonIds)
+		s.recipients[id] = &data
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteReminderRecipient(id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.recipients, id)
+	return nil
+}
+
+// Reminder run
+
+func reminderEmailKey(weekStart time.Time, recipientId int) string {
+	return fmt.Sprintf("%s/%d", weekStart.Format("2006-01-02"), recipientId)
+}
+
+func (s *MemoryStore) InsertReminderRun(weekStart time.Time, startedAt time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	run := ReminderRun{Id: s.newId(), WeekStart: weekStart, StartedAt: startedAt}
+	s.runs = append(s.runs, run)
+	return run.Id, nil
+}
+
+func (s *MemoryStore) FinishReminderRun(run *ReminderRun) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.runs {
+		if s.runs[i].Id == run.Id {
+			s.runs[i] = *run
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) LoadReminderRunList(limit int) ([]ReminderRun, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var runs []ReminderRun
+	for i := len(s.runs) - 1; i >= 0 && len(runs) < limit; i-- {
+		runs = append(runs, s.runs[i])
+	}
+	return runs, nil
+}
+
+func (s *MemoryStore) ClaimReminderEmail(runId int, weekStart time.Time, recipientId int, email string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := reminderEmailKey(weekStart, recipientId)
+	if claim, found := s.runEmails[key]; found && !isStaleReminderClaim(claim.ClaimedAt, claim.SentAt, time.Now()) {
+		return false, nil
+	}
+	s.runEmails[key] = memoryReminderEmail{RunId: runId, ClaimedAt: time.Now()}
+	return true, nil
+}
+
+func (s *MemoryStore) MarkReminderEmailSent(weekStart time.Time, recipientId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := reminderEmailKey(weekStart, recipientId)
+	claim, found := s.runEmails[key]
+	if !found {
+		return fmt.Errorf("No reminder email claimed for recipient %d", recipientId)
+	}
+	sentAt := time.Now()
+	claim.SentAt = &sentAt
+	s.runEmails[key] = claim
+	return nil
+}
+
+func (s *MemoryStore) ReleaseReminderEmail(weekStart time.Time, recipientId int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.runEmails, reminderEmailKey(weekStart, recipientId))
+	return nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Numbered schema migrations, named NNNN_description.up.sql and NNNN_description.down.sql
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version   int
+	Name      string
+	UpSql     string
+	DownSql   string
+	AppliedAt *time.Time
+}
+
+func (m *Migration) IsApplied() bool {
+	return m.AppliedAt != nil
+}
+
+// Load the embedded migrations in version order
+func LoadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	lookup := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("Migration file %s must end in .up.sql or .down.sql", fileName)
+		}
+		baseName := strings.TrimSuffix(fileName, "."+direction+".sql")
+		parts := strings.SplitN(baseName, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("Migration file %s must start with a version number", fileName)
+		}
+		contents, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, found := lookup[version]
+		if !found {
+			migration = &Migration{Version: version, Name: parts[1]}
+			lookup[version] = migration
+		} else if migration.Name != parts[1] {
+			return nil, fmt.Errorf("Migration version %d is used by both %s and %s", version, migration.Name, parts[1])
+		}
+		if direction == "up" {
+			migration.UpSql = string(contents)
+		} else {
+			migration.DownSql = string(contents)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range lookup {
+		if migration.UpSql == "" {
+			return nil, fmt.Errorf("Migration %04d_%s is missing its .up.sql file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Load the embedded migrations, marking the ones already applied to the database
+func LoadMigrationStatus(db *sql.DB) ([]Migration, error) {
+	defer trace(traceName("LoadMigrationStatus"))
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	err = createMigrationsTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAtString string
+		err = rows.Scan(&version, &appliedAtString)
+		if err != nil {
+			return nil, err
+		}
+		applied[version], _ = time.Parse("2006-01-02 15:04:05", appliedAtString)
+	}
+
+	for i := range migrations {
+		if appliedAt, found := applied[migrations[i].Version]; found {
+			migrations[i].AppliedAt = &appliedAt
+		}
+	}
+	return migrations, nil
+}
+
+// Apply every migration that hasn't been applied yet
+func MigrateUp(db *sql.DB) error {
+	defer trace(traceName("MigrateUp"))
+	return withMigrationLock(db, func() error {
+		err := baselineExistingSchema(db)
+		if err != nil {
+			return err
+		}
+		migrations, err := LoadMigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if migration.IsApplied() {
+				continue
+			}
+			log.Printf("Applying migration %04d_%s", migration.Version, migration.Name)
+			err = execMigrationSql(db, migration.UpSql)
+			if err != nil {
+				return fmt.Errorf("Error applying migration %04d_%s: %v", migration.Version, migration.Name, err)
+			}
+			err = recordMigration(db, migration)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func recordMigration(db *sql.DB, migration Migration) error {
+	_, err := db.Exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	return err
+}
+
+// Name of the MySQL lock held while migrating, so servers starting at the
+// same time don't both apply the same migration
+const migrationLockName = "family_migrate"
+
+// Longest to wait for another server to finish migrating, in seconds
+const migrationLockTimeout = 300
+
+// Run fn holding the migration lock.  MySQL locks belong to a connection, so
+// one is kept aside from the pool until fn returns.
+func withMigrationLock(db *sql.DB, fn func() error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockTimeout).Scan(&locked)
+	if err != nil {
+		return fmt.Errorf("Error taking the migration lock: %v", err)
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("Timed out waiting for another server to finish migrating")
+	}
+	defer conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName).Scan(&locked)
+	return fn()
+}
+
+// Databases created before there were migrations already have the changes
+// of the first few.  Each of these reports whether its migration's changes
+// are there, so they can be recorded as applied rather than run again.
+var baselineMigrations = map[int]func(db *sql.DB) (bool, error){
+	1: func(db *sql.DB) (bool, error) {
+		return tableExists(db, "people")
+	},
+	2: func(db *sql.DB) (bool, error) {
+		return tableExists(db, "tags")
+	},
+	3: func(db *sql.DB) (bool, error) {
+		found, err := tableExists(db, "holiday_items")
+		if err != nil || !found {
+			return false, err
+		}
+		found, err = columnExists(db, "holidays", "date")
+		return !found, err
+	},
+}
+
+// Record the baseline migrations whose changes a database already has, when
+// it has no migrations recorded at all
+func baselineExistingSchema(db *sql.DB) error {
+	migrations, err := LoadMigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		if migration.IsApplied() {
+			return nil
+		}
+	}
+	for _, migration := range migrations {
+		check, found := baselineMigrations[migration.Version]
+		if !found {
+			return nil
+		}
+		present, err := check(db)
+		if err != nil {
+			return err
+		}
+		if !present {
+			return nil
+		}
+		log.Printf("Recording migration %04d_%s as applied, the database already has it", migration.Version, migration.Name)
+		err = recordMigration(db, migration)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tableExists(db *sql.DB, table string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM information_schema.tables"+
+			" WHERE table_schema=DATABASE() AND table_name=?",
+		table).Scan(&count)
+	return count > 0, err
+}
+
+func columnExists(db *sql.DB, table string, column string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM information_schema.columns"+
+			" WHERE table_schema=DATABASE() AND table_name=? AND column_name=?",
+		table, column).Scan(&count)
+	return count > 0, err
+}
+
+// Roll back the most recently applied migrations
+func MigrateDown(db *sql.DB, steps int) error {
+	defer trace(traceName(fmt.Sprintf("MigrateDown(%d)", steps)))
+	return withMigrationLock(db, func() error {
+		return migrateDown(db, steps)
+	})
+}
+
+func migrateDown(db *sql.DB, steps int) error {
+	migrations, err := LoadMigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		migration := migrations[i]
+		if !migration.IsApplied() {
+			continue
+		}
+		if migration.DownSql == "" {
+			return fmt.Errorf("Migration %04d_%s can not be rolled back", migration.Version, migration.Name)
+		}
+		log.Printf("Rolling back migration %04d_%s", migration.Version, migration.Name)
+		err = execMigrationSql(db, migration.DownSql)
+		if err != nil {
+			return fmt.Errorf("Error rolling back migration %04d_%s: %v", migration.Version, migration.Name, err)
+		}
+		_, err = db.Exec("DELETE FROM schema_migrations WHERE version=?", migration.Version)
+		if err != nil {
+			return err
+		}
+		steps--
+	}
+	return nil
+}
+
+func createMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(
+		"CREATE TABLE IF NOT EXISTS schema_migrations (" +
+			" version int(11) NOT NULL," +
+			" name varchar(100) NOT NULL," +
+			" applied_at datetime NOT NULL," +
+			" PRIMARY KEY (version)" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8")
+	return err
+}
+
+// The MySQL driver runs one statement per Exec, so split the file on the
+// semicolons that end a line.
+func execMigrationSql(db *sql.DB, contents string) error {
+	var statement strings.Builder
+	for _, line := range strings.Split(contents, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		statement.WriteString(line)
+		statement.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			_, err := db.Exec(statement.String())
+			if err != nil {
+				return err
+			}
+			statement.Reset()
+		}
+	}
+	if strings.TrimSpace(statement.String()) != "" {
+		_, err := db.Exec(statement.String())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+DROP VIEW IF EXISTS `region_view`;
+DROP VIEW IF EXISTS `city_view`;
+DROP TABLE IF EXISTS `spouses`;
+DROP TABLE IF EXISTS `regions`;
+DROP TABLE IF EXISTS `people`;
+DROP TABLE IF EXISTS `holidays`;
+DROP TABLE IF EXISTS `favorite_people`;
+DROP TABLE IF EXISTS `countries`;
+DROP TABLE IF EXISTS `continents`;
+DROP TABLE IF EXISTS `cities`;
@@ -1,8 +1,4 @@
-CREATE DATABASE IF NOT EXISTS `family` /*!40100 DEFAULT CHARACTER SET utf8 */;
-
-USE `family`;
-
-CREATE TABLE `cities` (
+CREATE TABLE IF NOT EXISTS `cities` (
   `id` int(11) NOT NULL AUTO_INCREMENT,
   `region_id` int(11) DEFAULT NULL,
   `name` varchar(45) DEFAULT NULL,
@@ -11,7 +7,7 @@ CREATE TABLE `cities` (
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `continents` (
+CREATE TABLE IF NOT EXISTS `continents` (
   `code` varchar(2) NOT NULL,
   `name` varchar(45) DEFAULT NULL,
   `map_lat` decimal(10,8) NOT NULL DEFAULT '0.00000000',
@@ -21,7 +17,7 @@ CREATE TABLE `continents` (
   PRIMARY KEY (`code`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `countries` (
+CREATE TABLE IF NOT EXISTS `countries` (
   `code` char(2) NOT NULL,
   `name` varchar(45) DEFAULT NULL,
   `capital_city_id` int(11) DEFAULT NULL,
@@ -32,20 +28,20 @@ CREATE TABLE `countries` (
   PRIMARY KEY (`code`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `favorite_people` (
+CREATE TABLE IF NOT EXISTS `favorite_people` (
   `user_id` int(11) NOT NULL,
   `person_id` int(11) NOT NULL,
   PRIMARY KEY (`user_id`,`person_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `holidays` (
+CREATE TABLE IF NOT EXISTS `holidays` (
   `id` int(11) NOT NULL AUTO_INCREMENT,
   `name` varchar(45) NOT NULL,
   `date` date NOT NULL,
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `people` (
+CREATE TABLE IF NOT EXISTS `people` (
   `id` int(11) NOT NULL AUTO_INCREMENT,
   `first_name` varchar(100) NOT NULL,
   `middle_name` varchar(100) NOT NULL,
@@ -62,7 +58,7 @@ CREATE TABLE `people` (
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `regions` (
+CREATE TABLE IF NOT EXISTS `regions` (
   `id` int(11) NOT NULL AUTO_INCREMENT,
   `country_code` char(2) DEFAULT NULL,
   `code` varchar(8) DEFAULT NULL,
@@ -71,7 +67,7 @@ CREATE TABLE `regions` (
   KEY `countryCodeIdx` (`country_code`,`name`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `spouses` (
+CREATE TABLE IF NOT EXISTS `spouses` (
   `person1_id` int(11) NOT NULL,
   `person2_id` int(11) NOT NULL,
   `status` int(11) NOT NULL,
@@ -79,7 +75,7 @@ CREATE TABLE `spouses` (
   PRIMARY KEY (`person1_id`,`person2_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE VIEW `city_view` AS
+CREATE OR REPLACE VIEW `city_view` AS
     SELECT
         `ci`.`id` AS `city_id`,
         `ci`.`name` AS `city_name`,
@@ -91,11 +87,11 @@ CREATE VIEW `city_view` AS
         `co`.`code` AS `country_code`,
         `co`.`name` AS `country_name`
     FROM
-        ((`family`.`cities` `ci`
-        JOIN `family`.`regions` `r` ON ((`ci`.`region_id` = `r`.`id`)))
-        JOIN `family`.`countries` `co` ON ((`co`.`code` = `r`.`country_code`)));
+        ((`cities` `ci`
+        JOIN `regions` `r` ON ((`ci`.`region_id` = `r`.`id`)))
+        JOIN `countries` `co` ON ((`co`.`code` = `r`.`country_code`)));
 
-CREATE VIEW `region_view` AS
+CREATE OR REPLACE VIEW `region_view` AS
     SELECT
         `r`.`id` AS `region_id`,
         `r`.`name` AS `region_name`,
@@ -104,6 +100,6 @@ CREATE VIEW `region_view` AS
         `c`.`name` AS `country_name`,
         `c`.`has_region_icons` AS `has_region_icon`
     FROM
-        (`family`.`regions` `r`
-        JOIN `family`.`countries` `c` ON ((`c`.`code` = `r`.`country_code`)))
+        (`regions` `r`
+        JOIN `countries` `c` ON ((`c`.`code` = `r`.`country_code`)))
     ORDER BY `c`.`name`,`r`.`name`;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `tags` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `label` varchar(100) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `labelIdx` (`label`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `people_tags` (
+  `person_id` int(11) NOT NULL,
+  `tag_id` int(11) NOT NULL,
+  PRIMARY KEY (`person_id`,`tag_id`),
+  KEY `tagIdx` (`tag_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,6 @@
+ALTER TABLE `holidays` ADD COLUMN `date` date DEFAULT NULL;
+
+UPDATE `holidays` h
+  SET h.`date` = (SELECT MIN(hi.`date`) FROM `holiday_items` hi WHERE hi.`holiday_id` = h.`id`);
+
+DROP TABLE IF EXISTS `holiday_items`;
@@ -0,0 +1,14 @@
+-- Holidays move from a single date to one row per occurrence
+CREATE TABLE IF NOT EXISTS `holiday_items` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `holiday_id` int(11) NOT NULL,
+  `date` date NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `holidayIdx` (`holiday_id`),
+  KEY `dateIdx` (`date`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT INTO `holiday_items` (`holiday_id`, `date`)
+  SELECT `id`, `date` FROM `holidays`;
+
+ALTER TABLE `holidays` DROP COLUMN `date`;
@@ -0,0 +1,5 @@
+ALTER TABLE `people`
+  DROP COLUMN `burial_city_id`,
+  DROP COLUMN `death_city_id`,
+  DROP COLUMN `is_death_year_guess`,
+  DROP COLUMN `death_date`;
@@ -0,0 +1,5 @@
+ALTER TABLE `people`
+  ADD COLUMN `death_date` date DEFAULT NULL AFTER `is_alive`,
+  ADD COLUMN `is_death_year_guess` tinyint(1) NOT NULL DEFAULT '0' AFTER `death_date`,
+  ADD COLUMN `death_city_id` int(11) DEFAULT NULL AFTER `birth_city_id`,
+  ADD COLUMN `burial_city_id` int(11) DEFAULT NULL AFTER `death_city_id`;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `email` varchar(255) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `password_hash` varchar(255) NOT NULL,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `emailIdx` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- Only a hash of the session token is stored, the token itself lives in the cookie
+CREATE TABLE IF NOT EXISTS `sessions` (
+  `token_hash` char(64) NOT NULL,
+  `user_id` int(11) NOT NULL,
+  `expires_at` datetime NOT NULL,
+  PRIMARY KEY (`token_hash`),
+  KEY `userIdx` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,5 @@
+ALTER TABLE `users`
+  ADD COLUMN `role` enum('viewer','editor','admin') NOT NULL DEFAULT 'viewer' AFTER `name`;
+
+-- Everyone could do everything before roles, so keep existing users as admins
+UPDATE `users` SET `role` = 'admin';
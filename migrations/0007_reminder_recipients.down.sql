@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `reminder_recipient_branches`;
+DROP TABLE IF EXISTS `reminder_recipient_tags`;
+DROP TABLE IF EXISTS `reminder_recipients`;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS `reminder_recipients` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `email` varchar(255) NOT NULL,
+  `birthdays` tinyint(1) NOT NULL DEFAULT 1,
+  `anniversaries` tinyint(1) NOT NULL DEFAULT 1,
+  `holidays` tinyint(1) NOT NULL DEFAULT 1,
+  `memorials` tinyint(1) NOT NULL DEFAULT 0,
+  `lead_days` int(11) NOT NULL DEFAULT 28,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- Recipients with tags or branches only hear about the people in them
+CREATE TABLE IF NOT EXISTS `reminder_recipient_tags` (
+  `recipient_id` int(11) NOT NULL,
+  `tag_id` int(11) NOT NULL,
+  PRIMARY KEY (`recipient_id`,`tag_id`),
+  KEY `tagIdx` (`tag_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- A branch is a person along with their descendants and their spouses
+CREATE TABLE IF NOT EXISTS `reminder_recipient_branches` (
+  `recipient_id` int(11) NOT NULL,
+  `person_id` int(11) NOT NULL,
+  PRIMARY KEY (`recipient_id`,`person_id`),
+  KEY `personIdx` (`person_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `reminder_run_emails`;
+DROP TABLE IF EXISTS `reminder_runs`;
@@ -0,0 +1,24 @@
+-- Each time the reminder job runs, for the week starting on week_start
+CREATE TABLE IF NOT EXISTS `reminder_runs` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `week_start` date NOT NULL,
+  `started_at` datetime NOT NULL,
+  `finished_at` datetime DEFAULT NULL,
+  `emails_sent` int(11) NOT NULL DEFAULT 0,
+  `emails_skipped` int(11) NOT NULL DEFAULT 0,
+  `error` text,
+  PRIMARY KEY (`id`),
+  KEY `weekStartIdx` (`week_start`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- Each email delivered by a run.  A recipient is claimed here before their
+-- email is sent, so the same week can't be sent to them twice.
+CREATE TABLE IF NOT EXISTS `reminder_run_emails` (
+  `week_start` date NOT NULL,
+  `recipient_id` int(11) NOT NULL,
+  `run_id` int(11) NOT NULL,
+  `email` varchar(255) NOT NULL,
+  `sent_at` datetime NOT NULL,
+  PRIMARY KEY (`week_start`,`recipient_id`),
+  KEY `runIdx` (`run_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
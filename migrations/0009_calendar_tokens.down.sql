@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+  DROP INDEX `calendarTokenIdx`,
+  DROP COLUMN `calendar_token_hash`;
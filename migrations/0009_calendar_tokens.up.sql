@@ -0,0 +1,5 @@
+-- Lets calendar apps fetch the .ics feeds without a session.  Only a hash of
+-- the token is stored, like sessions.
+ALTER TABLE `users`
+  ADD COLUMN `calendar_token_hash` char(64) DEFAULT NULL,
+  ADD UNIQUE KEY `calendarTokenIdx` (`calendar_token_hash`);
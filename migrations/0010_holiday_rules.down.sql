@@ -0,0 +1,12 @@
+ALTER TABLE `holiday_items`
+  DROP INDEX `holidayDateIdx`;
+
+ALTER TABLE `holidays`
+  DROP INDEX `countryCodeIdx`,
+  DROP COLUMN `rule`,
+  DROP COLUMN `month`,
+  DROP COLUMN `day`,
+  DROP COLUMN `weekday`,
+  DROP COLUMN `week`,
+  DROP COLUMN `easter_offset`,
+  DROP COLUMN `country_code`;
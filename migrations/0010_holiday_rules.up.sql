@@ -0,0 +1,22 @@
+-- Holidays can have a rule that works out their date in any year, instead of
+-- each date being added by hand.  Existing holidays keep their dates.
+ALTER TABLE `holidays`
+  ADD COLUMN `rule` enum('dates','fixed','weekday','easter') NOT NULL DEFAULT 'dates',
+  ADD COLUMN `month` tinyint(2) NOT NULL DEFAULT 0,
+  ADD COLUMN `day` tinyint(2) NOT NULL DEFAULT 0,
+  ADD COLUMN `weekday` tinyint(1) NOT NULL DEFAULT 0,
+  ADD COLUMN `week` tinyint(1) NOT NULL DEFAULT 0,
+  ADD COLUMN `easter_offset` smallint(4) NOT NULL DEFAULT 0,
+  ADD COLUMN `country_code` char(2) DEFAULT NULL,
+  ADD KEY `countryCodeIdx` (`country_code`);
+
+-- Rule based dates are added by whichever server looks at a year first, so
+-- a holiday can only have each date once
+DELETE duplicate FROM `holiday_items` duplicate
+  INNER JOIN `holiday_items` original
+    ON original.`holiday_id` = duplicate.`holiday_id`
+   AND original.`date` = duplicate.`date`
+   AND original.`id` < duplicate.`id`;
+
+ALTER TABLE `holiday_items`
+  ADD UNIQUE KEY `holidayDateIdx` (`holiday_id`, `date`);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `alternate_names`;
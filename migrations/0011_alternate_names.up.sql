@@ -0,0 +1,10 @@
+-- Other names people are known by, like a maiden name, so they can be found
+-- by them
+CREATE TABLE IF NOT EXISTS `alternate_names` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `person_id` int(11) NOT NULL,
+  `kind` enum('maiden','married','birth','alias') NOT NULL DEFAULT 'alias',
+  `name` varchar(255) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `personIdx` (`person_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
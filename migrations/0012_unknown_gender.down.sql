@@ -0,0 +1,2 @@
+UPDATE `people` SET `gender`='F' WHERE `gender`='U';
+ALTER TABLE `people` MODIFY `gender` enum('M','F') NOT NULL;
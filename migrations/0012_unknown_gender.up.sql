@@ -0,0 +1,3 @@
+-- People whose gender isn't known, like those imported from a GEDCOM file
+-- without a SEX line
+ALTER TABLE `people` MODIFY `gender` enum('M','F','U') NOT NULL;
@@ -0,0 +1,5 @@
+DELETE FROM `reminder_run_emails` WHERE `sent_at` IS NULL;
+
+ALTER TABLE `reminder_run_emails`
+  DROP COLUMN `sent_at`,
+  CHANGE `claimed_at` `sent_at` datetime NOT NULL;
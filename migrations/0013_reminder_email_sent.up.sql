@@ -0,0 +1,8 @@
+-- Emails are claimed before they're sent and marked sent after, so a claim
+-- left behind by a run that died part way can be retried.  Emails already
+-- recorded were sent.
+ALTER TABLE `reminder_run_emails`
+  CHANGE `sent_at` `claimed_at` datetime NOT NULL,
+  ADD `sent_at` datetime DEFAULT NULL;
+
+UPDATE `reminder_run_emails` SET `sent_at`=`claimed_at`;
@@ -0,0 +1,2 @@
+ALTER TABLE `reminder_recipients`
+  DROP COLUMN `country_code`;
@@ -0,0 +1,4 @@
+-- Recipients can limit the holidays in their email to one country's, along
+-- with the ones for everyone
+ALTER TABLE `reminder_recipients`
+  ADD COLUMN `country_code` char(2) DEFAULT NULL;
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Version of the API the OpenAPI document describes
+const apiVersion = "1.0.0"
+
+// Names the OpenAPI document uses for types whose Go names are unexported
+var openApiSchemaNames = map[reflect.Type]string{
+	reflect.TypeOf(apiPersonRecord{}):      "PersonRecord",
+	reflect.TypeOf(apiFamilyRecord{}):      "FamilyRecord",
+	reflect.TypeOf(apiHolidayRecord{}):     "HolidayRecord",
+	reflect.TypeOf(apiSpouseData{}):        "SpouseData",
+	reflect.TypeOf(apiSpouseRecord{}):      "SpouseRecord",
+	reflect.TypeOf(apiCityData{}):          "CityData",
+	reflect.TypeOf(apiAlternateNameData{}): "AlternateNameData",
+	reflect.TypeOf(apiTagData{}):           "TagData",
+	reflect.TypeOf(apiTagWithPeople{}):     "TagWithPeople",
+	reflect.TypeOf(apiErrorResponse{}):     "ErrorResponse",
+}
+
+// Allowed values of string types
+var openApiEnums = map[reflect.Type][]string{
+	reflect.TypeOf(HolidayRule("")): {
+		string(HolidayRuleDates), string(HolidayRuleFixed), string(HolidayRuleWeekday), string(HolidayRuleEaster),
+	},
+	reflect.TypeOf(AlternateNameKind("")): {
+		string(AlternateNameMaiden), string(AlternateNameMarried), string(AlternateNameBirth), string(AlternateNameAlias),
+	},
+}
+
+// Formats of string types
+var openApiFormats = map[reflect.Type]string{
+	reflect.TypeOf(apiDate("")): "date",
+}
+
+// Builds JSON schemas from Go types.  Named structs go in the document's
+// components and are referred to by name, so each is described once.
+type openApiSchemas struct {
+	components map[string]interface{}
+}
+
+func (s *openApiSchemas) ref(t reflect.Type) map[string]interface{} {
+	name, found := openApiSchemaNames[t]
+	if !found {
+		name = t.Name()
+	}
+	if _, done := s.components[name]; !done {
+		// Set first so types that refer to themselves stop here
+		s.components[name] = nil
+		s.components[name] = s.object(t)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// The properties of a struct, named the way encoding/json names them.  As
+// with encoding/json, a field hides those of the same name in the structs
+// embedded alongside it.
+func (s *openApiSchemas) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		var embedded []reflect.Type
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				embedded = append(embedded, field.Type)
+				continue
+			}
+			if field.PkgPath != "" {
+				continue
+			}
+			name := field.Name
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			if _, found := properties[name]; found {
+				continue
+			}
+			schema := s.schema(field.Type)
+			// Lists inside results are null rather than [] when empty
+			if field.Type.Kind() == reflect.Slice {
+				schema["nullable"] = true
+			}
+			properties[name] = schema
+		}
+		for _, embeddedType := range embedded {
+			addFields(embeddedType)
+		}
+	}
+	addFields(t)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// The schema of a type.  There's none for time.Time: the API sends dates as
+// apiDate so they don't come with a time and zone.
+func (s *openApiSchemas) schema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schema(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"nullable": true, "allOf": []interface{}{schema}}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		schema := map[string]interface{}{"type": "string"}
+		if format, found := openApiFormats[t]; found {
+			schema["format"] = format
+		}
+		if values, found := openApiEnums[t]; found {
+			schema["enum"] = values
+		}
+		return schema
+	}
+	panic(fmt.Sprintf("No OpenAPI schema for %v", t))
+}
+
+// A parameter in a route's pattern: ids are numbers, dates are dates, and
+// anything else like a country code is a string
+func openApiParameter(name string) map[string]interface{} {
+	schema := map[string]interface{}{"type": "string"}
+	if name == "id" || strings.HasSuffix(name, "Id") {
+		schema = map[string]interface{}{"type": "integer", "format": "int64", "minimum": 1}
+	} else if name == "date" {
+		schema["format"] = "date"
+	}
+	return map[string]interface{}{"name": name, "in": "path", "required": true, "schema": schema}
+}
+
+func openApiJsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func openApiOperation(schemas *openApiSchemas, collection *apiCollection, route *apiRoute) map[string]interface{} {
+	var parameters []interface{}
+	for _, part := range strings.Split(route.Pattern, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parameters = append(parameters, openApiParameter(part[1:len(part)-1]))
+		}
+	}
+
+	for _, query := range route.Query {
+		parameters = append(parameters, map[string]interface{}{
+			"name":        query.Name,
+			"in":          "query",
+			"description": query.Description,
+			"schema":      map[string]interface{}{"type": query.Type},
+		})
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(route.successStatus())}
+	if route.Response != nil {
+		success["content"] = openApiJsonContent(schemas.schema(reflect.TypeOf(route.Response)))
+	}
+	operation := map[string]interface{}{
+		"tags":        []string{collection.Name},
+		"summary":     route.Summary,
+		"description": fmt.Sprintf("Needs the %s role.", route.Role),
+		"operationId": openApiOperationId(collection.Name, route),
+		"responses": map[string]interface{}{
+			fmt.Sprint(route.successStatus()): success,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content":     openApiJsonContent(schemas.schema(reflect.TypeOf(apiErrorResponse{}))),
+			},
+		},
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+	if route.Request != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  openApiJsonContent(schemas.schema(reflect.TypeOf(route.Request))),
+		}
+	}
+	return operation
+}
+
+// Name for the function a generated client makes for the route, like
+// "getPeopleIdFamily"
+func openApiOperationId(collection string, route *apiRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.Split(collection+"/"+route.Pattern, "/") {
+		part = strings.Trim(part, "{}")
+		if part != "" {
+			id += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return id
+}
+
+// The OpenAPI 3 document for every API route
+func BuildOpenApiDocument() map[string]interface{} {
+	schemas := &openApiSchemas{components: make(map[string]interface{})}
+	// Named in the document even where no route returns them directly
+	for _, value := range []interface{}{PersonLite{}, CityLite{}, Country{}, Tag{}} {
+		schemas.ref(reflect.TypeOf(value))
+	}
+
+	paths := make(map[string]interface{})
+	var tags []interface{}
+	collections := apiCollections()
+	for i := range collections {
+		collection := &collections[i]
+		tags = append(tags, map[string]interface{}{"name": collection.Name, "description": collection.Description})
+		for j := range collection.Routes {
+			route := &collection.Routes[j]
+			path := apiPrefix + collection.Name
+			if route.Pattern != "" {
+				path += "/" + route.Pattern
+			}
+			if paths[path] == nil {
+				paths[path] = make(map[string]interface{})
+			}
+			paths[path].(map[string]interface{})[strings.ToLower(route.Method)] = openApiOperation(schemas, collection, route)
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Family API",
+			"version":     apiVersion,
+			"description": "People, places, tags and holidays in the family database.  Log in at /login and send the session cookie with each request.",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": "/"}},
+		"tags":    tags,
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]interface{}{
+				"session": map[string]interface{}{"type": "apiKey", "in": "cookie", "name": sessionCookieName},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"session": []string{}}},
+	}
+}
+
+func apiOpenApiDocument(w http.ResponseWriter, r *http.Request) {
+	writeApiJson(w, http.StatusOK, BuildOpenApiDocument())
+}
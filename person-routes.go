@@ -1,13 +1,13 @@
 package main
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type GrandParents struct {
@@ -15,6 +15,18 @@ type GrandParents struct {
 	GrandMother *PersonLite
 }
 
+// Gender column value for the gender radio buttons
+func genderFromForm(value string) string {
+	switch value {
+	case "male":
+		return "M"
+	case "female":
+		return "F"
+	default:
+		return "U"
+	}
+}
+
 func personAdd(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		var data PersonData
@@ -28,11 +40,7 @@ func personAdd(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if r.FormValue("gender") == "male" {
-			data.Gender = "M"
-		} else {
-			data.Gender = "F"
-		}
+		data.Gender = genderFromForm(r.FormValue("gender"))
 		data.BirthDate = r.FormValue("birth_date")
 		if r.FormValue("is_birth_year_guess") == "1" {
 			data.IsBirthYearGuess = true
@@ -41,11 +49,23 @@ func personAdd(w http.ResponseWriter, r *http.Request) {
 			data.IsAlive = true
 		}
 		data.BirthCityId, _ = strconv.Atoi(r.FormValue("birth_city_id"))
+		data.DeathDate = r.FormValue("death_date")
+		if r.FormValue("is_death_year_guess") == "1" {
+			data.IsDeathYearGuess = true
+		}
+		data.DeathCityId, _ = strconv.Atoi(r.FormValue("death_city_id"))
+		data.BurialCityId, _ = strconv.Atoi(r.FormValue("burial_city_id"))
+		if strings.TrimSpace(data.DeathDate) != "" {
+			data.IsAlive = false
+		}
 		data.HomeCityId, _ = strconv.Atoi(r.FormValue("home_city_id"))
 		data.MotherId, _ = strconv.Atoi(r.FormValue("mother_id"))
 		data.FatherId, _ = strconv.Atoi(r.FormValue("father_id"))
 
-		personId, err := InsertPerson(db, data)
+		if !checkFormParents(w, 0, &data) {
+			return
+		}
+		personId, err := store.InsertPerson(data)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error inserting person: %v", err), 500)
 			return
@@ -54,10 +74,23 @@ func personAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/person/add.html")).Execute(w, "empty data")
+	renderTemplate(w, r, "person/add.html", "empty data")
+}
+
+// Reply with a 400 and return false when the person's parents can't be theirs
+func checkFormParents(w http.ResponseWriter, personId int, data *PersonData) bool {
+	problems, err := checkPersonParents(store, personId, data.MotherId, data.FatherId)
 	if err != nil {
-		panic(err)
+		http.Error(w, fmt.Sprintf("Error checking parents: %v", err), 500)
+		return false
+	}
+	for _, parent := range []struct{ field, name string }{{"MotherId", "mother"}, {"FatherId", "father"}} {
+		if problem, found := problems[parent.field]; found {
+			http.Error(w, fmt.Sprintf("Error with the %s: %s.", parent.name, problem), 400)
+			return false
+		}
 	}
+	return true
 }
 
 func personEdit(w http.ResponseWriter, r *http.Request) {
@@ -79,11 +112,7 @@ func personEdit(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if r.FormValue("gender") == "male" {
-			data.Gender = "M"
-		} else {
-			data.Gender = "F"
-		}
+		data.Gender = genderFromForm(r.FormValue("gender"))
 		data.BirthDate = r.FormValue("birth_date")
 		if r.FormValue("is_birth_year_guess") == "1" {
 			data.IsBirthYearGuess = true
@@ -92,11 +121,23 @@ func personEdit(w http.ResponseWriter, r *http.Request) {
 			data.IsAlive = true
 		}
 		data.BirthCityId, _ = strconv.Atoi(r.FormValue("birth_city_id"))
+		data.DeathDate = r.FormValue("death_date")
+		if r.FormValue("is_death_year_guess") == "1" {
+			data.IsDeathYearGuess = true
+		}
+		data.DeathCityId, _ = strconv.Atoi(r.FormValue("death_city_id"))
+		data.BurialCityId, _ = strconv.Atoi(r.FormValue("burial_city_id"))
+		if strings.TrimSpace(data.DeathDate) != "" {
+			data.IsAlive = false
+		}
 		data.HomeCityId, _ = strconv.Atoi(r.FormValue("home_city_id"))
 		data.MotherId, _ = strconv.Atoi(r.FormValue("mother_id"))
 		data.FatherId, _ = strconv.Atoi(r.FormValue("father_id"))
 
-		err = UpdatePerson(db, personId, data)
+		if !checkFormParents(w, personId, &data) {
+			return
+		}
+		err = store.UpdatePerson(personId, data)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error updating person: %v", err), 500)
 			return
@@ -105,24 +146,28 @@ func personEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	person, err := LoadPersonById(db, personId)
+	person, err := store.LoadPersonById(personId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading person: %v", err), 500)
 		return
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/person/edit.html")).Execute(w, person)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "person/edit.html", person)
 }
 
-func LoadPersonLiteOrNull(db *sql.DB, personId int) (*PersonLite, error) {
-	if personId == 0 {
-		return nil, nil
-	} else {
-		return LoadPersonLiteById(db, personId)
+// Grandparents from a parent's node in the ancestor tree
+func grandParentsOf(parent *AncestorNode) GrandParents {
+	var grandParents GrandParents
+	if parent == nil {
+		return grandParents
+	}
+	if parent.Mother != nil {
+		grandParents.GrandMother = &parent.Mother.Person
+	}
+	if parent.Father != nil {
+		grandParents.GrandFather = &parent.Father.Person
 	}
+	return grandParents
 }
 
 func personView(w http.ResponseWriter, r *http.Request) {
@@ -132,89 +177,250 @@ func personView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	person, err := LoadPersonById(db, personId)
+	// The ancestor tree finds the parents already loaded, so the grandparents
+	// are a single query
+	loader := RequestPersonLoader(r)
+	person, err := loader.LoadPerson(personId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading person: %v", err), 500)
 		return
 	}
 
-	var paternalGrandParents, maternalGrandParents GrandParents
-	if person.Father != nil {
-		paternalGrandParents.GrandMother, _ = LoadPersonLiteOrNull(db, person.Father.MotherId)
-		paternalGrandParents.GrandFather, _ = LoadPersonLiteOrNull(db, person.Father.FatherId)
-	}
-	if person.Mother != nil {
-		maternalGrandParents.GrandMother, _ = LoadPersonLiteOrNull(db, person.Mother.MotherId)
-		maternalGrandParents.GrandFather, _ = LoadPersonLiteOrNull(db, person.Mother.FatherId)
+	ancestors, err := LoadAncestorTree(loader, person.Id, 2)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading ancestors: %v", err), 500)
+		return
 	}
+	paternalGrandParents := grandParentsOf(ancestors.Father)
+	maternalGrandParents := grandParentsOf(ancestors.Mother)
+
+	tags, err := store.LoadTagsForPerson(person.Id)
 
-	tags, err := LoadTagsForPerson(db, person.Id)
+	alternateNames, err := store.LoadAlternateNames(person.Id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading alternate names: %v", err), 500)
+		return
+	}
 
 	data := struct {
 		Person               *Person
 		PaternalGrandParents GrandParents
 		MaternalGrandParents GrandParents
 		Tags                 []Tag
+		AlternateNames       []AlternateName
+		AlternateNameKinds   []AlternateNameKind
 	}{
 		person,
 		paternalGrandParents,
 		maternalGrandParents,
 		tags,
+		alternateNames,
+		alternateNameKinds,
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/person/view.html", "tmpl/person/tree.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "person/view.html", data)
 }
 
 func personList(w http.ResponseWriter, r *http.Request) {
-	err := template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/person/list.html")).Execute(w, nil)
-	if err != nil {
-		panic(err)
+	renderTemplate(w, r, "person/list.html", nil)
+}
+
+// Link to the calendar for a year, or one month of it when month isn't 0
+func personCalendarUrl(year int, month int, includeMemorials bool, countryCode string) string {
+	query := url.Values{}
+	query.Set("year", strconv.Itoa(year))
+	if month != 0 {
+		query.Set("month", strconv.Itoa(month))
+	}
+	if includeMemorials {
+		query.Set("memorials", "1")
+	}
+	if countryCode != "" {
+		query.Set("country", countryCode)
 	}
+	return "/person/calendar?" + query.Encode()
 }
 
+// Shows a year of events as a list, or a month of them as a grid when a
+// month is given
 func personCalendar(w http.ResponseWriter, r *http.Request) {
-	calendar, err := LoadPeopleCalendar(db)
+	includeMemorials := r.FormValue("memorials") == "1"
+	countryCode := r.FormValue("country")
+	now := time.Now()
+
+	year := now.Year()
+	if r.FormValue("year") != "" {
+		var err error
+		year, err = strconv.Atoi(r.FormValue("year"))
+		if err != nil || year < 1 || year > 9999 {
+			http.Error(w, fmt.Sprintf("Invalid year: %s", r.FormValue("year")), 400)
+			return
+		}
+	}
+	month := 0
+	if r.FormValue("month") != "" {
+		var err error
+		month, err = strconv.Atoi(r.FormValue("month"))
+		if err != nil || month < 1 || month > 12 {
+			http.Error(w, fmt.Sprintf("Invalid month: %s", r.FormValue("month")), 400)
+			return
+		}
+	}
+
+	calendar, err := LoadPeopleCalendar(store, year, includeMemorials, countryCode)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading person calendar: %v", err), 500)
 		return
 	}
+	countries, err := store.LoadCountryList()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading countries: %v", err), 500)
+		return
+	}
+
+	data := struct {
+		*PeopleCalendar
+		IncludeMemorials bool
+		Countries        []Country
+		CountryCode      string
+		Month            *CalendarMonth
+		PreviousUrl      string
+		NextUrl          string
+		TodayUrl         string
+		ViewUrl          string
+		MemorialsUrl     string
+	}{
+		PeopleCalendar:   calendar,
+		IncludeMemorials: includeMemorials,
+		Countries:        countries,
+		CountryCode:      countryCode,
+		MemorialsUrl:     personCalendarUrl(year, month, !includeMemorials, countryCode),
+	}
+	if month == 0 {
+		data.PreviousUrl = personCalendarUrl(year-1, 0, includeMemorials, countryCode)
+		data.NextUrl = personCalendarUrl(year+1, 0, includeMemorials, countryCode)
+		data.TodayUrl = personCalendarUrl(now.Year(), 0, includeMemorials, countryCode)
+		gridMonth := 1
+		if year == now.Year() {
+			gridMonth = int(now.Month())
+		}
+		data.ViewUrl = personCalendarUrl(year, gridMonth, includeMemorials, countryCode)
+	} else {
+		data.Month = &calendar.Months[month-1]
+		previous := time.Date(year, time.Month(month-1), 1, 0, 0, 0, 0, time.UTC)
+		next := time.Date(year, time.Month(month+1), 1, 0, 0, 0, 0, time.UTC)
+		data.PreviousUrl = personCalendarUrl(previous.Year(), int(previous.Month()), includeMemorials, countryCode)
+		data.NextUrl = personCalendarUrl(next.Year(), int(next.Month()), includeMemorials, countryCode)
+		data.TodayUrl = personCalendarUrl(now.Year(), int(now.Month()), includeMemorials, countryCode)
+		data.ViewUrl = personCalendarUrl(year, 0, includeMemorials, countryCode)
+	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/person/calendar.html")).Execute(w, calendar)
+	renderTemplate(w, r, "person/calendar.html", data)
+}
+
+// Person in a JSON list, with a star when they're one of the user's favorites
+type favoritePersonLite struct {
+	PersonLite
+	IsFavorite bool
+	// The alternate name a search found them by
+	MatchedName string
+}
+
+func markFavorites(r *http.Request, people []PersonLite) ([]favoritePersonLite, error) {
+	favorites, err := store.LoadFavoritePersonIds(CurrentUser(r).Id)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	var list []favoritePersonLite
+	for _, person := range people {
+		list = append(list, favoritePersonLite{PersonLite: person, IsFavorite: favorites[person.Id]})
 	}
+	return list, nil
 }
 
+// Search people by any of their names.  The query is in q, or prefix for
+// older callers, and the results come a page at a time from offset.
 func personJsonSearch(w http.ResponseWriter, r *http.Request) {
-	prefix := r.FormValue("prefix")
+	query := r.FormValue("q")
+	if query == "" {
+		query = r.FormValue("prefix")
+	}
 	offset, err := strconv.Atoi(r.FormValue("offset"))
-	if err != nil {
+	if err != nil || offset < 0 {
 		offset = 0
 	}
-	people, err := LoadPersonLiteListByNamePrefix(db, prefix, offset)
+	results, err := SearchPeople(store, query, offset, personSearchPageSize)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error loading people: %v", err), 500)
+		http.Error(w, fmt.Sprintf("Error searching people: %v", err), 500)
+		return
+	}
+	favorites, err := store.LoadFavoritePersonIds(CurrentUser(r).Id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading favorites: %v", err), 500)
 		return
 	}
+
+	data := struct {
+		Total  int
+		Offset int
+		People []favoritePersonLite
+	}{
+		Total:  results.Total,
+		Offset: results.Offset,
+		People: []favoritePersonLite{},
+	}
+	for _, hit := range results.People {
+		data.People = append(data.People, favoritePersonLite{hit.PersonLite, favorites[hit.Id], hit.MatchedName})
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(people)
+	json.NewEncoder(w).Encode(data)
 }
 
 func personJsonFavorites(w http.ResponseWriter, r *http.Request) {
-	people, err := LoadPersonLiteListWithTag(db, "Favorites")
+	people, err := store.LoadFavoritePeople(CurrentUser(r).Id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading people: %v", err), 500)
 		return
 	}
+	list, err := markFavorites(r, people)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading favorites: %v", err), 500)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(people)
+	json.NewEncoder(w).Encode(list)
+}
+
+func personJsonFavoriteAdd(w http.ResponseWriter, r *http.Request) {
+	personId, err := strconv.Atoi(r.FormValue("person_id"))
+	if personId < 1 {
+		http.Error(w, fmt.Sprintf("Error parsing person_id: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = store.InsertFavoritePerson(CurrentUser(r).Id, personId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error adding favorite: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func personJsonFavoriteDelete(w http.ResponseWriter, r *http.Request) {
+	personId, err := strconv.Atoi(r.FormValue("person_id"))
+	if personId < 1 {
+		http.Error(w, fmt.Sprintf("Error parsing person_id: %v", err), http.StatusBadRequest)
+		return
+	}
+	err = store.DeleteFavoritePerson(CurrentUser(r).Id, personId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting favorite: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 func personGraph(w http.ResponseWriter, r *http.Request) {
-	personList, err := LoadPersonLiteList(db)
+	personList, err := store.LoadPersonLiteList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading people: %v", err), 500)
 		return
@@ -253,7 +459,7 @@ func personDelete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Could not parse person id: %v", err), 400)
 		return
 	}
-	err = DeletePerson(db, personId)
+	err = store.DeletePerson(personId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Could not delete person: %v", err), 500)
 		return
@@ -261,14 +467,62 @@ func personDelete(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/person/list", 302)
 }
 
+func personNameAdd(w http.ResponseWriter, r *http.Request) {
+	personId, err := getIntPathParam(r, "personId", 4)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse person id: %v", err), 400)
+		return
+	}
+	kind := AlternateNameKind(r.FormValue("kind"))
+	name := strings.TrimSpace(r.FormValue("name"))
+	if !kind.IsValid() || name == "" {
+		http.Error(w, "Error, a kind and a name are needed.", 400)
+		return
+	}
+	_, err = store.LoadPersonLiteById(personId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading person: %v", err), 404)
+		return
+	}
+	_, err = store.InsertAlternateName(personId, kind, name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error adding name: %v", err), 500)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/person/view/%d", personId), 302)
+}
+
+func personNameDelete(w http.ResponseWriter, r *http.Request) {
+	personId, err := getIntPathParam(r, "personId", 4)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse person id: %v", err), 400)
+		return
+	}
+	nameId, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse name id: %v", err), 400)
+		return
+	}
+	err = store.DeleteAlternateName(personId, nameId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting name: %v", err), 500)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/person/view/%d", personId), 302)
+}
+
 func addPersonRoutes() {
-	http.HandleFunc("/person/json/search", personJsonSearch)
-	http.HandleFunc("/person/json/favorites", personJsonFavorites)
-	http.HandleFunc("/person/list", personList)
-	http.HandleFunc("/person/calendar", personCalendar)
-	http.HandleFunc("/person/view/", personView)
-	http.HandleFunc("/person/add", personAdd)
-	http.HandleFunc("/person/delete/", personDelete)
-	http.HandleFunc("/person/edit/", personEdit)
-	http.HandleFunc("/person/graph/", personGraph)
+	http.HandleFunc("/person/json/search", requireRole(RoleViewer, personJsonSearch))
+	http.HandleFunc("/person/json/favorites", requireRole(RoleViewer, personJsonFavorites))
+	http.HandleFunc("/person/json/favorite/add", requireRole(RoleViewer, requirePost(personJsonFavoriteAdd)))
+	http.HandleFunc("/person/json/favorite/delete", requireRole(RoleViewer, requirePost(personJsonFavoriteDelete)))
+	http.HandleFunc("/person/list", requireRole(RoleViewer, personList))
+	http.HandleFunc("/person/calendar", requireRole(RoleViewer, personCalendar))
+	http.HandleFunc("/person/view/", requireRole(RoleViewer, personView))
+	http.HandleFunc("/person/add", requireRole(RoleEditor, personAdd))
+	http.HandleFunc("/person/delete/", requireRole(RoleAdmin, requirePost(personDelete)))
+	http.HandleFunc("/person/edit/", requireRole(RoleEditor, personEdit))
+	http.HandleFunc("/person/name/add/", requireRole(RoleEditor, requirePost(personNameAdd)))
+	http.HandleFunc("/person/name/delete/", requireRole(RoleEditor, requirePost(personNameDelete)))
+	http.HandleFunc("/person/graph/", requireRole(RoleViewer, personGraph))
 }
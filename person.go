@@ -2,7 +2,9 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -27,6 +29,10 @@ type Person struct {
 	BirthDate        time.Time
 	BirthCity        *CityLite
 	IsBirthYearGuess bool
+	DeathDate        time.Time
+	IsDeathYearGuess bool
+	DeathCity        *CityLite
+	BurialCity       *CityLite
 	HomeCity         *CityLite
 	Mother           *PersonLite
 	Father           *PersonLite
@@ -45,6 +51,10 @@ type PersonData struct {
 	BirthDate        string
 	BirthCityId      int
 	IsBirthYearGuess bool
+	DeathDate        string
+	IsDeathYearGuess bool
+	DeathCityId      int
+	BurialCityId     int
 	HomeCityId       int
 	MotherId         int
 	FatherId         int
@@ -79,22 +89,198 @@ func (p *Person) HasBirthDate() bool {
 	return p.BirthDate != time.Time{}
 }
 
+func (p *Person) DeathDateFormatted() string {
+	return p.DeathDate.Format("Mon, Jan 2, 2006")
+}
+
+func (p *Person) HasDeathDate() bool {
+	return p.DeathDate != time.Time{}
+}
+
+// The person's fields in the form they're saved in
+func (p *Person) Data() PersonData {
+	data := PersonData{
+		FirstName:        p.FirstName,
+		MiddleName:       p.MiddleName,
+		LastName:         p.LastName,
+		NickName:         p.NickName,
+		Gender:           GetGenderCode(p.Gender),
+		IsAlive:          p.IsAlive,
+		IsBirthYearGuess: p.IsBirthYearGuess,
+		IsDeathYearGuess: p.IsDeathYearGuess,
+	}
+	if p.HasBirthDate() {
+		data.BirthDate = p.BirthDate.Format("2006-01-02")
+	}
+	if p.HasDeathDate() {
+		data.DeathDate = p.DeathDate.Format("2006-01-02")
+	}
+	for _, city := range []struct {
+		city *CityLite
+		id   *int
+	}{
+		{p.BirthCity, &data.BirthCityId},
+		{p.DeathCity, &data.DeathCityId},
+		{p.BurialCity, &data.BurialCityId},
+		{p.HomeCity, &data.HomeCityId},
+	} {
+		if city.city != nil {
+			*city.id = city.city.Id
+		}
+	}
+	if p.Mother != nil {
+		data.MotherId = p.Mother.Id
+	}
+	if p.Father != nil {
+		data.FatherId = p.Father.Id
+	}
+	return data
+}
+
+// Age today, or the age they reached for someone who has died
 func (p *Person) Age() int {
-	now := time.Now()
-	years := now.Year() - p.BirthDate.Year()
+	if !p.IsAlive && p.HasDeathDate() {
+		return yearsBetween(p.BirthDate, p.DeathDate)
+	}
+	return yearsBetween(p.BirthDate, time.Now())
+}
 
-	if now.Month() < p.BirthDate.Month() {
+// Whether an age can be worked out, which needs the death date for someone
+// who has died
+func (p *Person) HasAge() bool {
+	return p.HasBirthDate() && (p.IsAlive || p.HasDeathDate())
+}
+
+// Years of birth and death with the age reached, like "1921–1998, aged 77".
+// Estimated years are shown as "c. 1921" and unknown ones as "?".
+func (p *Person) LifeSpan() string {
+	birthYear := formatLifeSpanYear(p.BirthDate, p.IsBirthYearGuess)
+	if p.IsAlive {
+		if !p.HasBirthDate() {
+			return ""
+		}
+		return fmt.Sprintf("born %s, aged %s", birthYear, p.ageFormatted())
+	}
+	if !p.HasBirthDate() && !p.HasDeathDate() {
+		return ""
+	}
+	span := birthYear + "–" + formatLifeSpanYear(p.DeathDate, p.IsDeathYearGuess)
+	if p.HasAge() {
+		span += ", aged " + p.ageFormatted()
+	}
+	return span
+}
+
+func (p *Person) ageFormatted() string {
+	if p.IsBirthYearGuess || (!p.IsAlive && p.IsDeathYearGuess) {
+		return fmt.Sprintf("about %d", p.Age())
+	}
+	return strconv.Itoa(p.Age())
+}
+
+func formatLifeSpanYear(date time.Time, isGuess bool) string {
+	if date.IsZero() {
+		return "?"
+	}
+	if isGuess {
+		return fmt.Sprintf("c. %d", date.Year())
+	}
+	return strconv.Itoa(date.Year())
+}
+
+// Whole years from start until end
+func yearsBetween(start time.Time, end time.Time) int {
+	years := end.Year() - start.Year()
+
+	if end.Month() < start.Month() {
 		years -= 1
-	} else if now.Month() == p.BirthDate.Month() && now.Day() < p.BirthDate.Day() {
+	} else if end.Month() == start.Month() && end.Day() < start.Day() {
 		years -= 1
 	}
 
 	return years
 }
 
-func LoadPersonLiteById(db *sql.DB, id int) (*PersonLite, error) {
+// Problems with making motherId and fatherId a person's parents, by field
+// name ("MotherId" or "FatherId").  personId is 0 for a new person.  A parent
+// can't be the person, one of their descendants, or of the other gender;
+// people whose gender isn't known can be either parent, as old records often
+// don't say.
+func checkPersonParents(store Store, personId int, motherId int, fatherId int) (map[string]string, error) {
+	problems := make(map[string]string)
+	for _, parent := range []struct {
+		field       string
+		id          int
+		wrongGender string
+	}{
+		{"MotherId", motherId, "M"},
+		{"FatherId", fatherId, "F"},
+	} {
+		if parent.id == 0 {
+			continue
+		}
+		if parent.id == personId {
+			problems[parent.field] = "can't be the person themselves"
+			continue
+		}
+		person, err := store.LoadPersonLiteById(parent.id)
+		if errors.Is(err, ErrNotFound) {
+			problems[parent.field] = fmt.Sprintf("person %d doesn't exist", parent.id)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if GetGenderCode(person.Gender) == parent.wrongGender {
+			problems[parent.field] = fmt.Sprintf("%s is %s", person.Name, strings.ToLower(person.Gender))
+			continue
+		}
+		if personId == 0 {
+			continue
+		}
+		isDescendant, err := isAncestorOf(store, personId, person)
+		if err != nil {
+			return nil, err
+		}
+		if isDescendant {
+			problems[parent.field] = fmt.Sprintf("%s is one of the person's descendants", person.Name)
+		}
+	}
+	return problems, nil
+}
+
+// Whether ancestorId is one of person's ancestors, looking a generation at a
+// time.  Loops already in the tree are only followed once.
+func isAncestorOf(store Store, ancestorId int, person *PersonLite) (bool, error) {
+	seen := map[int]bool{person.Id: true}
+	generation := []PersonLite{*person}
+	for len(generation) > 0 {
+		var parentIds []int
+		for _, child := range generation {
+			for _, parentId := range []int{child.MotherId, child.FatherId} {
+				if parentId == ancestorId {
+					return true, nil
+				}
+				if parentId != 0 && !seen[parentId] {
+					seen[parentId] = true
+					parentIds = append(parentIds, parentId)
+				}
+			}
+		}
+		if len(parentIds) == 0 {
+			break
+		}
+		var err error
+		generation, err = store.LoadPersonLiteListByIds(parentIds)
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (s *MySqlStore) LoadPersonLiteById(id int) (*PersonLite, error) {
 	defer trace(traceName(fmt.Sprintf("LoadPersonLiteById(%d)", id)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT id, first_name, middle_name, last_name, nick_name, gender, mother_id, father_id"+
 			" FROM people"+
 			" WHERE id=?", id)
@@ -104,7 +290,7 @@ func LoadPersonLiteById(db *sql.DB, id int) (*PersonLite, error) {
 	defer rows.Close()
 
 	if !rows.Next() {
-		return nil, fmt.Errorf("Person not found with id: %d", id)
+		return nil, notFoundf("Person not found with id: %d", id)
 	}
 	item, err := readPersonLiteFromRows(rows)
 	if err != nil {
@@ -114,84 +300,160 @@ func LoadPersonLiteById(db *sql.DB, id int) (*PersonLite, error) {
 }
 
 func GetGenderName(gender string) string {
-	if gender == "M" {
+	switch gender {
+	case "M":
 		return "Male"
-	} else {
+	case "F":
 		return "Female"
+	default:
+		return "Unknown"
 	}
 }
 
-func LoadPersonById(db *sql.DB, id int) (*Person, error) {
-	defer trace(traceName(fmt.Sprintf("LoadPersonById(%d)", id)))
-	rows, err := db.Query(
-		"SELECT p.id, p.first_name, p.middle_name, p.last_name,"+
-			" p.nick_name, p.mother_id, p.father_id, p.birth_date,"+
-			" p.is_birth_year_guess, p.is_alive, p.home_city_id, p.birth_city_id,"+
-			" p.gender"+
-			" FROM people p"+
-			" WHERE p.id=?",
+// The gender column's value for a name from GetGenderName
+func GetGenderCode(name string) string {
+	switch name {
+	case "Male":
+		return "M"
+	case "Female":
+		return "F"
+	default:
+		return "U"
+	}
+}
+
+// Load a person with their family and cities.  See PersonLoader.LoadPerson
+// for the queries this takes.
+func (s *MySqlStore) LoadPersonById(id int) (*Person, error) {
+	return NewPersonLoader(s).LoadPerson(id)
+}
+
+// Load the fields of a person's own row, without following any of its links
+func (s *MySqlStore) LoadPersonDataById(id int) (*PersonData, error) {
+	defer trace(traceName(fmt.Sprintf("LoadPersonDataById(%d)", id)))
+	rows, err := s.db.Query(
+		"SELECT first_name, middle_name, last_name, nick_name, gender,"+
+			" is_alive, birth_date, birth_city_id, is_birth_year_guess,"+
+			" death_date, is_death_year_guess, death_city_id, burial_city_id,"+
+			" home_city_id, mother_id, father_id"+
+			" FROM people"+
+			" WHERE id=?",
 		id)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	if !rows.Next() {
-		rows.Close()
-		return nil, fmt.Errorf("Person not found with id: %d", id)
+		return nil, notFoundf("Person not found with id: %d", id)
 	}
-	var item Person
-	var motherId, fatherId, homeCityId, birthCityId sql.NullInt64
-	var birthDateString sql.NullString
-	var gender string
+	var item PersonData
+	var motherId, fatherId, homeCityId, birthCityId, deathCityId, burialCityId sql.NullInt64
+	var birthDate, deathDate sql.NullString
 	err = rows.Scan(
-		&item.Id, &item.FirstName, &item.MiddleName, &item.LastName,
-		&item.NickName, &motherId, &fatherId, &birthDateString,
-		&item.IsBirthYearGuess, &item.IsAlive, &homeCityId, &birthCityId,
-		&gender)
-	rows.Close()
+		&item.FirstName, &item.MiddleName, &item.LastName, &item.NickName, &item.Gender,
+		&item.IsAlive, &birthDate, &birthCityId, &item.IsBirthYearGuess,
+		&deathDate, &item.IsDeathYearGuess, &deathCityId, &burialCityId,
+		&homeCityId, &motherId, &fatherId)
 	if err != nil {
 		return nil, err
 	}
-	if motherId.Valid {
-		item.Mother, err = LoadPersonLiteById(db, int(motherId.Int64))
+	item.BirthDate = birthDate.String
+	item.DeathDate = deathDate.String
+	item.BirthCityId = int(birthCityId.Int64)
+	item.DeathCityId = int(deathCityId.Int64)
+	item.BurialCityId = int(burialCityId.Int64)
+	item.HomeCityId = int(homeCityId.Int64)
+	item.MotherId = int(motherId.Int64)
+	item.FatherId = int(fatherId.Int64)
+	return &item, nil
+}
+
+// Load every person along with their parents and cities.  Children, spouses
+// and siblings are not filled in.
+func (s *MySqlStore) LoadPersonList() ([]Person, error) {
+	defer trace(traceName("LoadPersonList"))
+	cities, err := s.LoadCityList()
+	if err != nil {
+		return nil, err
 	}
-	if fatherId.Valid {
-		item.Father, err = LoadPersonLiteById(db, int(fatherId.Int64))
+	cityLookup := make(map[int]*CityLite)
+	for i := range cities {
+		cityLookup[cities[i].Id] = &cities[i]
 	}
-	if birthDateString.Valid {
-		item.BirthDate, err = time.Parse("2006-01-02", birthDateString.String)
+
+	rows, err := s.db.Query(
+		"SELECT p.id, p.first_name, p.middle_name, p.last_name," +
+			" p.nick_name, p.mother_id, p.father_id, p.birth_date," +
+			" p.is_birth_year_guess, p.is_alive, p.home_city_id, p.birth_city_id," +
+			" p.death_date, p.is_death_year_guess, p.death_city_id, p.burial_city_id," +
+			" p.gender" +
+			" FROM people p" +
+			" ORDER BY p.id")
+	if err != nil {
+		return nil, err
 	}
-	item.Gender = GetGenderName(gender)
-	if birthCityId.Valid {
-		item.BirthCity, err = LoadCityById(db, int(birthCityId.Int64))
+	defer rows.Close()
+
+	var list []Person
+	var parentIds [][2]int
+	for rows.Next() {
+		var item Person
+		var motherId, fatherId, homeCityId, birthCityId, deathCityId, burialCityId sql.NullInt64
+		var birthDateString, deathDateString sql.NullString
+		var gender string
+		err = rows.Scan(
+			&item.Id, &item.FirstName, &item.MiddleName, &item.LastName,
+			&item.NickName, &motherId, &fatherId, &birthDateString,
+			&item.IsBirthYearGuess, &item.IsAlive, &homeCityId, &birthCityId,
+			&deathDateString, &item.IsDeathYearGuess, &deathCityId, &burialCityId,
+			&gender)
 		if err != nil {
 			return nil, err
 		}
-	}
-	if homeCityId.Valid {
-		item.HomeCity, _ = LoadCityById(db, int(homeCityId.Int64))
-		if err != nil {
-			return nil, err
+		if birthDateString.Valid {
+			item.BirthDate, _ = time.Parse("2006-01-02", birthDateString.String)
+		}
+		if deathDateString.Valid {
+			item.DeathDate, _ = time.Parse("2006-01-02", deathDateString.String)
+		}
+		item.Gender = GetGenderName(gender)
+		if birthCityId.Valid {
+			item.BirthCity = cityLookup[int(birthCityId.Int64)]
+		}
+		if homeCityId.Valid {
+			item.HomeCity = cityLookup[int(homeCityId.Int64)]
+		}
+		if deathCityId.Valid {
+			item.DeathCity = cityLookup[int(deathCityId.Int64)]
+		}
+		if burialCityId.Valid {
+			item.BurialCity = cityLookup[int(burialCityId.Int64)]
+		}
+		list = append(list, item)
+		parentIds = append(parentIds, [2]int{int(motherId.Int64), int(fatherId.Int64)})
+	}
+
+	personLookup := make(map[int]*PersonLite)
+	for i := range list {
+		personLookup[list[i].Id] = &PersonLite{
+			Id:       list[i].Id,
+			Name:     list[i].FullName(),
+			Gender:   list[i].Gender,
+			MotherId: parentIds[i][0],
+			FatherId: parentIds[i][1],
 		}
 	}
-	item.Children, err = LoadChildrenPersonLite(db, id)
-	if err != nil {
-		return nil, err
-	}
-	item.Spouses, err = LoadSpousesByPersonId(db, id)
-	if err != nil {
-		return nil, err
-	}
-	item.Siblings, err = LoadSiblingsPersonLite(db, id)
-	if err != nil {
-		return nil, err
+	for i := range list {
+		list[i].Mother = personLookup[parentIds[i][0]]
+		list[i].Father = personLookup[parentIds[i][1]]
 	}
-	return &item, nil
+	return list, nil
 }
 
-func LoadPersonLiteList(db *sql.DB) ([]PersonLite, error) {
+func (s *MySqlStore) LoadPersonLiteList() ([]PersonLite, error) {
 	defer trace(traceName("LoadPersonLiteList"))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT id, first_name, middle_name, last_name, nick_name, gender, mother_id, father_id" +
 			" FROM people" +
 			" ORDER BY last_name, first_name, middle_name")
@@ -203,14 +465,18 @@ func LoadPersonLiteList(db *sql.DB) ([]PersonLite, error) {
 	return readPersonLiteListFromRows(rows)
 }
 
-func LoadPersonLiteListByHomeCityId(db *sql.DB, cityId int) ([]PersonLite, error) {
-	defer trace(traceName(fmt.Sprintf("LoadPersonLiteListByCity(%d)", cityId)))
-	rows, err := db.Query(
+// Load the people with the given ids in a single query, ordered by id
+func (s *MySqlStore) LoadPersonLiteListByIds(ids []int) ([]PersonLite, error) {
+	defer trace(traceName(fmt.Sprintf("LoadPersonLiteListByIds(%v)", ids)))
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.Query(
 		"SELECT id, first_name, middle_name, last_name, nick_name, gender, mother_id, father_id"+
 			" FROM people"+
-			" WHERE home_city_id=?"+
-			" ORDER BY last_name, first_name, middle_name",
-		cityId)
+			" WHERE id IN ("+sqlPlaceholders(len(ids))+")"+
+			" ORDER BY id",
+		intArgs(ids)...)
 	if err != nil {
 		return nil, err
 	}
@@ -219,14 +485,14 @@ func LoadPersonLiteListByHomeCityId(db *sql.DB, cityId int) ([]PersonLite, error
 	return readPersonLiteListFromRows(rows)
 }
 
-func LoadChildrenPersonLite(db *sql.DB, personId int) ([]PersonLite, error) {
-	defer trace(traceName(fmt.Sprintf("LoadChildrenPersonLite(%d)", personId)))
-	rows, err := db.Query(
+func (s *MySqlStore) LoadPersonLiteListByHomeCityId(cityId int) ([]PersonLite, error) {
+	defer trace(traceName(fmt.Sprintf("LoadPersonLiteListByCity(%d)", cityId)))
+	rows, err := s.db.Query(
 		"SELECT id, first_name, middle_name, last_name, nick_name, gender, mother_id, father_id"+
-			" FROM people "+
-			" WHERE father_id = ? OR mother_id = ?"+
-			" ORDER BY birth_date ASC",
-		personId, personId)
+			" FROM people"+
+			" WHERE home_city_id=?"+
+			" ORDER BY last_name, first_name, middle_name",
+		cityId)
 	if err != nil {
 		return nil, err
 	}
@@ -235,18 +501,18 @@ func LoadChildrenPersonLite(db *sql.DB, personId int) ([]PersonLite, error) {
 	return readPersonLiteListFromRows(rows)
 }
 
-func LoadSiblingsPersonLite(db *sql.DB, personId int) ([]PersonLite, error) {
-	defer trace(traceName(fmt.Sprintf("LoadSiblingsPersonLite(%d)", personId)))
-	row := db.QueryRow("SELECT father_id, mother_id FROM people WHERE id=?", personId)
-	var fatherId, motherId int
-	row.Scan(&fatherId, &motherId)
-
-	rows, err := db.Query(
+func (s *MySqlStore) LoadChildrenPersonLiteByParentIds(parentIds []int) ([]PersonLite, error) {
+	defer trace(traceName(fmt.Sprintf("LoadChildrenPersonLiteByParentIds(%v)", parentIds)))
+	if len(parentIds) == 0 {
+		return nil, nil
+	}
+	placeholders := sqlPlaceholders(len(parentIds))
+	rows, err := s.db.Query(
 		"SELECT id, first_name, middle_name, last_name, nick_name, gender, mother_id, father_id"+
-			" FROM people"+
-			" WHERE father_id=? AND mother_id=? AND id!=?"+
+			" FROM people "+
+			" WHERE father_id IN ("+placeholders+") OR mother_id IN ("+placeholders+")"+
 			" ORDER BY birth_date ASC",
-		fatherId, motherId, personId)
+		append(intArgs(parentIds), intArgs(parentIds)...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -255,9 +521,9 @@ func LoadSiblingsPersonLite(db *sql.DB, personId int) ([]PersonLite, error) {
 	return readPersonLiteListFromRows(rows)
 }
 
-func LoadPersonLiteListWithTag(db *sql.DB, tagLabel string) ([]PersonLite, error) {
+func (s *MySqlStore) LoadPersonLiteListWithTag(tagLabel string) ([]PersonLite, error) {
 	defer trace(traceName(fmt.Sprintf("LoadPersonLiteListWithTag(%+v)", tagLabel)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT p.id, p.first_name, p.middle_name, p.last_name, p.nick_name, p.gender, p.mother_id, p.father_id"+
 			" FROM people p"+
 			"   INNER JOIN people_tags pt ON pt.person_id = p.id"+
@@ -273,14 +539,15 @@ func LoadPersonLiteListWithTag(db *sql.DB, tagLabel string) ([]PersonLite, error
 	return readPersonLiteListFromRows(rows)
 }
 
-func LoadPersonLiteListByNamePrefix(db *sql.DB, prefix string, offset int) ([]PersonLite, error) {
-	defer trace(traceName(fmt.Sprintf("LoadPersonLiteListByNamePrefix(%+v, %+v)", prefix, offset)))
-	rows, err := db.Query(
-		"SELECT p.id, p.first_name, p.middle_name, p.last_name, p.nick_name, p.gender, p.mother_id, p.father_id"+
-			" FROM people p"+
-			" WHERE (first_name LIKE CONCAT(?, '%') OR last_name LIKE CONCAT(?, '%'))"+
-			" ORDER BY last_name, first_name"+
-			" LIMIT ?, 10", prefix, prefix, offset)
+func (s *MySqlStore) LoadPersonLiteListByName(firstName string, lastName string, birthDate string) ([]PersonLite, error) {
+	defer trace(traceName(fmt.Sprintf("LoadPersonLiteListByName(%s, %s, %s)", firstName, lastName, birthDate)))
+	rows, err := s.db.Query(
+		"SELECT id, first_name, middle_name, last_name, nick_name, gender, mother_id, father_id"+
+			" FROM people"+
+			" WHERE first_name=? AND last_name=?"+
+			" AND (? = '' OR birth_date IS NULL OR birth_date=?)"+
+			" ORDER BY id",
+		firstName, lastName, birthDate, birthDate)
 	if err != nil {
 		return nil, err
 	}
@@ -318,17 +585,19 @@ func readPersonLiteFromRows(rows *sql.Rows) (*PersonLite, error) {
 	return &item, nil
 }
 
-func InsertPerson(db *sql.DB, data PersonData) (int, error) {
+func (s *MySqlStore) InsertPerson(data PersonData) (int, error) {
 	defer trace(traceName(fmt.Sprintf("InsertPerson(%v)", data)))
 
-	res, err := db.Exec(
+	res, err := s.db.Exec(
 		"INSERT INTO people"+
 			" (first_name, middle_name, last_name, nick_name,"+
 			" mother_id, father_id, birth_date, is_birth_year_guess, is_alive,"+
+			" death_date, is_death_year_guess, death_city_id, burial_city_id,"+
 			" home_city_id, birth_city_id, gender)"+
-			" VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			" VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		data.FirstName, data.MiddleName, data.LastName, data.NickName,
 		getNullableInt(data.MotherId), getNullableInt(data.FatherId), getNullableString(data.BirthDate), data.IsBirthYearGuess, data.IsAlive,
+		getNullableString(data.DeathDate), data.IsDeathYearGuess, getNullableInt(data.DeathCityId), getNullableInt(data.BurialCityId),
 		getNullableInt(data.HomeCityId), getNullableInt(data.BirthCityId), data.Gender)
 	if err != nil {
 		return 0, err
@@ -337,25 +606,42 @@ func InsertPerson(db *sql.DB, data PersonData) (int, error) {
 	return int(personId), err
 }
 
-func UpdatePerson(db *sql.DB, personId int, data PersonData) error {
+func (s *MySqlStore) UpdatePerson(personId int, data PersonData) error {
 	defer trace(traceName(fmt.Sprintf("UpdatePerson(%d, %v)", personId, data)))
 
-	_, err := db.Exec(
+	_, err := s.db.Exec(
 		"UPDATE people"+
 			" SET first_name=?, middle_name=?, last_name=?, nick_name=?,"+
 			" mother_id=?, father_id=?, birth_date=?, is_birth_year_guess=?, is_alive=?,"+
+			" death_date=?, is_death_year_guess=?, death_city_id=?, burial_city_id=?,"+
 			" home_city_id=?, birth_city_id=?, gender=?"+
 			" WHERE id=?",
 		data.FirstName, data.MiddleName, data.LastName, data.NickName,
 		getNullableInt(data.MotherId), getNullableInt(data.FatherId), getNullableString(data.BirthDate), data.IsBirthYearGuess, data.IsAlive,
+		getNullableString(data.DeathDate), data.IsDeathYearGuess, getNullableInt(data.DeathCityId), getNullableInt(data.BurialCityId),
 		getNullableInt(data.HomeCityId), getNullableInt(data.BirthCityId), data.Gender,
 		personId)
 	return err
 }
 
-func DeletePerson(db *sql.DB, personId int) error {
+func (s *MySqlStore) UpdatePersonParents(personId int, motherId int, fatherId int) error {
+	defer trace(traceName(fmt.Sprintf("UpdatePersonParents(%d, %d, %d)", personId, motherId, fatherId)))
+
+	_, err := s.db.Exec(
+		"UPDATE people"+
+			" SET mother_id=?, father_id=?"+
+			" WHERE id=?",
+		getNullableInt(motherId), getNullableInt(fatherId), personId)
+	return err
+}
+
+func (s *MySqlStore) DeletePerson(personId int) error {
 	defer trace(traceName(fmt.Sprintf("DeletePerson(%d)", personId)))
-	_, err := db.Exec("DELETE from people WHERE id = ?", personId)
+	_, err := s.db.Exec("DELETE from people WHERE id = ?", personId)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("DELETE FROM alternate_names WHERE person_id = ?", personId)
 	return err
 }
 
@@ -372,3 +658,16 @@ func getNullableString(value string) sql.NullString {
 		String: strings.TrimSpace(value),
 	}
 }
+
+// Placeholders for an IN clause, like "?, ?, ?"
+func sqlPlaceholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
+func intArgs(values []int) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"net/http"
 	"strings"
 )
@@ -15,7 +14,7 @@ type RegionGroup struct {
 }
 
 func regionList(w http.ResponseWriter, r *http.Request) {
-	regions, err := LoadRegionList(db)
+	regions, err := store.LoadRegionList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading regions: %v", err), 500)
 		return
@@ -35,14 +34,11 @@ func regionList(w http.ResponseWriter, r *http.Request) {
 		group.Regions = append(group.Regions, region)
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/region/list.html")).Execute(w, regionGroups)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "region/list.html", regionGroups)
 }
 
 func regionJsonList(w http.ResponseWriter, r *http.Request) {
-	regions, err := LoadRegionList(db)
+	regions, err := store.LoadRegionList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading regions: %v", err), 500)
 		return
@@ -58,12 +54,12 @@ func regionView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	region, err := LoadRegionById(db, regionId)
+	region, err := store.LoadRegionById(regionId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading region: %v", err), 500)
 		return
 	}
-	cities, err := LoadCitiesByRegionId(db, regionId)
+	cities, err := store.LoadCitiesByRegionId(regionId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading cities: %v", err), 500)
 		return
@@ -77,10 +73,7 @@ func regionView(w http.ResponseWriter, r *http.Request) {
 		cities,
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/region/view.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "region/view.html", data)
 }
 
 func regionAdd(w http.ResponseWriter, r *http.Request) {
@@ -94,7 +87,7 @@ func regionAdd(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("Error, empty name or country code: %v", data), 400)
 			return
 		}
-		_, err := InsertRegion(db, data)
+		_, err := store.InsertRegion(data)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error creating region: %v", err), 500)
 			return
@@ -103,7 +96,7 @@ func regionAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	countries, err := LoadCountryList(db)
+	countries, err := store.LoadCountryList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error reading countries: %v", err), 500)
 		return
@@ -117,10 +110,7 @@ func regionAdd(w http.ResponseWriter, r *http.Request) {
 		r.FormValue("country_code"),
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/region/add.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "region/add.html", data)
 }
 
 func regionEdit(w http.ResponseWriter, r *http.Request) {
@@ -140,7 +130,7 @@ func regionEdit(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf("Error, empty name or country code: %v", data), 400)
 			return
 		}
-		err = UpdateRegion(db, regionId, data)
+		err = store.UpdateRegion(regionId, data)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error updating region: %v", err), 500)
 			return
@@ -149,13 +139,13 @@ func regionEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	region, err := LoadRegionById(db, regionId)
+	region, err := store.LoadRegionById(regionId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading region: %v", err), 400)
 		return
 	}
 
-	countries, err := LoadCountryList(db)
+	countries, err := store.LoadCountryList()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error reading countries: %v", err), 500)
 		return
@@ -169,10 +159,7 @@ func regionEdit(w http.ResponseWriter, r *http.Request) {
 		region,
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/region/edit.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "region/edit.html", data)
 }
 
 func regionDelete(w http.ResponseWriter, r *http.Request) {
@@ -182,13 +169,13 @@ func regionDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	region, err := LoadRegionById(db, regionId)
+	region, err := store.LoadRegionById(regionId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading region: %v", err), 400)
 		return
 	}
 
-	err = DeleteRegion(db, regionId)
+	err = store.DeleteRegion(regionId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error deleting region: %v", err), 500)
 		return
@@ -198,10 +185,10 @@ func regionDelete(w http.ResponseWriter, r *http.Request) {
 }
 
 func addRegionRoutes() {
-	http.HandleFunc("/region/add", regionAdd)
-	http.HandleFunc("/region/edit/", regionEdit)
-	http.HandleFunc("/region/list", regionList)
-	http.HandleFunc("/region/json/list", regionJsonList)
-	http.HandleFunc("/region/view/", regionView)
-	http.HandleFunc("/region/delete/", regionDelete)
+	http.HandleFunc("/region/add", requireRole(RoleEditor, regionAdd))
+	http.HandleFunc("/region/edit/", requireRole(RoleEditor, regionEdit))
+	http.HandleFunc("/region/list", requireRole(RoleViewer, regionList))
+	http.HandleFunc("/region/json/list", requireRole(RoleViewer, regionJsonList))
+	http.HandleFunc("/region/view/", requireRole(RoleViewer, regionView))
+	http.HandleFunc("/region/delete/", requireRole(RoleAdmin, requirePost(regionDelete)))
 }
@@ -20,9 +20,9 @@ type RegionData struct {
 	CountryCode string
 }
 
-func LoadRegionById(db *sql.DB, id int) (*RegionLite, error) {
+func (s *MySqlStore) LoadRegionById(id int) (*RegionLite, error) {
 	trace(traceName(fmt.Sprintf("LoadRegionById(%d)", id)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT region_id, region_code, region_name, country_code, country_name, has_region_icon"+
 			" FROM region_view"+
 			" WHERE region_id=?", id)
@@ -31,7 +31,7 @@ func LoadRegionById(db *sql.DB, id int) (*RegionLite, error) {
 	}
 	defer rows.Close()
 	if !rows.Next() {
-		return nil, fmt.Errorf("Region not found (id: %d)", id)
+		return nil, notFoundf("Region not found (id: %d)", id)
 	}
 	region, err := readRegionFromRows(rows)
 	if err != nil {
@@ -40,9 +40,9 @@ func LoadRegionById(db *sql.DB, id int) (*RegionLite, error) {
 	return region, nil
 }
 
-func LoadRegionsByCountryCode(db *sql.DB, countryCode string) ([]RegionLite, error) {
+func (s *MySqlStore) LoadRegionsByCountryCode(countryCode string) ([]RegionLite, error) {
 	trace(traceName(fmt.Sprintf("LoadRegionsByCountryCode(%s)", countryCode)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT region_id, region_code, region_name, country_code, country_name, has_region_icon"+
 			" FROM region_view"+
 			" WHERE country_code=?"+
@@ -54,9 +54,9 @@ func LoadRegionsByCountryCode(db *sql.DB, countryCode string) ([]RegionLite, err
 	return readRegionListFromRows(rows)
 }
 
-func LoadRegionList(db *sql.DB) ([]RegionLite, error) {
+func (s *MySqlStore) LoadRegionList() ([]RegionLite, error) {
 	trace(traceName("LoadRegionList"))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT region_id, region_code, region_name, country_code, country_name, has_region_icon" +
 			" FROM region_view" +
 			" ORDER BY country_name, region_name")
@@ -67,15 +67,15 @@ func LoadRegionList(db *sql.DB) ([]RegionLite, error) {
 	return readRegionListFromRows(rows)
 }
 
-func DeleteRegion(db *sql.DB, regionId int) error {
+func (s *MySqlStore) DeleteRegion(regionId int) error {
 	defer trace(traceName(fmt.Sprintf("DeleteRegion(%d)", regionId)))
-	_, err := db.Exec("DELETE FROM regions WHERE region_id=?", regionId)
+	_, err := s.db.Exec("DELETE FROM regions WHERE region_id=?", regionId)
 	return err
 }
 
-func InsertRegion(db *sql.DB, data RegionData) (*RegionLite, error) {
+func (s *MySqlStore) InsertRegion(data RegionData) (*RegionLite, error) {
 	defer trace(traceName(fmt.Sprintf("InsertRegion (data: %v)", data)))
-	res, err := db.Exec(
+	res, err := s.db.Exec(
 		"INSERT INTO regions"+
 			" (name, code, country_code)"+
 			" VALUES(?, ?, ?)",
@@ -87,12 +87,12 @@ func InsertRegion(db *sql.DB, data RegionData) (*RegionLite, error) {
 	if err != nil {
 		return nil, err
 	}
-	return LoadRegionById(db, int(regionId))
+	return s.LoadRegionById(int(regionId))
 }
 
-func UpdateRegion(db *sql.DB, regionId int, data RegionData) error {
+func (s *MySqlStore) UpdateRegion(regionId int, data RegionData) error {
 	defer trace(traceName(fmt.Sprintf("UpdateRegion(%d, %v)", regionId, data)))
-	_, err := db.Exec(
+	_, err := s.db.Exec(
 		"UPDATE regions"+
 			" SET name=?, code=?, country_code=?"+
 			" WHERE id=?",
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+func personRelationship(w http.ResponseWriter, r *http.Request) {
+	personAId, _ := strconv.Atoi(r.FormValue("a"))
+	personBId, _ := strconv.Atoi(r.FormValue("b"))
+
+	var err error
+	var personA, personB *PersonLite
+	var relationship *Relationship
+	if personAId > 0 {
+		personA, err = store.LoadPersonLiteById(personAId)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error loading person: %v", err), 500)
+			return
+		}
+	}
+	if personBId > 0 {
+		personB, err = store.LoadPersonLiteById(personBId)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error loading person: %v", err), 500)
+			return
+		}
+	}
+	if personA != nil && personB != nil {
+		relationship, err = FindRelationship(store, personAId, personBId)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error finding relationship: %v", err), 500)
+			return
+		}
+	}
+
+	data := struct {
+		PersonA      *PersonLite
+		PersonB      *PersonLite
+		Relationship *Relationship
+	}{
+		personA,
+		personB,
+		relationship,
+	}
+
+	renderTemplate(w, r, "person/relationship.html", data)
+}
+
+func personJsonRelationship(w http.ResponseWriter, r *http.Request) {
+	personAId, err := strconv.Atoi(r.FormValue("a"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse person id a: %v", err), 400)
+		return
+	}
+	personBId, err := strconv.Atoi(r.FormValue("b"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse person id b: %v", err), 400)
+		return
+	}
+
+	relationship, err := FindRelationship(store, personAId, personBId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error finding relationship: %v", err), 500)
+		return
+	}
+
+	data := struct {
+		*Relationship
+		Description string
+	}{
+		relationship,
+		relationship.Description(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func addRelationshipRoutes() {
+	http.HandleFunc("/person/relationship", requireRole(RoleViewer, personRelationship))
+	http.HandleFunc("/person/json/relationship", requireRole(RoleViewer, personJsonRelationship))
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// How far up the family tree to look for a common ancestor
+const maxRelationshipDepth = 12
+
+type RelationshipStep struct {
+	Person PersonLite
+	// What this person is to the previous step, like "mother" or "husband"
+	Link string
+}
+
+type Relationship struct {
+	PersonA PersonLite
+	PersonB PersonLite
+	// What B is to A, like "second cousin once removed", or empty when they
+	// aren't related
+	Name            string
+	CommonAncestors []PersonLite
+	// The people connecting A to B, starting with A
+	Path []RelationshipStep
+}
+
+func (r *Relationship) IsRelated() bool {
+	return r.Name != ""
+}
+
+// Sentence describing the relationship, like "Bob is Alice's brother"
+func (r *Relationship) Description() string {
+	if !r.IsRelated() {
+		return fmt.Sprintf("%s and %s are not related", r.PersonB.Name, r.PersonA.Name)
+	}
+	if r.PersonA.Id == r.PersonB.Id {
+		return fmt.Sprintf("%s is the same person", r.PersonA.Name)
+	}
+	return fmt.Sprintf("%s is %s's %s", r.PersonB.Name, r.PersonA.Name, r.Name)
+}
+
+type ancestorStep struct {
+	Generations int
+	// The child of the ancestor on the way back down to the person
+	ChildId int
+}
+
+// A shared line of descent: GenerationsA steps up from A and GenerationsB
+// steps up from B both reach the common ancestors.
+type bloodLink struct {
+	GenerationsA int
+	GenerationsB int
+	IsHalf       bool
+	Common       []int
+	Path         []RelationshipStep
+}
+
+type relationshipFinder struct {
+	store  Store
+	people map[int]PersonLite
+}
+
+// Work out how B is related to A by blood or by marriage
+func FindRelationship(store Store, personAId int, personBId int) (*Relationship, error) {
+	defer trace(traceName(fmt.Sprintf("FindRelationship(%d, %d)", personAId, personBId)))
+
+	f := &relationshipFinder{store: store, people: make(map[int]PersonLite)}
+	err := f.load([]int{personAId, personBId})
+	if err != nil {
+		return nil, err
+	}
+	personA, found := f.people[personAId]
+	if !found {
+		return nil, notFoundf("Person not found with id: %d", personAId)
+	}
+	personB, found := f.people[personBId]
+	if !found {
+		return nil, notFoundf("Person not found with id: %d", personBId)
+	}
+	relationship := &Relationship{PersonA: personA, PersonB: personB}
+
+	// Related by blood
+	link, err := f.bloodLink(personAId, personBId)
+	if err != nil {
+		return nil, err
+	}
+	if link != nil {
+		relationship.Name = bloodRelationshipName(link.GenerationsA, link.GenerationsB, personB.Gender, link.IsHalf)
+		relationship.Path = link.Path
+		for _, id := range link.Common {
+			relationship.CommonAncestors = append(relationship.CommonAncestors, f.people[id])
+		}
+		return relationship, nil
+	}
+
+	// Married to each other, or related through one of their spouses
+	spousesA, err := f.spouses(personAId)
+	if err != nil {
+		return nil, err
+	}
+	for _, spouse := range spousesA {
+		if spouse.Person2.Id == personBId {
+			relationship.Name = spouseName(spouse)
+			relationship.Path = []RelationshipStep{{Person: personA}, {Person: personB, Link: relationship.Name}}
+			return relationship, nil
+		}
+	}
+	spousesB, err := f.spouses(personBId)
+	if err != nil {
+		return nil, err
+	}
+
+	best := -1
+	consider := func(distance int, name string, path []RelationshipStep, common []int) {
+		if best >= 0 && distance >= best {
+			return
+		}
+		best = distance
+		relationship.Name = name
+		relationship.Path = path
+		relationship.CommonAncestors = nil
+		for _, id := range common {
+			relationship.CommonAncestors = append(relationship.CommonAncestors, f.people[id])
+		}
+	}
+
+	// B is a blood relative of A's spouse
+	for _, spouse := range spousesA {
+		link, err := f.bloodLink(spouse.Person2.Id, personBId)
+		if err != nil {
+			return nil, err
+		}
+		if link == nil {
+			continue
+		}
+		path := append([]RelationshipStep{{Person: personA}, {Person: spouse.Person2, Link: spouseName(spouse)}}, link.Path[1:]...)
+		consider(link.GenerationsA+link.GenerationsB+1, spouseRelativeName(spouse, link, personB.Gender), path, link.Common)
+	}
+
+	// B is the spouse of a blood relative of A
+	for _, spouse := range spousesB {
+		link, err := f.bloodLink(personAId, spouse.Person2.Id)
+		if err != nil {
+			return nil, err
+		}
+		if link == nil {
+			continue
+		}
+		path := append(link.Path, RelationshipStep{Person: personB, Link: spouseName(swapSpouse(spouse))})
+		consider(link.GenerationsA+link.GenerationsB+1, relativeSpouseName(link, spouse), path, link.Common)
+	}
+
+	return relationship, nil
+}
+
+// Load any people not already known, in a single query
+func (f *relationshipFinder) load(ids []int) error {
+	var missing []int
+	for _, id := range uniqueInts(ids) {
+		if _, found := f.people[id]; !found && id > 0 {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	people, err := f.store.LoadPersonLiteListByIds(missing)
+	if err != nil {
+		return err
+	}
+	for _, person := range people {
+		f.people[person.Id] = person
+	}
+	return nil
+}
+
+// Spouses of a person, with the person as Person1
+func (f *relationshipFinder) spouses(personId int) ([]SpouseLite, error) {
+	list, err := f.store.LoadSpousesByPersonIds([]int{personId})
+	if err != nil {
+		return nil, err
+	}
+	var spouses []SpouseLite
+	for _, spouse := range list {
+		if spouse.Person1.Id != personId {
+			spouse = swapSpouse(spouse)
+		}
+		f.people[spouse.Person2.Id] = spouse.Person2
+		spouses = append(spouses, spouse)
+	}
+	return spouses, nil
+}
+
+// Every ancestor of a person, including themselves, with the shortest number
+// of generations up to them.  Each generation takes at most one query.
+func (f *relationshipFinder) ancestors(personId int) (map[int]ancestorStep, error) {
+	result := map[int]ancestorStep{personId: {}}
+	generation := []int{personId}
+	for level := 1; level <= maxRelationshipDepth && len(generation) > 0; level++ {
+		var parentIds []int
+		for _, id := range generation {
+			person := f.people[id]
+			parentIds = append(parentIds, person.MotherId, person.FatherId)
+		}
+		err := f.load(parentIds)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []int
+		for _, id := range generation {
+			person := f.people[id]
+			for _, parentId := range []int{person.MotherId, person.FatherId} {
+				if _, found := f.people[parentId]; !found {
+					continue
+				}
+				if _, found := result[parentId]; found {
+					continue
+				}
+				result[parentId] = ancestorStep{Generations: level, ChildId: id}
+				next = append(next, parentId)
+			}
+		}
+		generation = next
+	}
+	return result, nil
+}
+
+// Find the closest common ancestors of two people, or nil when there are none
+func (f *relationshipFinder) bloodLink(personAId int, personBId int) (*bloodLink, error) {
+	ancestorsA, err := f.ancestors(personAId)
+	if err != nil {
+		return nil, err
+	}
+	ancestorsB, err := f.ancestors(personBId)
+	if err != nil {
+		return nil, err
+	}
+
+	var link *bloodLink
+	for id, stepA := range ancestorsA {
+		stepB, found := ancestorsB[id]
+		if !found {
+			continue
+		}
+		distance := stepA.Generations + stepB.Generations
+		if link == nil || distance < link.GenerationsA+link.GenerationsB ||
+			(distance == link.GenerationsA+link.GenerationsB && stepA.Generations < link.GenerationsA) {
+			link = &bloodLink{GenerationsA: stepA.Generations, GenerationsB: stepB.Generations, Common: []int{id}}
+		} else if stepA.Generations == link.GenerationsA && stepB.Generations == link.GenerationsB {
+			link.Common = append(link.Common, id)
+		}
+	}
+	if link == nil {
+		return nil, nil
+	}
+	sort.Ints(link.Common)
+	ancestorId := link.Common[0]
+	lineA := descentLine(ancestorsA, ancestorId)
+	lineB := descentLine(ancestorsB, ancestorId)
+
+	// Up from A to the common ancestor, then back down to B
+	link.Path = append(link.Path, RelationshipStep{Person: f.people[personAId]})
+	for i := len(lineA) - 2; i >= 0; i-- {
+		person := f.people[lineA[i]]
+		link.Path = append(link.Path, RelationshipStep{Person: person, Link: genderWord(person.Gender, "father", "mother")})
+	}
+	for _, id := range lineB[1:] {
+		person := f.people[id]
+		link.Path = append(link.Path, RelationshipStep{Person: person, Link: genderWord(person.Gender, "son", "daughter")})
+	}
+
+	// Half relations descend from different partners of the common ancestor.
+	// A parent that isn't recorded is assumed to be shared.
+	if link.GenerationsA > 0 && link.GenerationsB > 0 {
+		childA := f.people[lineA[1]]
+		childB := f.people[lineB[1]]
+		link.IsHalf = (childA.MotherId > 0 && childB.MotherId > 0 && childA.MotherId != childB.MotherId) ||
+			(childA.FatherId > 0 && childB.FatherId > 0 && childA.FatherId != childB.FatherId)
+	}
+	return link, nil
+}
+
+// The line of descent from an ancestor down to the person the ancestors were
+// loaded for, starting with the ancestor
+func descentLine(ancestors map[int]ancestorStep, ancestorId int) []int {
+	line := []int{ancestorId}
+	for id := ancestorId; ancestors[id].Generations > 0; {
+		id = ancestors[id].ChildId
+		line = append(line, id)
+	}
+	return line
+}
+
+func swapSpouse(spouse SpouseLite) SpouseLite {
+	spouse.Person1, spouse.Person2 = spouse.Person2, spouse.Person1
+	return spouse
+}
+
+func genderWord(gender string, male string, female string) string {
+	if gender == "Male" {
+		return male
+	}
+	return female
+}
+
+func greats(count int) string {
+	if count <= 0 {
+		return ""
+	}
+	return strings.Repeat("great-", count)
+}
+
+func ordinal(value int) string {
+	words := []string{"zeroth", "first", "second", "third", "fourth", "fifth", "sixth", "seventh", "eighth", "ninth", "tenth"}
+	if value < len(words) {
+		return words[value]
+	}
+	return fmt.Sprintf("%dth", value)
+}
+
+func timesRemoved(count int) string {
+	switch count {
+	case 0:
+		return ""
+	case 1:
+		return " once removed"
+	case 2:
+		return " twice removed"
+	default:
+		return fmt.Sprintf(" %d times removed", count)
+	}
+}
+
+// Name what B is to A, given the generations from each up to their closest
+// common ancestor and B's gender
+func bloodRelationshipName(generationsA int, generationsB int, gender string, isHalf bool) string {
+	half := ""
+	if isHalf {
+		half = "half-"
+	}
+	switch {
+	case generationsA == 0 && generationsB == 0:
+		return "self"
+	case generationsA == 0 && generationsB == 1:
+		return genderWord(gender, "son", "daughter")
+	case generationsA == 0:
+		return greats(generationsB-2) + genderWord(gender, "grandson", "granddaughter")
+	case generationsB == 0 && generationsA == 1:
+		return genderWord(gender, "father", "mother")
+	case generationsB == 0:
+		return greats(generationsA-2) + genderWord(gender, "grandfather", "grandmother")
+	case generationsA == 1 && generationsB == 1:
+		return half + genderWord(gender, "brother", "sister")
+	case generationsA == 1:
+		return half + greats(generationsB-2) + genderWord(gender, "nephew", "niece")
+	case generationsB == 1:
+		return half + greats(generationsA-2) + genderWord(gender, "uncle", "aunt")
+	}
+	degree := generationsA
+	if generationsB < degree {
+		degree = generationsB
+	}
+	removed := generationsA - generationsB
+	if removed < 0 {
+		removed = -removed
+	}
+	if isHalf {
+		half = "half "
+	}
+	return half + ordinal(degree-1) + " cousin" + timesRemoved(removed)
+}
+
+// What Person2 is to Person1
+func spouseName(spouse SpouseLite) string {
+	switch spouse.Status {
+	case 2:
+		return "partner"
+	case 3:
+		return genderWord(spouse.Person2.Gender, "ex-husband", "ex-wife")
+	default:
+		return genderWord(spouse.Person2.Gender, "husband", "wife")
+	}
+}
+
+// Name a blood relative of A's spouse, like "brother-in-law" or "wife's cousin"
+func spouseRelativeName(spouse SpouseLite, link *bloodLink, gender string) string {
+	if spouse.Status == 1 && !link.IsHalf {
+		switch {
+		case link.GenerationsA == 1 && link.GenerationsB == 0:
+			return genderWord(gender, "father-in-law", "mother-in-law")
+		case link.GenerationsA == 1 && link.GenerationsB == 1:
+			return genderWord(gender, "brother-in-law", "sister-in-law")
+		case link.GenerationsA == 0 && link.GenerationsB == 1:
+			return genderWord(gender, "stepson", "stepdaughter")
+		}
+	}
+	return spouseName(spouse) + "'s " + bloodRelationshipName(link.GenerationsA, link.GenerationsB, gender, link.IsHalf)
+}
+
+// Name the spouse of a blood relative of A, like "son-in-law" or "cousin's wife"
+func relativeSpouseName(link *bloodLink, spouse SpouseLite) string {
+	relative := spouse.Person2
+	gender := spouse.Person1.Gender
+	if spouse.Status == 1 && !link.IsHalf {
+		switch {
+		case link.GenerationsA == 1 && link.GenerationsB == 1:
+			return genderWord(gender, "brother-in-law", "sister-in-law")
+		case link.GenerationsA == 0 && link.GenerationsB == 1:
+			return genderWord(gender, "son-in-law", "daughter-in-law")
+		case link.GenerationsA == 1 && link.GenerationsB == 0:
+			return genderWord(gender, "stepfather", "stepmother")
+		}
+	}
+	return bloodRelationshipName(link.GenerationsA, link.GenerationsB, relative.Gender, link.IsHalf) + "'s " + spouseName(swapSpouse(spouse))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBloodRelationshipName(t *testing.T) {
+	cases := []struct {
+		generationsA int
+		generationsB int
+		gender       string
+		isHalf       bool
+		name         string
+	}{
+		{0, 0, "Female", false, "self"},
+		{0, 1, "Male", false, "son"},
+		{0, 2, "Female", false, "granddaughter"},
+		{0, 4, "Male", false, "great-great-grandson"},
+		{1, 0, "Female", false, "mother"},
+		{3, 0, "Male", false, "great-grandfather"},
+		{1, 1, "Male", false, "brother"},
+		{1, 1, "Female", true, "half-sister"},
+		{1, 2, "Female", false, "niece"},
+		{1, 3, "Male", false, "great-nephew"},
+		{2, 1, "Male", false, "uncle"},
+		{3, 1, "Female", false, "great-aunt"},
+		{4, 1, "Male", true, "half-great-great-uncle"},
+		{2, 2, "Male", false, "first cousin"},
+		{2, 2, "Female", true, "half first cousin"},
+		{2, 3, "Male", false, "first cousin once removed"},
+		{4, 2, "Female", false, "first cousin twice removed"},
+		{3, 3, "Male", false, "second cousin"},
+		{3, 4, "Female", false, "second cousin once removed"},
+		{5, 8, "Male", false, "fourth cousin 3 times removed"},
+		{12, 12, "Female", false, "11th cousin"},
+	}
+	for _, c := range cases {
+		name := bloodRelationshipName(c.generationsA, c.generationsB, c.gender, c.isHalf)
+		if name != c.name {
+			t.Errorf("bloodRelationshipName(%d, %d, %s, %v) = %q, want %q", c.generationsA, c.generationsB, c.gender, c.isHalf, name, c.name)
+		}
+	}
+}
+
+func TestFindRelationship(t *testing.T) {
+	store := NewMemoryStore()
+	person := func(name string, gender string, fatherId int, motherId int) int {
+		return insertTestPerson(t, store, PersonData{FirstName: name, LastName: "Tester", Gender: gender, FatherId: fatherId, MotherId: motherId})
+	}
+	marry := func(person1Id int, person2Id int, status int) {
+		err := store.InsertSpouse(person1Id, person2Id, status, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	greatGrandpa := person("Great Grandpa", "M", 0, 0)
+	grandpa := person("Grandpa", "M", greatGrandpa, 0)
+	grandma := person("Grandma", "F", 0, 0)
+	marry(grandpa, grandma, 1)
+	greatUncle := person("Great Uncle", "M", greatGrandpa, 0)
+	greatUnclesSon := person("Great Uncle's Son", "M", greatUncle, 0)
+	greatUnclesGrandson := person("Great Uncle's Grandson", "M", greatUnclesSon, 0)
+
+	dad := person("Dad", "M", grandpa, grandma)
+	mum := person("Mum", "F", 0, 0)
+	marry(dad, mum, 1)
+	uncle := person("Uncle", "M", grandpa, grandma)
+	unclesExWife := person("Uncle's Ex", "F", 0, 0)
+	marry(uncle, unclesExWife, 3)
+	aunt := person("Aunt", "F", grandpa, grandma)
+
+	me := person("Me", "F", dad, mum)
+	brother := person("Brother", "M", dad, mum)
+	brothersWife := person("Brother's Wife", "F", 0, 0)
+	marry(brother, brothersWife, 1)
+	dadsEx := person("Dad's Ex", "F", 0, 0)
+	halfSister := person("Half Sister", "F", dad, dadsEx)
+	// A parent that isn't recorded is taken to be shared
+	sister := person("Sister", "F", dad, 0)
+	cousin := person("Cousin", "M", uncle, 0)
+	auntsDaughter := person("Aunt's Daughter", "F", 0, aunt)
+	nephew := person("Nephew", "M", brother, brothersWife)
+	cousinsSon := person("Cousin's Son", "M", cousin, 0)
+	cousinsGrandson := person("Cousin's Grandson", "M", cousinsSon, 0)
+
+	fatherInLaw := person("Father In Law", "M", 0, 0)
+	husband := person("Husband", "M", fatherInLaw, 0)
+	husbandsBrother := person("Husband's Brother", "M", fatherInLaw, 0)
+	marry(husband, me, 1)
+	partner := person("Partner", "M", 0, 0)
+	marry(cousin, partner, 2)
+	stranger := person("Stranger", "F", 0, 0)
+
+	cases := []struct {
+		personAId int
+		personBId int
+		name      string
+	}{
+		{me, me, "self"},
+		{me, dad, "father"},
+		{me, mum, "mother"},
+		{me, grandpa, "grandfather"},
+		{me, greatGrandpa, "great-grandfather"},
+		{grandpa, me, "granddaughter"},
+		{me, brother, "brother"},
+		{me, halfSister, "half-sister"},
+		{me, sister, "sister"},
+		{me, uncle, "uncle"},
+		{me, aunt, "aunt"},
+		{me, greatUncle, "great-uncle"},
+		{greatUncle, me, "great-niece"},
+		{me, nephew, "nephew"},
+		{me, cousin, "first cousin"},
+		{me, auntsDaughter, "first cousin"},
+		{me, cousinsSon, "first cousin once removed"},
+		{me, cousinsGrandson, "first cousin twice removed"},
+		{me, greatUnclesSon, "first cousin once removed"},
+		{me, greatUnclesGrandson, "second cousin"},
+		{me, husband, "husband"},
+		{husband, me, "wife"},
+		{me, fatherInLaw, "father-in-law"},
+		{fatherInLaw, me, "daughter-in-law"},
+		{me, husbandsBrother, "brother-in-law"},
+		{me, brothersWife, "sister-in-law"},
+		{husband, dad, "father-in-law"},
+		{mum, halfSister, "stepdaughter"},
+		{me, unclesExWife, "uncle's ex-wife"},
+		{me, partner, "first cousin's partner"},
+		{husband, cousin, "wife's first cousin"},
+		{me, stranger, ""},
+	}
+	for _, c := range cases {
+		relationship, err := FindRelationship(store, c.personAId, c.personBId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if relationship.Name != c.name {
+			t.Errorf("%s is %s's %q, want %q", relationship.PersonB.Name, relationship.PersonA.Name, relationship.Name, c.name)
+		}
+		if relationship.IsRelated() && c.personAId != c.personBId {
+			path := relationship.Path
+			if len(path) < 2 || path[0].Person.Id != c.personAId || path[len(path)-1].Person.Id != c.personBId {
+				t.Errorf("Path from %s to %s is %+v", relationship.PersonA.Name, relationship.PersonB.Name, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Read the recipient from the add and edit forms
+func parseReminderRecipientForm(r *http.Request) (*ReminderRecipientData, error) {
+	data := ReminderRecipientData{
+		Name:          strings.TrimSpace(r.FormValue("name")),
+		Email:         strings.TrimSpace(r.FormValue("email")),
+		Birthdays:     r.FormValue("birthdays") == "1",
+		Anniversaries: r.FormValue("anniversaries") == "1",
+		Holidays:      r.FormValue("holidays") == "1",
+		Memorials:     r.FormValue("memorials") == "1",
+		LeadDays:      defaultReminderLeadDays,
+		CountryCode:   strings.TrimSpace(r.FormValue("country_code")),
+	}
+	if data.Name == "" {
+		return nil, fmt.Errorf("Empty name")
+	}
+	if !strings.Contains(data.Email, "@") {
+		return nil, fmt.Errorf("Invalid email: %s", data.Email)
+	}
+	if r.FormValue("lead_days") != "" {
+		leadDays, err := strconv.Atoi(r.FormValue("lead_days"))
+		if err != nil || leadDays < 1 || leadDays > maxReminderLeadDays {
+			return nil, fmt.Errorf("Lead time must be between 1 and %d days", maxReminderLeadDays)
+		}
+		data.LeadDays = leadDays
+	}
+
+	r.ParseForm()
+	for _, value := range r.Form["tag_id"] {
+		tagId, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tag_id: %s", value)
+		}
+		data.TagIds = append(data.TagIds, tagId)
+	}
+	// The branch typeahead leaves an empty id when nothing is picked
+	for _, value := range r.Form["branch_id"] {
+		if value == "" {
+			continue
+		}
+		personId, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid branch_id: %s", value)
+		}
+		data.BranchPersonIds = append(data.BranchPersonIds, personId)
+	}
+	return &data, nil
+}
+
+func renderReminderRecipientForm(w http.ResponseWriter, r *http.Request, recipient *ReminderRecipient) {
+	tags, err := store.LoadTagsListByPrefix("")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading tags: %v", err), 500)
+		return
+	}
+	countries, err := store.LoadCountryList()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading countries: %v", err), 500)
+		return
+	}
+	selectedTags := make(map[int]bool)
+	for _, tagId := range recipient.TagIds {
+		selectedTags[tagId] = true
+	}
+	data := struct {
+		Recipient    *ReminderRecipient
+		Tags         []Tag
+		SelectedTags map[int]bool
+		Countries    []Country
+		MaxLeadDays  int
+	}{
+		Recipient:    recipient,
+		Tags:         tags,
+		SelectedTags: selectedTags,
+		Countries:    countries,
+		MaxLeadDays:  maxReminderLeadDays,
+	}
+	renderTemplate(w, r, "reminder/edit.html", data)
+}
+
+func reminderList(w http.ResponseWriter, r *http.Request) {
+	recipients, err := store.LoadReminderRecipientList()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading recipients: %v", err), 500)
+		return
+	}
+	renderTemplate(w, r, "reminder/list.html", recipients)
+}
+
+func reminderAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		data, err := parseReminderRecipientForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		_, err = store.InsertReminderRecipient(*data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error creating recipient: %v", err), 500)
+			return
+		}
+		http.Redirect(w, r, "/reminder/list", 302)
+		return
+	}
+
+	renderReminderRecipientForm(w, r, &ReminderRecipient{
+		ReminderRecipientData: ReminderRecipientData{
+			Birthdays:     true,
+			Anniversaries: true,
+			Holidays:      true,
+			LeadDays:      defaultReminderLeadDays,
+		},
+	})
+}
+
+func reminderEdit(w http.ResponseWriter, r *http.Request) {
+	recipientId, err := getIntPathParam(r, "recipientId", 3 /* index */)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing recipientId: %v", err), 400)
+		return
+	}
+
+	if r.Method == "POST" {
+		data, err := parseReminderRecipientForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		err = store.UpdateReminderRecipient(recipientId, *data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error updating recipient: %v", err), 500)
+			return
+		}
+		http.Redirect(w, r, "/reminder/list", 302)
+		return
+	}
+
+	recipient, err := store.LoadReminderRecipientById(recipientId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading recipient: %v", err), 500)
+		return
+	}
+	renderReminderRecipientForm(w, r, recipient)
+}
+
+func reminderDelete(w http.ResponseWriter, r *http.Request) {
+	recipientId, err := getIntPathParam(r, "recipientId", 3 /* index */)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error parsing recipientId: %v", err), 400)
+		return
+	}
+	err = store.DeleteReminderRecipient(recipientId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting recipient (id: %d): %v", recipientId, err), 500)
+		return
+	}
+	http.Redirect(w, r, "/reminder/list", 302)
+}
+
+func addReminderRoutes() {
+	http.HandleFunc("/reminder/list", requireRole(RoleAdmin, reminderList))
+	http.HandleFunc("/reminder/add", requireRole(RoleAdmin, reminderAdd))
+	http.HandleFunc("/reminder/edit/", requireRole(RoleAdmin, reminderEdit))
+	http.HandleFunc("/reminder/delete/", requireRole(RoleAdmin, requirePost(reminderDelete)))
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A run of the reminder job for the week starting at WeekStart
+type ReminderRun struct {
+	Id            int
+	WeekStart     time.Time
+	StartedAt     time.Time
+	FinishedAt    *time.Time
+	EmailsSent    int
+	EmailsSkipped int
+	Error         string
+}
+
+// Stops the scheduler and a manual send from running at the same time in
+// this process.  Claiming each email in the store covers other processes.
+var reminderRunMutex sync.Mutex
+
+// How long a claimed email can go without being marked sent before another
+// run takes it over, as the process that claimed it may have died part way
+const reminderClaimTimeout = 30 * time.Minute
+
+// Reminder emails are sent a week at a time, starting on a Monday
+func checkReminderWeekStart(startDate time.Time) error {
+	if startDate.Weekday() != time.Monday {
+		return fmt.Errorf("Reminder emails start on a Monday, and %s is a %s", startDate.Format("2006-01-02"), startDate.Weekday())
+	}
+	return nil
+}
+
+// Whether a claim on an email was never marked sent and has timed out
+func isStaleReminderClaim(claimedAt time.Time, sentAt *time.Time, now time.Time) bool {
+	return sentAt == nil && now.Sub(claimedAt) > reminderClaimTimeout
+}
+
+// Send each reminder recipient the events they want for the week starting at
+// startDate, which must be a Monday so each week is only sent once.
+// Recipients already sent that week are skipped, as are recipients with
+// nothing coming up, and one failing doesn't stop the rest being sent.  The
+// run and each email sent are recorded in the store.
+func RunReminders(store Store, startDate time.Time) (*ReminderRun, error) {
+	defer trace(traceName(fmt.Sprintf("RunReminders(%v)", startDate)))
+	err := checkReminderWeekStart(startDate)
+	if err != nil {
+		return nil, err
+	}
+	reminderRunMutex.Lock()
+	defer reminderRunMutex.Unlock()
+
+	weekStart := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.UTC)
+	run := &ReminderRun{WeekStart: weekStart, StartedAt: time.Now()}
+	run.Id, err = store.InsertReminderRun(run.WeekStart, run.StartedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sendReminderEmails(store, run, startDate)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	finishErr := store.FinishReminderRun(run)
+	if err == nil {
+		err = finishErr
+	}
+	return run, err
+}
+
+func sendReminderEmails(store Store, run *ReminderRun, startDate time.Time) error {
+	recipients, err := store.LoadReminderRecipientList()
+	if err != nil {
+		return err
+	}
+	var failures []string
+	for i := range recipients {
+		recipient := &recipients[i]
+		events, err := LoadRecipientReminderEvents(store, recipient, startDate)
+		if err != nil {
+			return fmt.Errorf("Error loading reminders for %s: %v", recipient.Email, err)
+		}
+		if len(events) == 0 {
+			continue
+		}
+
+		claimed, err := store.ClaimReminderEmail(run.Id, run.WeekStart, recipient.Id, recipient.Email)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			run.EmailsSkipped++
+			continue
+		}
+		err = SendReminderEmailToUser(startDate, events, recipient.Name, recipient.Email)
+		if err != nil {
+			log.Printf("Error sending reminders to %s: %v", recipient.Email, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", recipient.Email, err))
+			// Let the next run try them again
+			err = store.ReleaseReminderEmail(run.WeekStart, recipient.Id)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		run.EmailsSent++
+		err = store.MarkReminderEmailSent(run.WeekStart, recipient.Id)
+		if err != nil {
+			return err
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("Error sending %d email(s): %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func (s *MySqlStore) InsertReminderRun(weekStart time.Time, startedAt time.Time) (int, error) {
+	defer trace(traceName(fmt.Sprintf("InsertReminderRun(%v)", weekStart)))
+	res, err := s.db.Exec(
+		"INSERT INTO reminder_runs (week_start, started_at) VALUES (?, ?)",
+		weekStart.Format("2006-01-02"), startedAt.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	runId, err := res.LastInsertId()
+	return int(runId), err
+}
+
+func (s *MySqlStore) FinishReminderRun(run *ReminderRun) error {
+	defer trace(traceName(fmt.Sprintf("FinishReminderRun(%d)", run.Id)))
+	var finishedAt interface{}
+	if run.FinishedAt != nil {
+		finishedAt = run.FinishedAt.UTC().Format("2006-01-02 15:04:05")
+	}
+	_, err := s.db.Exec(
+		"UPDATE reminder_runs"+
+			" SET finished_at=?, emails_sent=?, emails_skipped=?, error=?"+
+			" WHERE id=?",
+		finishedAt, run.EmailsSent, run.EmailsSkipped, run.Error, run.Id)
+	return err
+}
+
+// Load the most recent runs, newest first
+func (s *MySqlStore) LoadReminderRunList(limit int) ([]ReminderRun, error) {
+	defer trace(traceName(fmt.Sprintf("LoadReminderRunList(%d)", limit)))
+	rows, err := s.db.Query(
+		"SELECT id, week_start, started_at, finished_at, emails_sent, emails_skipped, error"+
+			" FROM reminder_runs"+
+			" ORDER BY started_at DESC, id DESC"+
+			" LIMIT ?",
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []ReminderRun
+	for rows.Next() {
+		var run ReminderRun
+		var weekStartString, startedAtString string
+		var finishedAtString, errorString sql.NullString
+		err = rows.Scan(&run.Id, &weekStartString, &startedAtString, &finishedAtString, &run.EmailsSent, &run.EmailsSkipped, &errorString)
+		if err != nil {
+			return nil, err
+		}
+		run.WeekStart, _ = time.Parse("2006-01-02", weekStartString)
+		run.StartedAt, _ = time.Parse("2006-01-02 15:04:05", startedAtString)
+		if finishedAtString.Valid {
+			finishedAt, err := time.Parse("2006-01-02 15:04:05", finishedAtString.String)
+			if err == nil {
+				run.FinishedAt = &finishedAt
+			}
+		}
+		run.Error = errorString.String
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// Record that a recipient is being sent the week's email, returning false
+// when they already have been or another run is sending it.  A claim that
+// timed out without being marked sent is taken over.
+func (s *MySqlStore) ClaimReminderEmail(runId int, weekStart time.Time, recipientId int, email string) (bool, error) {
+	defer trace(traceName(fmt.Sprintf("ClaimReminderEmail(%d, %v, %d)", runId, weekStart, recipientId)))
+	now := time.Now().UTC()
+	res, err := s.db.Exec(
+		"INSERT IGNORE INTO reminder_run_emails"+
+			" (week_start, recipient_id, run_id, email, claimed_at)"+
+			" VALUES (?, ?, ?, ?, ?)",
+		weekStart.Format("2006-01-02"), recipientId, runId, email, now.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	res, err = s.db.Exec(
+		"UPDATE reminder_run_emails"+
+			" SET run_id=?, email=?, claimed_at=?"+
+			" WHERE week_start=? AND recipient_id=? AND sent_at IS NULL AND claimed_at < ?",
+		runId, email, now.Format("2006-01-02 15:04:05"),
+		weekStart.Format("2006-01-02"), recipientId, now.Add(-reminderClaimTimeout).Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return false, err
+	}
+	count, err = res.RowsAffected()
+	return count > 0, err
+}
+
+// Record that a claimed email went out
+func (s *MySqlStore) MarkReminderEmailSent(weekStart time.Time, recipientId int) error {
+	defer trace(traceName(fmt.Sprintf("MarkReminderEmailSent(%v, %d)", weekStart, recipientId)))
+	_, err := s.db.Exec(
+		"UPDATE reminder_run_emails SET sent_at=? WHERE week_start=? AND recipient_id=?",
+		time.Now().UTC().Format("2006-01-02 15:04:05"), weekStart.Format("2006-01-02"), recipientId)
+	return err
+}
+
+func (s *MySqlStore) ReleaseReminderEmail(weekStart time.Time, recipientId int) error {
+	defer trace(traceName(fmt.Sprintf("ReleaseReminderEmail(%v, %d)", weekStart, recipientId)))
+	_, err := s.db.Exec(
+		"DELETE FROM reminder_run_emails WHERE week_start=? AND recipient_id=?",
+		weekStart.Format("2006-01-02"), recipientId)
+	return err
+}
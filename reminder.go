@@ -0,0 +1,539 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"sort"
+	"time"
+)
+
+// Number of days of upcoming events a reminder covers unless the recipient says otherwise
+var defaultReminderLeadDays = 28
+
+// Longest lead time a recipient can ask for
+const maxReminderLeadDays = 366
+
+// Number of days ahead milestone birthdays and anniversaries are reminded
+// about, when that's more than the recipient's lead time, to allow planning
+var milestoneLeadDays = 91
+
+// What a recipient wants to be reminded about
+type ReminderRecipientData struct {
+	Name          string
+	Email         string
+	Birthdays     bool
+	Anniversaries bool
+	Holidays      bool
+	Memorials     bool
+	// Number of days of upcoming events in each email
+	LeadDays int
+	// Only remind about people with these tags or in these branches, or
+	// everyone when both are empty
+	TagIds          []int
+	BranchPersonIds []int
+	// Only the holidays for everyone and this country, or all of them when
+	// it's empty
+	CountryCode string
+}
+
+// Someone who gets the weekly reminder email
+type ReminderRecipient struct {
+	Id int
+	ReminderRecipientData
+	Tags     []Tag
+	Branches []PersonLite
+}
+
+func (r *ReminderRecipient) EventTypes() []string {
+	var types []string
+	if r.Birthdays {
+		types = append(types, "Birthday")
+	}
+	if r.Anniversaries {
+		types = append(types, "Anniversary")
+	}
+	if r.Holidays {
+		types = append(types, "Holiday")
+	}
+	if r.Memorials {
+		types = append(types, "Memorial")
+	}
+	return types
+}
+
+// Which events go into a list of reminders
+type ReminderFilter struct {
+	Birthdays     bool
+	Anniversaries bool
+	Holidays      bool
+	Memorials     bool
+	// When not nil, birthdays, anniversaries and memorials are only included
+	// for these people.  Holidays are for everyone.
+	PersonIds map[int]bool
+	// When not empty, only the holidays for everyone and this country
+	CountryCode string
+}
+
+func (f *ReminderFilter) includesPerson(personId int) bool {
+	return f.PersonIds == nil || f.PersonIds[personId]
+}
+
+// Work out the events a recipient wants, loading the people in their tags and branches
+func (r *ReminderRecipient) LoadReminderFilter(store Store) (*ReminderFilter, error) {
+	filter := &ReminderFilter{
+		Birthdays:     r.Birthdays,
+		Anniversaries: r.Anniversaries,
+		Holidays:      r.Holidays,
+		Memorials:     r.Memorials,
+		CountryCode:   r.CountryCode,
+	}
+	if len(r.Tags) == 0 && len(r.Branches) == 0 {
+		return filter, nil
+	}
+
+	var rootIds []int
+	for _, person := range r.Branches {
+		rootIds = append(rootIds, person.Id)
+	}
+	personIds, err := loadBranchPersonIds(store, rootIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range r.Tags {
+		people, err := store.LoadPersonLiteListWithTag(tag.Label)
+		if err != nil {
+			return nil, err
+		}
+		for _, person := range people {
+			personIds[person.Id] = true
+		}
+	}
+	filter.PersonIds = personIds
+	return filter, nil
+}
+
+// Load the people in the branches starting at each root: the roots, all of
+// their descendants and the spouses of everyone in the branch
+func loadBranchPersonIds(store Store, rootIds []int) (map[int]bool, error) {
+	personIds := make(map[int]bool)
+	var members []int
+	generation := uniqueInts(rootIds)
+	for len(generation) > 0 {
+		for _, id := range generation {
+			personIds[id] = true
+		}
+		members = append(members, generation...)
+
+		children, err := store.LoadChildrenPersonLiteByParentIds(generation)
+		if err != nil {
+			return nil, err
+		}
+		// Skip anyone already seen, so bad parent links can't loop forever
+		generation = nil
+		for _, child := range children {
+			if !personIds[child.Id] {
+				generation = append(generation, child.Id)
+			}
+		}
+		generation = uniqueInts(generation)
+	}
+
+	if len(members) > 0 {
+		spouses, err := store.LoadSpousesByPersonIds(members)
+		if err != nil {
+			return nil, err
+		}
+		for _, spouse := range spouses {
+			personIds[spouse.Person1.Id] = true
+			personIds[spouse.Person2.Id] = true
+		}
+	}
+	return personIds, nil
+}
+
+// The date a yearly event first held on date falls on in the given year.
+// Events on February 29th fall on the 28th outside leap years.
+func occurrenceInYear(date time.Time, year int, location *time.Location) time.Time {
+	month, day := date.Month(), date.Day()
+	if month == time.February && day == 29 && !isLeapYear(year) {
+		day = 28
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// The first date on or after startTime and before endTime that a yearly
+// event first held on date falls on, and false when there isn't one.  Ranges
+// can cross the end of the year, and years before the first one are skipped.
+func nextOccurrence(date time.Time, startTime time.Time, endTime time.Time) (time.Time, bool) {
+	startDay := time.Date(startTime.Year(), startTime.Month(), startTime.Day(), 0, 0, 0, 0, startTime.Location())
+	for year := startDay.Year(); year <= endTime.Year(); year++ {
+		if year < date.Year() {
+			continue
+		}
+		occurrence := occurrenceInYear(date, year, startDay.Location())
+		if occurrence.Before(startDay) {
+			continue
+		}
+		if !occurrence.Before(endTime) {
+			break
+		}
+		return occurrence, true
+	}
+	return time.Time{}, false
+}
+
+// Load the events on or after startTime and before endTime that pass the
+// filter, dated when they next happen and sorted by that date.  Milestone
+// birthdays and anniversaries are included up to milestoneLeadDays ahead.
+func LoadReminderEvents(store Store, startTime time.Time, endTime time.Time, filter *ReminderFilter) ([]CalendarEvent, error) {
+	defer trace(traceName(fmt.Sprintf("LoadReminderEvents(%v, %v)", startTime, endTime)))
+
+	var events []CalendarEvent
+
+	// Milestones are looked for further ahead
+	milestoneEnd := startTime.AddDate(0, 0, milestoneLeadDays)
+	if milestoneEnd.Before(endTime) {
+		milestoneEnd = endTime
+	}
+
+	// Add birthdays to event calendar
+	if filter.Birthdays {
+		people, err := store.LoadBirthdays()
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range people {
+			date, ok := nextOccurrence(value.BirthDate, startTime, milestoneEnd)
+			if !ok || !filter.includesPerson(value.Person.Id) {
+				continue
+			}
+			event := newBirthdayEvent(value, date, template.HTML(template.HTMLEscapeString(value.Person.Name)))
+			if event.Milestone || date.Before(endTime) {
+				events = append(events, event)
+			}
+		}
+	}
+
+	// Add anniversaries to event calendar
+	if filter.Anniversaries {
+		anniversaries, err := store.LoadAnniversaries()
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range anniversaries {
+			date, ok := nextOccurrence(value.MarriedDate, startTime, milestoneEnd)
+			if !ok || (!filter.includesPerson(value.Person1.Id) && !filter.includesPerson(value.Person2.Id)) {
+				continue
+			}
+			event := newAnniversaryEvent(value, date, template.HTML(template.HTMLEscapeString(value.Person1.Name)+" &amp; "+template.HTMLEscapeString(value.Person2.Name)))
+			if event.Milestone || date.Before(endTime) {
+				events = append(events, event)
+			}
+		}
+	}
+
+	// Add the anniversaries of deaths to event calendar
+	if filter.Memorials {
+		memorials, err := store.LoadMemorials()
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range memorials {
+			date, ok := nextOccurrence(value.DeathDate, startTime, endTime)
+			if !ok || !filter.includesPerson(value.Person.Id) {
+				continue
+			}
+			caption := fmt.Sprintf("%s (died %d)", template.HTMLEscapeString(value.Person.Name), value.DeathDate.Year())
+			events = append(events, newMemorialEvent(value, date, template.HTML(caption)))
+		}
+	}
+
+	if filter.Holidays {
+		err := ensureHolidayItems(store, startTime.Year(), endTime.Year())
+		if err != nil {
+			return nil, err
+		}
+		holidays, err := store.LoadHolidaysInRange(startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range filterHolidaysByCountry(holidays, filter.CountryCode) {
+			events = append(events, CalendarEvent{
+				Date:    value.Date,
+				Type:    "Holiday",
+				Caption: template.HTML(template.HTMLEscapeString(value.Name)),
+			})
+		}
+	}
+
+	// Events on the same day stay in the order they were added
+	sort.SliceStable(
+		events,
+		func(i, j int) bool {
+			return dateBefore(events[i].Date, events[j].Date)
+		})
+	return events, nil
+}
+
+// Compare the calendar dates, ignoring the time and time zone, as holidays
+// are read in UTC and the other events in the range's time zone
+func dateBefore(a time.Time, b time.Time) bool {
+	return a.Format("2006-01-02") < b.Format("2006-01-02")
+}
+
+// Load the events a recipient wants for the week starting at startTime
+func LoadRecipientReminderEvents(store Store, recipient *ReminderRecipient, startTime time.Time) ([]CalendarEvent, error) {
+	filter, err := recipient.LoadReminderFilter(store)
+	if err != nil {
+		return nil, err
+	}
+	endTime := startTime.AddDate(0, 0, recipient.LeadDays)
+	return LoadReminderEvents(store, startTime, endTime, filter)
+}
+
+func (s *MySqlStore) LoadReminderRecipientList() ([]ReminderRecipient, error) {
+	defer trace(traceName("LoadReminderRecipientList"))
+	rows, err := s.db.Query(
+		"SELECT id, name, email, birthdays, anniversaries, holidays, memorials, lead_days, country_code" +
+			" FROM reminder_recipients" +
+			" ORDER BY name, email")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []ReminderRecipient
+	for rows.Next() {
+		recipient, err := readReminderRecipientFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, *recipient)
+	}
+	err = s.loadReminderRecipientFilters(recipients)
+	if err != nil {
+		return nil, err
+	}
+	return recipients, nil
+}
+
+func (s *MySqlStore) LoadReminderRecipientById(id int) (*ReminderRecipient, error) {
+	defer trace(traceName(fmt.Sprintf("LoadReminderRecipientById(%d)", id)))
+	rows, err := s.db.Query(
+		"SELECT id, name, email, birthdays, anniversaries, holidays, memorials, lead_days, country_code"+
+			" FROM reminder_recipients"+
+			" WHERE id=?",
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, notFoundf("Reminder recipient not found with id: %d", id)
+	}
+	recipient, err := readReminderRecipientFromRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	recipients := []ReminderRecipient{*recipient}
+	err = s.loadReminderRecipientFilters(recipients)
+	if err != nil {
+		return nil, err
+	}
+	return &recipients[0], nil
+}
+
+func readReminderRecipientFromRows(rows *sql.Rows) (*ReminderRecipient, error) {
+	var item ReminderRecipient
+	var countryCode sql.NullString
+	err := rows.Scan(&item.Id, &item.Name, &item.Email, &item.Birthdays, &item.Anniversaries, &item.Holidays, &item.Memorials, &item.LeadDays, &countryCode)
+	if err != nil {
+		return nil, err
+	}
+	item.CountryCode = countryCode.String
+	return &item, nil
+}
+
+// Fill in the tags and branches of each recipient
+func (s *MySqlStore) loadReminderRecipientFilters(recipients []ReminderRecipient) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	lookup := make(map[int]*ReminderRecipient)
+	var recipientIds []int
+	for i := range recipients {
+		lookup[recipients[i].Id] = &recipients[i]
+		recipientIds = append(recipientIds, recipients[i].Id)
+	}
+
+	rows, err := s.db.Query(
+		"SELECT rt.recipient_id, t.id, t.label"+
+			" FROM reminder_recipient_tags rt"+
+			"   INNER JOIN tags t ON t.id = rt.tag_id"+
+			" WHERE rt.recipient_id IN ("+sqlPlaceholders(len(recipientIds))+")"+
+			" ORDER BY t.label",
+		intArgs(recipientIds)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var recipientId int
+		var tag Tag
+		err = rows.Scan(&recipientId, &tag.Id, &tag.Label)
+		if err != nil {
+			return err
+		}
+		recipient := lookup[recipientId]
+		recipient.Tags = append(recipient.Tags, tag)
+		recipient.TagIds = append(recipient.TagIds, tag.Id)
+	}
+
+	branchRows, err := s.db.Query(
+		"SELECT recipient_id, person_id"+
+			" FROM reminder_recipient_branches"+
+			" WHERE recipient_id IN ("+sqlPlaceholders(len(recipientIds))+")",
+		intArgs(recipientIds)...)
+	if err != nil {
+		return err
+	}
+	defer branchRows.Close()
+	var personIds []int
+	for branchRows.Next() {
+		var recipientId, personId int
+		err = branchRows.Scan(&recipientId, &personId)
+		if err != nil {
+			return err
+		}
+		recipient := lookup[recipientId]
+		recipient.BranchPersonIds = append(recipient.BranchPersonIds, personId)
+		personIds = append(personIds, personId)
+	}
+	if len(personIds) == 0 {
+		return nil
+	}
+
+	people, err := s.LoadPersonLiteListByIds(uniqueInts(personIds))
+	if err != nil {
+		return err
+	}
+	personLookup := make(map[int]PersonLite)
+	for _, person := range people {
+		personLookup[person.Id] = person
+	}
+	for i := range recipients {
+		for _, personId := range recipients[i].BranchPersonIds {
+			if person, found := personLookup[personId]; found {
+				recipients[i].Branches = append(recipients[i].Branches, person)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MySqlStore) InsertReminderRecipient(data ReminderRecipientData) (int, error) {
+	defer trace(traceName(fmt.Sprintf("InsertReminderRecipient(%s)", data.Email)))
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(
+		"INSERT INTO reminder_recipients"+
+			" (name, email, birthdays, anniversaries, holidays, memorials, lead_days, country_code)"+
+			" VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		data.Name, data.Email, data.Birthdays, data.Anniversaries, data.Holidays, data.Memorials, data.LeadDays,
+		getNullableString(data.CountryCode))
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	recipientId, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = insertReminderRecipientFilters(tx, int(recipientId), data)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return int(recipientId), tx.Commit()
+}
+
+func (s *MySqlStore) UpdateReminderRecipient(id int, data ReminderRecipientData) error {
+	defer trace(traceName(fmt.Sprintf("UpdateReminderRecipient(%d, %s)", id, data.Email)))
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"UPDATE reminder_recipients"+
+			" SET name=?, email=?, birthdays=?, anniversaries=?, holidays=?, memorials=?, lead_days=?, country_code=?"+
+			" WHERE id=?",
+		data.Name, data.Email, data.Birthdays, data.Anniversaries, data.Holidays, data.Memorials, data.LeadDays,
+		getNullableString(data.CountryCode), id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = deleteReminderRecipientFilters(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = insertReminderRecipientFilters(tx, id, data)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *MySqlStore) DeleteReminderRecipient(id int) error {
+	defer trace(traceName(fmt.Sprintf("DeleteReminderRecipient(%d)", id)))
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = deleteReminderRecipientFilters(tx, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM reminder_recipients WHERE id=?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertReminderRecipientFilters(tx *sql.Tx, recipientId int, data ReminderRecipientData) error {
+	for _, tagId := range uniqueInts(data.TagIds) {
+		_, err := tx.Exec("INSERT INTO reminder_recipient_tags (recipient_id, tag_id) VALUES (?, ?)", recipientId, tagId)
+		if err != nil {
+			return err
+		}
+	}
+	for _, personId := range uniqueInts(data.BranchPersonIds) {
+		_, err := tx.Exec("INSERT INTO reminder_recipient_branches (recipient_id, person_id) VALUES (?, ?)", recipientId, personId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteReminderRecipientFilters(tx *sql.Tx, recipientId int) error {
+	_, err := tx.Exec("DELETE FROM reminder_recipient_tags WHERE recipient_id=?", recipientId)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM reminder_recipient_branches WHERE recipient_id=?", recipientId)
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReminderCaptionsAreEscaped(t *testing.T) {
+	store := NewMemoryStore()
+	person := insertTestPerson(t, store, PersonData{FirstName: "<i>Pat</i>", LastName: "Tester", Gender: "U", IsAlive: true, BirthDate: "1990-03-05"})
+	spouse := insertTestPerson(t, store, PersonData{FirstName: "Sam", LastName: "<b>Tester</b>", Gender: "F"})
+	err := store.InsertSpouse(person, spouse, 1, "2015-03-06")
+	if err != nil {
+		t.Fatal(err)
+	}
+	holiday, err := store.InsertHoliday(HolidayData{Name: "<script>alert(1)</script>", Rule: HolidayRuleDates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.InsertHolidayItem(holiday, time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	filter := &ReminderFilter{Birthdays: true, Anniversaries: true, Holidays: true}
+	events, err := LoadReminderEvents(store, start, start.AddDate(0, 0, 7), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Got %d events, expected a birthday, an anniversary and a holiday", len(events))
+	}
+	for _, event := range events {
+		if strings.Contains(string(event.Caption), "<") {
+			t.Errorf("%s caption isn't escaped: %s", event.Type, event.Caption)
+		}
+	}
+}
+
+func TestRemindersStartOnAMonday(t *testing.T) {
+	store := NewMemoryStore()
+	tuesday := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	run, err := RunReminders(store, tuesday)
+	if err == nil || run != nil {
+		t.Fatalf("Running reminders from a Tuesday gave %v, %v", run, err)
+	}
+	runs, err := store.LoadReminderRunList(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("Running reminders from a Tuesday recorded %v", runs)
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	day := func(value string) time.Time {
+		result, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	cases := []struct {
+		date  string
+		start string
+		end   string
+		next  string
+	}{
+		{"1990-03-05", "2024-03-04", "2024-03-11", "2024-03-05"},
+		{"1990-03-04", "2024-03-04", "2024-03-11", "2024-03-04"},
+		{"1990-03-11", "2024-03-04", "2024-03-11", ""},
+		{"1990-03-01", "2024-03-04", "2024-03-11", ""},
+		// Ranges across the end of the year
+		{"1990-12-30", "2024-12-30", "2025-01-06", "2024-12-30"},
+		{"1990-01-02", "2024-12-30", "2025-01-06", "2025-01-02"},
+		{"1990-12-29", "2024-12-30", "2025-01-06", ""},
+		// Not before the first one
+		{"2025-01-02", "2024-12-30", "2025-01-06", "2025-01-02"},
+		{"2026-01-02", "2024-12-30", "2025-01-06", ""},
+		{"2024-03-06", "2024-03-04", "2024-03-11", "2024-03-06"},
+		// February 29th falls on the 28th outside leap years
+		{"2000-02-29", "2024-02-26", "2024-03-04", "2024-02-29"},
+		{"2000-02-29", "2023-02-27", "2023-03-06", "2023-02-28"},
+		{"2000-02-29", "2023-02-20", "2023-02-27", ""},
+		{"2000-02-29", "2100-02-22", "2100-03-01", "2100-02-28"},
+		{"2000-02-28", "2024-02-26", "2024-03-04", "2024-02-28"},
+		// Long ranges give the first one
+		{"1990-06-15", "2024-07-01", "2025-07-01", "2025-06-15"},
+	}
+	for _, c := range cases {
+		next, ok := nextOccurrence(day(c.date), day(c.start), day(c.end))
+		if c.next == "" {
+			if ok {
+				t.Errorf("nextOccurrence(%s, %s, %s) = %s, want none", c.date, c.start, c.end, next.Format("2006-01-02"))
+			}
+			continue
+		}
+		if !ok || !next.Equal(day(c.next)) {
+			t.Errorf("nextOccurrence(%s, %s, %s) = %s, %v, want %s", c.date, c.start, c.end, next.Format("2006-01-02"), ok, c.next)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// When a job runs, parsed from a standard 5 field cron expression:
+// minute, hour, day of month, month and day of week (0 or 7 is Sunday).
+// Fields can be *, a number, a range (1-5), a list (1,15) and have a step (*/15).
+type CronSchedule struct {
+	expression string
+	minutes    map[int]bool
+	hours      map[int]bool
+	days       map[int]bool
+	months     map[int]bool
+	weekdays   map[int]bool
+	// Whether the day of month and day of week fields start with *.  When
+	// neither does, a day matching either runs the job, like cron does, so
+	// "*/2" still has to match along with the other field.
+	anyDay     bool
+	anyWeekday bool
+}
+
+func ParseCronSchedule(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Cron schedule %q must have 5 fields: minute hour day month weekday", expression)
+	}
+	schedule := &CronSchedule{
+		expression: expression,
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	for i, field := range []struct {
+		values   *map[int]bool
+		min, max int
+	}{
+		{&schedule.minutes, 0, 59},
+		{&schedule.hours, 0, 23},
+		{&schedule.days, 1, 31},
+		{&schedule.months, 1, 12},
+		{&schedule.weekdays, 0, 7},
+	} {
+		*field.values, err = parseCronField(fields[i], field.min, field.max)
+		if err != nil {
+			return nil, fmt.Errorf("Cron schedule %q: %v", expression, err)
+		}
+	}
+	if schedule.weekdays[7] {
+		schedule.weekdays[0] = true
+	}
+	return schedule, nil
+}
+
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:index]
+		}
+
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid range %q", part)
+				}
+			} else if step > 1 {
+				// "5/15" means every 15 starting at 5
+				end = max
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+func (c *CronSchedule) String() string {
+	return c.expression
+}
+
+func (c *CronSchedule) matchesDay(t time.Time) bool {
+	dayMatches := c.days[t.Day()]
+	weekdayMatches := c.weekdays[int(t.Weekday())]
+	if !c.anyDay && !c.anyWeekday {
+		return dayMatches || weekdayMatches
+	}
+	return dayMatches && weekdayMatches
+}
+
+// The first time after the given one that the job should run, or the zero
+// time when the schedule can never match (eg. February 30th)
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Every day for the next 5 years covers leap days
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.months[int(t.Month())] || !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Run the job each time the schedule comes round, until the context is done
+func RunScheduler(ctx context.Context, name string, schedule *CronSchedule, job func()) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Scheduler %s: %s never runs", name, schedule)
+			return
+		}
+		log.Printf("Scheduler %s: next run at %s", name, next.Format(time.RFC1123))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			job()
+		}
+	}
+}
+
+// The job run by the -reminderSchedule, sending next week's reminders
+func runScheduledReminders() {
+	run, err := RunReminders(store, getMonday(time.Now()))
+	if err != nil {
+		log.Printf("Error running reminders: %v", err)
+	}
+	if run != nil {
+		log.Printf("Reminders for %s: sent %d, skipped %d already sent", run.WeekStart.Format("2006-01-02"), run.EmailsSent, run.EmailsSkipped)
+	}
+}
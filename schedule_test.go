@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	at := func(value string) time.Time {
+		result, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	cases := []struct {
+		expression string
+		after      string
+		next       string
+	}{
+		{"0 8 * * 1", "2024-03-02 10:00", "2024-03-04 08:00"},
+		{"0 8 * * 1", "2024-03-04 08:00", "2024-03-11 08:00"},
+		{"*/15 * * * *", "2024-03-04 10:07", "2024-03-04 10:15"},
+		{"5/20 * * * *", "2024-03-04 10:30", "2024-03-04 10:45"},
+		{"30 8 * * 7", "2024-03-04 00:00", "2024-03-10 08:30"},
+		{"0 12 * 6-8 1-5", "2024-05-31 13:00", "2024-06-03 12:00"},
+		{"0 0 1,15 * *", "2024-03-02 00:00", "2024-03-15 00:00"},
+		{"0 0 1 1 *", "2024-06-01 00:00", "2025-01-01 00:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		// With both day fields restricted either one matching is enough
+		{"0 9 1 * 1", "2024-04-02 10:00", "2024-04-08 09:00"},
+		{"0 9 1 * 1", "2024-04-29 10:00", "2024-05-01 09:00"},
+		// A field starting with * counts as unrestricted, so both have to match
+		{"0 9 */2 * 1", "2024-03-01 00:00", "2024-03-11 09:00"},
+		{"0 9 1 * */7", "2024-03-01 10:00", "2024-09-01 09:00"},
+		// Never matches
+		{"0 0 30 2 *", "2024-01-01 00:00", ""},
+	}
+	for _, c := range cases {
+		schedule, err := ParseCronSchedule(c.expression)
+		if err != nil {
+			t.Errorf("ParseCronSchedule(%q): %v", c.expression, err)
+			continue
+		}
+		next := schedule.Next(at(c.after))
+		var want time.Time
+		if c.next != "" {
+			want = at(c.next)
+		}
+		if !next.Equal(want) {
+			t.Errorf("%q after %s is %s, want %s", c.expression, c.after, next, want)
+		}
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"0 8 * *",
+		"0 8 * * 1 2024",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+	} {
+		_, err := ParseCronSchedule(expression)
+		if err == nil {
+			t.Errorf("ParseCronSchedule(%q) should fail", expression)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// People shown on each page of search results
+const personSearchPageSize = 10
+
+// Every name a person can be found by
+type PersonSearchNames struct {
+	PersonLite
+	FirstName      string
+	MiddleName     string
+	LastName       string
+	NickName       string
+	AlternateNames []AlternateName
+}
+
+// A person found by a search
+type PersonSearchHit struct {
+	PersonLite
+	// Higher for better matches
+	Score float64
+	// The alternate name that matched, when they were found by one
+	MatchedName string
+}
+
+// One page of the people matching a search, best matches first
+type PersonSearchResults struct {
+	Query  string
+	Total  int
+	Offset int
+	People []PersonSearchHit
+}
+
+// Letters that don't come apart into a plain letter and an accent
+var searchFoldings = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d", "ð", "d", "ł", "l", "ı", "i", "þ", "th",
+	// O'Brien and OBrien are the same name
+	"'", "", "’", "",
+)
+
+// Lower case without accents, so "Zoë" and "zoe" are the same
+func normalizeSearchText(text string) string {
+	text = searchFoldings.Replace(strings.ToLower(text))
+	var folded strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		if !unicode.Is(unicode.Mn, r) {
+			folded.WriteRune(r)
+		}
+	}
+	return folded.String()
+}
+
+// The words of a name or query, normalized
+func searchWords(text string) []string {
+	return strings.FieldsFunc(normalizeSearchText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Typos forgiven in a word of the given length.  Short words have to be
+// right, or everything would match them.
+func allowedSearchTypos(length int) int {
+	switch {
+	case length < 4:
+		return 0
+	case length < 7:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Letters inserted, deleted, changed or swapped with their neighbour to turn a
+// into b
+func editDistance(a []rune, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			best := min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				best = min(best, rows[i-2][j-2]+1)
+			}
+			rows[i][j] = best
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+// How well a word of a query matches a word of a name, from 1 for exactly to
+// 0 for not at all.  Prefixes match so results come up while typing, and
+// small typos match with a lower score.
+func matchSearchWord(query string, word string) float64 {
+	if query == word {
+		return 1
+	}
+	if strings.HasPrefix(word, query) {
+		return 0.8
+	}
+	q, w := []rune(query), []rune(word)
+	allowed := allowedSearchTypos(len(q))
+	if allowed == 0 {
+		return 0
+	}
+	if distance := editDistance(q, w); distance <= allowed {
+		return 0.7 - 0.1*float64(distance)
+	}
+	// A typo in a word that's still being typed
+	if len(w) > len(q) {
+		if distance := editDistance(q, w[:len(q)]); distance <= allowed {
+			return 0.5 - 0.1*float64(distance)
+		}
+	}
+	return 0
+}
+
+// Names a person can be found by, with how much a match on each counts
+type searchField struct {
+	words  []string
+	weight float64
+	// Set for alternate names, to show what was matched
+	alternate string
+}
+
+func personSearchFields(person *PersonSearchNames) []searchField {
+	fields := []searchField{
+		{words: searchWords(person.FirstName), weight: 1},
+		{words: searchWords(person.NickName), weight: 1},
+		{words: searchWords(person.LastName), weight: 1},
+		{words: searchWords(person.MiddleName), weight: 0.7},
+	}
+	for _, name := range person.AlternateNames {
+		fields = append(fields, searchField{words: searchWords(name.Name), weight: 0.9, alternate: name.Name})
+	}
+	return fields
+}
+
+// Score a person against the words of a query.  Every word has to match one
+// of their names for them to be found.
+func scorePersonSearch(person *PersonSearchNames, query []string) (PersonSearchHit, bool) {
+	hit := PersonSearchHit{PersonLite: person.PersonLite}
+	fields := personSearchFields(person)
+	for _, queryWord := range query {
+		best := 0.0
+		matchedName := ""
+		for _, field := range fields {
+			for _, word := range field.words {
+				score := matchSearchWord(queryWord, word) * field.weight
+				if score > best {
+					best = score
+					matchedName = field.alternate
+				}
+			}
+		}
+		if best == 0 {
+			return hit, false
+		}
+		hit.Score += best
+		if hit.MatchedName == "" {
+			hit.MatchedName = matchedName
+		}
+	}
+	return hit, true
+}
+
+// Find people by any of their names, best matches first.  Everyone's names
+// are read for each search: a family's worth is small enough that this is
+// quicker than keeping an index in step with every edit.  An empty query
+// lists everyone by name.
+func SearchPeople(store Store, query string, offset int, limit int) (*PersonSearchResults, error) {
+	people, err := store.LoadPersonSearchNames()
+	if err != nil {
+		return nil, err
+	}
+	queryWords := searchWords(query)
+
+	type match struct {
+		hit    PersonSearchHit
+		person *PersonSearchNames
+	}
+	var matches []match
+	for i := range people {
+		hit, found := scorePersonSearch(&people[i], queryWords)
+		if found {
+			matches = append(matches, match{hit, &people[i]})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.hit.Score != b.hit.Score {
+			return a.hit.Score > b.hit.Score
+		}
+		for _, names := range [][2]string{
+			{a.person.LastName, b.person.LastName},
+			{a.person.FirstName, b.person.FirstName},
+			{a.person.MiddleName, b.person.MiddleName},
+		} {
+			if x, y := normalizeSearchText(names[0]), normalizeSearchText(names[1]); x != y {
+				return x < y
+			}
+		}
+		return a.hit.Id < b.hit.Id
+	})
+
+	results := &PersonSearchResults{Query: query, Total: len(matches), Offset: offset}
+	for _, match := range pageOf(matches, offset, limit) {
+		results.People = append(results.People, match.hit)
+	}
+	return results, nil
+}
+
+func (s *MySqlStore) LoadPersonSearchNames() ([]PersonSearchNames, error) {
+	defer trace(traceName("LoadPersonSearchNames()"))
+	rows, err := s.db.Query(
+		"SELECT id, first_name, middle_name, last_name, nick_name, gender, mother_id, father_id" +
+			" FROM people")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []PersonSearchNames
+	byId := make(map[int]int)
+	for rows.Next() {
+		var item PersonSearchNames
+		var motherId, fatherId sql.NullInt64
+		var gender string
+		err = rows.Scan(&item.Id, &item.FirstName, &item.MiddleName, &item.LastName, &item.NickName, &gender, &motherId, &fatherId)
+		if err != nil {
+			return nil, err
+		}
+		item.Name = BuildFullName(item.FirstName, item.MiddleName, item.LastName, item.NickName)
+		item.Gender = GetGenderName(gender)
+		item.MotherId = int(motherId.Int64)
+		item.FatherId = int(fatherId.Int64)
+		byId[item.Id] = len(list)
+		list = append(list, item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	nameRows, err := s.db.Query("SELECT id, person_id, kind, name FROM alternate_names ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer nameRows.Close()
+	for nameRows.Next() {
+		var name AlternateName
+		err = nameRows.Scan(&name.Id, &name.PersonId, &name.Kind, &name.Name)
+		if err != nil {
+			return nil, err
+		}
+		if i, found := byId[name.PersonId]; found {
+			list[i].AlternateNames = append(list[i].AlternateNames, name)
+		}
+	}
+	return list, nameRows.Err()
+}
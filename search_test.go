@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSearchWords(t *testing.T) {
+	cases := []struct {
+		text  string
+		words string
+	}{
+		{"Anne Smith", "anne smith"},
+		{"Zoë O'Brien-Smith", "zoe obrien smith"},
+		{"O’Brien", "obrien"},
+		{"Straße", "strasse"},
+		{"Łukasz Ørsted", "lukasz orsted"},
+		{"José  MARÍA", "jose maria"},
+		{"  ", ""},
+	}
+	for _, c := range cases {
+		if words := strings.Join(searchWords(c.text), " "); words != c.words {
+			t.Errorf("searchWords(%q) = %q, want %q", c.text, words, c.words)
+		}
+	}
+}
+
+func TestMatchSearchWord(t *testing.T) {
+	cases := []struct {
+		query string
+		word  string
+		score float64
+	}{
+		{"anne", "anne", 1},
+		{"ann", "anne", 0.8},
+		{"a", "anne", 0.8},
+		// Words under four letters have to be right
+		{"jon", "john", 0},
+		{"bob", "rob", 0},
+		{"jhon", "john", 0.6},
+		{"smiht", "smith", 0.6},
+		{"smyth", "smith", 0.6},
+		{"elizbeth", "elizabeth", 0.6},
+		{"catherine", "katharine", 0.5},
+		{"catherine", "kathryn", 0},
+		// A typo in a word that's still being typed
+		{"elizb", "elizabeth", 0.4},
+		{"xyzw", "smith", 0},
+	}
+	for _, c := range cases {
+		if score := matchSearchWord(c.query, c.word); math.Abs(score-c.score) > 1e-9 {
+			t.Errorf("matchSearchWord(%q, %q) = %v, want %v", c.query, c.word, score, c.score)
+		}
+	}
+}
+
+func TestSearchPeople(t *testing.T) {
+	store := NewMemoryStore()
+	ids := make(map[int]string)
+	person := func(firstName string, lastName string, nickName string) int {
+		id := insertTestPerson(t, store, PersonData{FirstName: firstName, LastName: lastName, NickName: nickName, Gender: "U"})
+		ids[id] = firstName + " " + lastName
+		return id
+	}
+	person("Anne", "Smith", "")
+	person("John", "Smyth", "")
+	person("Catherine", "Jones", "Kate")
+	person("Ann", "Jones", "")
+	mary := person("Mary", "O'Brien", "")
+	_, err := store.InsertAlternateName(mary, AlternateNameMaiden, "Mary Walsh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		query       string
+		offset      int
+		limit       int
+		people      string
+		total       int
+		matchedName string
+	}{
+		{"smith", 0, 10, "Anne Smith, John Smyth", 2, ""},
+		{"ann", 0, 10, "Ann Jones, Anne Smith", 2, ""},
+		{"jones", 0, 10, "Ann Jones, Catherine Jones", 2, ""},
+		{"jon", 0, 10, "Ann Jones, Catherine Jones", 2, ""},
+		{"kate jones", 0, 10, "Catherine Jones", 1, ""},
+		{"KATHERINE", 0, 10, "Catherine Jones", 1, ""},
+		{"obrien", 0, 10, "Mary O'Brien", 1, ""},
+		{"Mary O’Brien", 0, 10, "Mary O'Brien", 1, ""},
+		{"walsh", 0, 10, "Mary O'Brien", 1, "Mary Walsh"},
+		// Every word has to match
+		{"john jones", 0, 10, "", 0, ""},
+		{"zzz", 0, 10, "", 0, ""},
+		// No query lists everyone by name, a page at a time
+		{"", 0, 10, "Ann Jones, Catherine Jones, Mary O'Brien, Anne Smith, John Smyth", 5, ""},
+		{"", 2, 2, "Mary O'Brien, Anne Smith", 5, ""},
+	}
+	for _, c := range cases {
+		results, err := SearchPeople(store, c.query, c.offset, c.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, hit := range results.People {
+			names = append(names, ids[hit.Id])
+		}
+		if strings.Join(names, ", ") != c.people || results.Total != c.total {
+			t.Errorf("Searching %q from %d found %d: %v, want %d: %s", c.query, c.offset, results.Total, names, c.total, c.people)
+		}
+		if len(results.People) > 0 && results.People[0].MatchedName != c.matchedName {
+			t.Errorf("Searching %q matched %q, want %q", c.query, results.People[0].MatchedName, c.matchedName)
+		}
+	}
+}
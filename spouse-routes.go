@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"html/template"
 	"net/http"
 	"strconv"
 )
@@ -11,7 +10,7 @@ func spouseDelete(w http.ResponseWriter, r *http.Request) {
 	person1Id, err := strconv.Atoi(r.FormValue("person1_id"))
 	person2Id, err := strconv.Atoi(r.FormValue("person2_id"))
 
-	err = DeleteSpouse(db, person1Id, person2Id)
+	err = store.DeleteSpouse(person1Id, person2Id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error deleting spouse: %v", err), 500)
 		return
@@ -27,7 +26,7 @@ func spouseAdd(w http.ResponseWriter, r *http.Request) {
 		status, err := strconv.Atoi(r.FormValue("status"))
 		marriedDate := r.FormValue("married_date")
 
-		err = InsertSpouse(db, person1Id, person2Id, status, marriedDate)
+		err = store.InsertSpouse(person1Id, person2Id, status, marriedDate)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error adding spouse: %v", err), 500)
 			return
@@ -36,19 +35,16 @@ func spouseAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	person1, err := LoadPersonLiteById(db, person1Id)
+	person1, err := store.LoadPersonLiteById(person1Id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading person: %v", err), 500)
 		return
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/spouse/add.html")).Execute(w, person1)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "spouse/add.html", person1)
 }
 
 func addSpouseRoutes() {
-	http.HandleFunc("/spouse/add", spouseAdd)
-	http.HandleFunc("/spouse/delete", spouseDelete)
+	http.HandleFunc("/spouse/add", requireRole(RoleEditor, spouseAdd))
+	http.HandleFunc("/spouse/delete", requireRole(RoleAdmin, requirePost(spouseDelete)))
 }
@@ -26,52 +26,112 @@ func (s *SpouseLite) StatusFormatted() string {
 	}
 }
 
-func LoadSpousesByPersonId(db *sql.DB, personId int) ([]SpouseLite, error) {
-	trace(traceName(fmt.Sprintf("LoadSpousesByPersonId(%d)", personId)))
-	rows, err := db.Query(
-		"SELECT person1_id, person2_id, status, married_date"+
-			" FROM spouses"+
-			" WHERE person1_id=? OR person2_id=? ORDER BY status", personId, personId)
+// Load the relationships of a person, with the person as Person1
+func (s *MySqlStore) LoadSpousesByPersonId(personId int) ([]SpouseLite, error) {
+	spouses, err := s.LoadSpousesByPersonIds([]int{personId})
 	if err != nil {
 		return nil, err
 	}
-	person1, err := LoadPersonLiteById(db, personId)
+	return spousesOf(personId, spouses), nil
+}
+
+func (s *MySqlStore) LoadSpouseList() ([]SpouseLite, error) {
+	defer trace(traceName("LoadSpouseList"))
+	rows, err := s.db.Query(
+		"SELECT s.status, s.married_date," +
+			" p1.id, p1.first_name, p1.middle_name, p1.last_name, p1.nick_name, p1.gender, p1.mother_id, p1.father_id," +
+			" p2.id, p2.first_name, p2.middle_name, p2.last_name, p2.nick_name, p2.gender, p2.mother_id, p2.father_id" +
+			" FROM spouses s" +
+			"  INNER JOIN people p1 ON p1.id = s.person1_id" +
+			"  INNER JOIN people p2 ON p2.id = s.person2_id" +
+			" ORDER BY s.person1_id, s.person2_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return readSpouseLiteListFromRows(rows)
+}
+
+// Load the relationships of any of the given people in a single query.  Each
+// is returned once, with the people in the order they are stored.
+func (s *MySqlStore) LoadSpousesByPersonIds(personIds []int) ([]SpouseLite, error) {
+	defer trace(traceName(fmt.Sprintf("LoadSpousesByPersonIds(%v)", personIds)))
+	if len(personIds) == 0 {
+		return nil, nil
+	}
+	placeholders := sqlPlaceholders(len(personIds))
+	rows, err := s.db.Query(
+		"SELECT s.status, s.married_date,"+
+			" p1.id, p1.first_name, p1.middle_name, p1.last_name, p1.nick_name, p1.gender, p1.mother_id, p1.father_id,"+
+			" p2.id, p2.first_name, p2.middle_name, p2.last_name, p2.nick_name, p2.gender, p2.mother_id, p2.father_id"+
+			" FROM spouses s"+
+			"  INNER JOIN people p1 ON p1.id = s.person1_id"+
+			"  INNER JOIN people p2 ON p2.id = s.person2_id"+
+			" WHERE s.person1_id IN ("+placeholders+") OR s.person2_id IN ("+placeholders+")"+
+			" ORDER BY s.status, s.person1_id, s.person2_id",
+		append(intArgs(personIds), intArgs(personIds)...)...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	return readSpouseLiteListFromRows(rows)
+}
+
+func readSpouseLiteListFromRows(rows *sql.Rows) ([]SpouseLite, error) {
 	var spouseList []SpouseLite
 	for rows.Next() {
-		item := SpouseLite{Person1: *person1}
-		var person1Id, person2Id int
+		var item SpouseLite
 		var marriedDate sql.NullString
-		err = rows.Scan(&person1Id, &person2Id, &item.Status, &marriedDate)
+		var person1MotherId, person1FatherId, person2MotherId, person2FatherId sql.NullInt64
+		var person1FirstName, person1MiddleName, person1LastName, person1NickName, person1Gender string
+		var person2FirstName, person2MiddleName, person2LastName, person2NickName, person2Gender string
+		err := rows.Scan(&item.Status, &marriedDate,
+			&item.Person1.Id, &person1FirstName, &person1MiddleName, &person1LastName, &person1NickName, &person1Gender, &person1MotherId, &person1FatherId,
+			&item.Person2.Id, &person2FirstName, &person2MiddleName, &person2LastName, &person2NickName, &person2Gender, &person2MotherId, &person2FatherId)
 		if err != nil {
 			return nil, err
 		}
 		if marriedDate.Valid {
 			item.MarriedDate, _ = time.Parse("2006-01-02", marriedDate.String)
 		}
-		if person2Id == personId {
-			person2Id = person1Id
-		}
-		person2, err := LoadPersonLiteById(db, person2Id)
-		if err != nil {
-			return nil, err
-		}
-		item.Person2 = *person2
+		item.Person1.Name = BuildFullName(person1FirstName, person1MiddleName, person1LastName, person1NickName)
+		item.Person1.Gender = GetGenderName(person1Gender)
+		item.Person1.MotherId = int(person1MotherId.Int64)
+		item.Person1.FatherId = int(person1FatherId.Int64)
+		item.Person2.Name = BuildFullName(person2FirstName, person2MiddleName, person2LastName, person2NickName)
+		item.Person2.Gender = GetGenderName(person2Gender)
+		item.Person2.MotherId = int(person2MotherId.Int64)
+		item.Person2.FatherId = int(person2FatherId.Int64)
 		spouseList = append(spouseList, item)
 	}
 	return spouseList, nil
 }
 
-func DeleteSpouse(db *sql.DB, person1Id int, person2Id int) error {
+func (s *MySqlStore) SpouseExists(person1Id int, person2Id int) (bool, error) {
+	if person2Id < person1Id {
+		person1Id, person2Id = person2Id, person1Id
+	}
+	defer trace(traceName(fmt.Sprintf("SpouseExists(%d, %d)", person1Id, person2Id)))
+	var count int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM spouses WHERE person1_id=? AND person2_id=?",
+		person1Id, person2Id).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *MySqlStore) DeleteSpouse(person1Id int, person2Id int) error {
 	if person2Id < person1Id {
 		tmp := person1Id
 		person1Id = person2Id
 		person2Id = tmp
 	}
 	trace(traceName(fmt.Sprintf("DeleteSpouse(%d, %d)", person1Id, person2Id)))
-	res, err := db.Exec("DELETE FROM spouses WHERE person1_id=? AND person2_id=?", person1Id, person2Id)
+	res, err := s.db.Exec("DELETE FROM spouses WHERE person1_id=? AND person2_id=?", person1Id, person2Id)
 	if err != nil {
 		return err
 	}
@@ -80,12 +140,12 @@ func DeleteSpouse(db *sql.DB, person1Id int, person2Id int) error {
 		return err
 	}
 	if numAffected < 1 {
-		return fmt.Errorf("Spouse not found with person1_id: %d, person2_id: %d", person1Id, person2Id)
+		return notFoundf("Spouse not found with person1_id: %d, person2_id: %d", person1Id, person2Id)
 	}
 	return nil
 }
 
-func InsertSpouse(db *sql.DB, person1Id int, person2Id int, status int, marriedDate string) error {
+func (s *MySqlStore) InsertSpouse(person1Id int, person2Id int, status int, marriedDate string) error {
 	if person2Id < person1Id {
 		tmp := person1Id
 		person1Id = person2Id
@@ -96,7 +156,7 @@ func InsertSpouse(db *sql.DB, person1Id int, person2Id int, status int, marriedD
 		String: marriedDate,
 		Valid:  marriedDate != "",
 	}
-	_, err := db.Exec(
+	_, err := s.db.Exec(
 		"INSERT INTO spouses"+
 			" (person1_id, person2_id, status, married_date)"+
 			" VALUES(?, ?, ?, ?)",
@@ -106,3 +166,31 @@ func InsertSpouse(db *sql.DB, person1Id int, person2Id int, status int, marriedD
 	}
 	return nil
 }
+
+func (s *MySqlStore) UpdateSpouse(person1Id int, person2Id int, status int, marriedDate string) error {
+	person1Id, person2Id = orderedPair(person1Id, person2Id)
+	defer trace(traceName(fmt.Sprintf("UpdateSpouse(%d, %d, %d, %s)", person1Id, person2Id, status, marriedDate)))
+	res, err := s.db.Exec(
+		"UPDATE spouses"+
+			" SET status=?, married_date=?"+
+			" WHERE person1_id=? AND person2_id=?",
+		status, getNullableString(marriedDate), person1Id, person2Id)
+	if err != nil {
+		return err
+	}
+	numAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if numAffected < 1 {
+		exists, err := s.SpouseExists(person1Id, person2Id)
+		if err != nil {
+			return err
+		}
+		// MySQL counts only changed rows, so an update to the same values affects none
+		if !exists {
+			return notFoundf("Spouse not found with person1_id: %d, person2_id: %d", person1Id, person2Id)
+		}
+	}
+	return nil
+}
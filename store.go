@@ -0,0 +1,228 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Storage for everything the handlers read and write.  MySqlStore is used in
+// production, MemoryStore for local runs and tests without a MySQL server.
+type Store interface {
+	// Run fn against a store whose changes are all kept if it returns nil,
+	// and all undone if it returns an error
+	InTransaction(fn func(store Store) error) error
+	PersonStore
+	SpouseStore
+	CityStore
+	RegionStore
+	CountryStore
+	ContinentStore
+	TagStore
+	HolidayStore
+	UserStore
+	FavoriteStore
+	ReminderRecipientStore
+	ReminderRunStore
+}
+
+type PersonStore interface {
+	LoadPersonLiteById(id int) (*PersonLite, error)
+	LoadPersonById(id int) (*Person, error)
+	LoadPersonDataById(id int) (*PersonData, error)
+	LoadPersonList() ([]Person, error)
+	LoadPersonLiteList() ([]PersonLite, error)
+	LoadPersonLiteListByIds(ids []int) ([]PersonLite, error)
+	LoadPersonLiteListByHomeCityId(cityId int) ([]PersonLite, error)
+	LoadChildrenPersonLiteByParentIds(parentIds []int) ([]PersonLite, error)
+	LoadPersonLiteListWithTag(tagLabel string) ([]PersonLite, error)
+	LoadPersonSearchNames() ([]PersonSearchNames, error)
+	LoadAlternateNames(personId int) ([]AlternateName, error)
+	InsertAlternateName(personId int, kind AlternateNameKind, name string) (int, error)
+	DeleteAlternateName(personId int, id int) error
+	LoadPersonLiteListByName(firstName string, lastName string, birthDate string) ([]PersonLite, error)
+	LoadBirthdays() ([]CalendarPerson, error)
+	LoadMemorials() ([]CalendarMemorial, error)
+	InsertPerson(data PersonData) (int, error)
+	UpdatePerson(personId int, data PersonData) error
+	UpdatePersonParents(personId int, motherId int, fatherId int) error
+	DeletePerson(personId int) error
+}
+
+type SpouseStore interface {
+	LoadSpousesByPersonId(personId int) ([]SpouseLite, error)
+	LoadSpousesByPersonIds(personIds []int) ([]SpouseLite, error)
+	LoadSpouseList() ([]SpouseLite, error)
+	LoadAnniversaries() ([]CalendarAnniversary, error)
+	SpouseExists(person1Id int, person2Id int) (bool, error)
+	DeleteSpouse(person1Id int, person2Id int) error
+	InsertSpouse(person1Id int, person2Id int, status int, marriedDate string) error
+	UpdateSpouse(person1Id int, person2Id int, status int, marriedDate string) error
+}
+
+type CityStore interface {
+	LoadCityById(id int) (*CityLite, error)
+	LoadCitiesByIds(ids []int) ([]CityLite, error)
+	LoadCityList() ([]CityLite, error)
+	LoadCitiesByRegionId(regionId int) ([]CityLite, error)
+	LoadCitiesByCountryCode(countryCode string) ([]CityLite, error)
+	LoadCitiesByName(name string) ([]CityLite, error)
+	LoadCitiesByPrefix(prefix string, offset int) ([]CityLite, error)
+	DeleteCity(id int) error
+	InsertCity(name string, regionId int, lat float32, lng float32) (*CityLite, error)
+	UpdateCity(cityId int, name string, regionId int, lat float32, lng float32) error
+}
+
+type RegionStore interface {
+	LoadRegionById(id int) (*RegionLite, error)
+	LoadRegionsByCountryCode(countryCode string) ([]RegionLite, error)
+	LoadRegionList() ([]RegionLite, error)
+	DeleteRegion(regionId int) error
+	InsertRegion(data RegionData) (*RegionLite, error)
+	UpdateRegion(regionId int, data RegionData) error
+}
+
+type CountryStore interface {
+	LoadCountriesByContinentCode(continentCode string) ([]Country, error)
+	LoadCountryByCode(code string) (*Country, error)
+	LoadCountryList() ([]Country, error)
+	DeleteCountryByCode(code string) error
+	InsertCountry(data CountryData) error
+	UpdateCountry(originalCode string, data CountryData) error
+}
+
+type ContinentStore interface {
+	LoadContinentList() ([]Continent, error)
+	LoadContinentByCode(code string) (*ContinentWithMap, error)
+	InsertContinent(data ContinentWithMap) error
+	UpdateContinent(originalCode string, data ContinentWithMap) error
+	DeleteContinent(code string) error
+}
+
+type TagStore interface {
+	InsertTag(label string) (*Tag, error)
+	UpdateTag(tagId int, label string) error
+	LoadTagById(tagId int) (*Tag, error)
+	LoadTagByLabel(label string) (*Tag, error)
+	InsertPeopleTag(tagId int, personId int) error
+	LoadTagsListByPrefix(labelPrefix string) ([]Tag, error)
+	LoadTagsForPerson(personId int) ([]Tag, error)
+	LoadTagsByPerson() (map[int][]Tag, error)
+	DeletePeopleTag(tagId int, personId int) error
+	DeleteTag(tagId int) error
+}
+
+type HolidayStore interface {
+	LoadHolidays(startYear int) ([]Holiday, error)
+	LoadHolidaysInRange(startTime time.Time, endTime time.Time) ([]Holiday, error)
+	LoadHolidayList() ([]HolidayDefinition, error)
+	LoadHolidayById(id int) (*HolidayDefinition, error)
+	InsertHoliday(data HolidayData) (int, error)
+	UpdateHoliday(id int, data HolidayData) error
+	DeleteHoliday(id int) error
+	InsertHolidayItem(holidayId int, date time.Time) error
+	DeleteHolidayItem(holidayId int, date time.Time) error
+	DeleteHolidayItems(holidayId int) error
+}
+
+type UserStore interface {
+	LoadUserById(id int) (*User, error)
+	LoadUserByEmail(email string) (*User, error)
+	LoadUserList() ([]User, error)
+	InsertUser(email string, name string, role Role, passwordHash string) (int, error)
+	UpdateUserPassword(userId int, passwordHash string) error
+	UpdateUserRole(userId int, role Role) error
+	UpdateUserCalendarToken(userId int, tokenHash string) error
+	LoadUserByCalendarToken(tokenHash string) (*User, error)
+	InsertSession(tokenHash string, userId int, expiresAt time.Time) error
+	LoadUserBySession(tokenHash string) (*User, error)
+	DeleteSession(tokenHash string) error
+	DeleteExpiredSessions() error
+}
+
+type FavoriteStore interface {
+	LoadFavoritePeople(userId int) ([]PersonLite, error)
+	LoadFavoritePersonIds(userId int) (map[int]bool, error)
+	InsertFavoritePerson(userId int, personId int) error
+	DeleteFavoritePerson(userId int, personId int) error
+}
+
+type ReminderRecipientStore interface {
+	LoadReminderRecipientList() ([]ReminderRecipient, error)
+	LoadReminderRecipientById(id int) (*ReminderRecipient, error)
+	InsertReminderRecipient(data ReminderRecipientData) (int, error)
+	UpdateReminderRecipient(id int, data ReminderRecipientData) error
+	DeleteReminderRecipient(id int) error
+}
+
+type ReminderRunStore interface {
+	InsertReminderRun(weekStart time.Time, startedAt time.Time) (int, error)
+	FinishReminderRun(run *ReminderRun) error
+	LoadReminderRunList(limit int) ([]ReminderRun, error)
+	ClaimReminderEmail(runId int, weekStart time.Time, recipientId int, email string) (bool, error)
+	MarkReminderEmailSent(weekStart time.Time, recipientId int) error
+	ReleaseReminderEmail(weekStart time.Time, recipientId int) error
+}
+
+// Wrapped by the errors stores return when what was asked for doesn't
+// exist, so callers can tell with errors.Is
+var ErrNotFound = errors.New("not found")
+
+// An error saying something wasn't found, that wraps ErrNotFound
+type notFoundError struct {
+	message string
+}
+
+func (e *notFoundError) Error() string {
+	return e.message
+}
+
+func (e *notFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+func notFoundf(format string, args ...interface{}) error {
+	return &notFoundError{message: fmt.Sprintf(format, args...)}
+}
+
+// What the MySQL store's queries run on: the database, or a transaction
+type mysqlConn interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Begin() (*sql.Tx, error)
+}
+
+// A transaction the store's queries run in.  MySQL can't nest them, so the
+// few methods that start their own fail inside InTransaction.
+type mysqlTx struct {
+	*sql.Tx
+}
+
+func (t mysqlTx) Begin() (*sql.Tx, error) {
+	return nil, fmt.Errorf("Already in a transaction")
+}
+
+// Store backed by the MySQL database
+type MySqlStore struct {
+	db mysqlConn
+}
+
+func NewMySqlStore(db *sql.DB) *MySqlStore {
+	return &MySqlStore{db: db}
+}
+
+func (s *MySqlStore) InTransaction(fn func(store Store) error) error {
+	defer trace(traceName("InTransaction"))
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(&MySqlStore{db: mysqlTx{tx}})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// Every case runs against a new MemoryStore, and against MySQL when
+// FAMILY_TEST_DATABASE has the dsn of a scratch database.  Migrations are
+// applied to it first, and each case cleans up what it adds, using names
+// unique to the run so rows already there don't get in the way.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	dsn := os.Getenv("FAMILY_TEST_DATABASE")
+	if dsn == "" {
+		return
+	}
+	t.Run("mysql", func(t *testing.T) {
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		err = MigrateUp(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, NewMySqlStore(db))
+	})
+}
+
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s %d", prefix, time.Now().UnixNano())
+}
+
+func insertTestPerson(t *testing.T, store Store, data PersonData) int {
+	t.Helper()
+	id, err := store.InsertPerson(data)
+	if err != nil {
+		t.Fatalf("Error inserting %s: %v", data.FirstName, err)
+	}
+	t.Cleanup(func() { store.DeletePerson(id) })
+	return id
+}
+
+func TestStorePeople(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		lastName := uniqueName("Tester")
+		motherId := insertTestPerson(t, store, PersonData{FirstName: "Mary", LastName: lastName, Gender: "F", IsAlive: true, BirthDate: "1950-03-04"})
+		fatherId := insertTestPerson(t, store, PersonData{FirstName: "John", LastName: lastName, Gender: "M", IsAlive: true})
+		childId := insertTestPerson(t, store, PersonData{FirstName: "Sam", LastName: lastName, Gender: "U", IsAlive: true, BirthDate: "1980-01-01", IsBirthYearGuess: true})
+
+		err := store.UpdatePersonParents(childId, motherId, fatherId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		child, err := store.LoadPersonById(childId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if child.FullName() != "Sam "+lastName || child.Gender != "Unknown" || !child.IsBirthYearGuess {
+			t.Errorf("Loaded %+v", child)
+		}
+		if child.Mother == nil || child.Mother.Id != motherId || child.Father == nil || child.Father.Id != fatherId {
+			t.Errorf("Parents are %+v and %+v", child.Mother, child.Father)
+		}
+		if child.BirthDate.Format("2006-01-02") != "1980-01-01" {
+			t.Errorf("Birth date is %v", child.BirthDate)
+		}
+
+		mother, err := store.LoadPersonById(motherId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mother.Children) != 1 || mother.Children[0].Id != childId {
+			t.Errorf("Children are %+v", mother.Children)
+		}
+
+		matches, err := store.LoadPersonLiteListByName("Mary", lastName, "1950-03-04")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 1 || matches[0].Id != motherId {
+			t.Errorf("Matched %+v", matches)
+		}
+
+		data, err := store.LoadPersonDataById(childId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.NickName = "Sammy"
+		data.IsAlive = false
+		data.DeathDate = "2020-05-06"
+		err = store.UpdatePerson(childId, *data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated, err := store.LoadPersonDataById(childId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.NickName != "Sammy" || updated.IsAlive || updated.DeathDate != "2020-05-06" || updated.MotherId != motherId {
+			t.Errorf("Updated to %+v", updated)
+		}
+
+		err = store.DeletePerson(childId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.LoadPersonById(childId)
+		if err == nil {
+			t.Errorf("Deleted person still loads")
+		}
+	})
+}
+
+func TestStoreSpouses(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		lastName := uniqueName("Spouse")
+		husbandId := insertTestPerson(t, store, PersonData{FirstName: "Tom", LastName: lastName, Gender: "M", IsAlive: true})
+		wifeId := insertTestPerson(t, store, PersonData{FirstName: "Ann", LastName: lastName, Gender: "F", IsAlive: true})
+
+		err := store.InsertSpouse(husbandId, wifeId, 1, "1975-06-07")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.DeleteSpouse(husbandId, wifeId) })
+		exists, err := store.SpouseExists(wifeId, husbandId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Errorf("Spouses don't exist either way round")
+		}
+
+		err = store.UpdateSpouse(husbandId, wifeId, 3, "1976-06-07")
+		if err != nil {
+			t.Fatal(err)
+		}
+		spouses, err := store.LoadSpousesByPersonId(wifeId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(spouses) != 1 || spouses[0].Person2.Id != husbandId || spouses[0].Status != 3 ||
+			spouses[0].MarriedDate.Format("2006-01-02") != "1976-06-07" {
+			t.Errorf("Spouses are %+v", spouses)
+		}
+
+		err = store.DeleteSpouse(husbandId, wifeId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exists, err = store.SpouseExists(husbandId, wifeId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists {
+			t.Errorf("Deleted spouses still exist")
+		}
+	})
+}
+
+func TestStoreTags(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		label := uniqueName("Tag")
+		personId := insertTestPerson(t, store, PersonData{FirstName: "Tagged", LastName: label, Gender: "F", IsAlive: true})
+		tag, err := store.InsertTag(label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.DeleteTag(tag.Id) })
+
+		err = store.InsertPeopleTag(tag.Id, personId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Tagging twice is allowed
+		err = store.InsertPeopleTag(tag.Id, personId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		people, err := store.LoadPersonLiteListWithTag(label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(people) != 1 || people[0].Id != personId {
+			t.Errorf("Tagged people are %+v", people)
+		}
+		byLabel, err := store.LoadTagByLabel(label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if byLabel.Id != tag.Id {
+			t.Errorf("Loaded %+v by label", byLabel)
+		}
+
+		err = store.DeletePeopleTag(tag.Id, personId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tags, err := store.LoadTagsForPerson(personId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tags) != 0 {
+			t.Errorf("Tags after untagging are %+v", tags)
+		}
+	})
+}
+
+func TestStorePlaces(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		code := fmt.Sprintf("Z%d", time.Now().UnixNano()%10)
+		err := store.InsertCountry(CountryData{Code: code, Name: uniqueName("Country"), ContinentCode: "EU"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.DeleteCountryByCode(code) })
+		region, err := store.InsertRegion(RegionData{Code: "RG", Name: "Region", CountryCode: code})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.DeleteRegion(region.Id) })
+		cityName := uniqueName("City")
+		city, err := store.InsertCity(cityName, region.Id, 1.5, 2.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.DeleteCity(city.Id) })
+
+		cities, err := store.LoadCitiesByName(cityName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cities) != 1 || cities[0].Id != city.Id || cities[0].RegionAbbr != "RG" || cities[0].CountryAbbr != code {
+			t.Errorf("Cities named %s are %+v", cityName, cities)
+		}
+		regions, err := store.LoadRegionsByCountryCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(regions) != 1 || regions[0].Id != region.Id {
+			t.Errorf("Regions are %+v", regions)
+		}
+
+		err = store.DeleteCity(city.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = store.LoadCityById(city.Id)
+		if err == nil {
+			t.Errorf("Deleted city still loads")
+		}
+	})
+}
+
+func TestStoreHolidays(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		id, err := store.InsertHoliday(HolidayData{Name: uniqueName("Holiday"), Rule: HolidayRuleDates})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.DeleteHoliday(id) })
+		date := time.Date(2031, time.July, 4, 0, 0, 0, 0, time.UTC)
+		err = store.InsertHolidayItem(id, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		holiday, err := store.LoadHolidayById(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(holiday.Dates) != 1 || !holiday.Dates[0].Equal(date) {
+			t.Errorf("Dates are %v", holiday.Dates)
+		}
+
+		err = store.DeleteHolidayItem(id, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		holiday, err = store.LoadHolidayById(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(holiday.Dates) != 0 {
+			t.Errorf("Dates after deleting are %v", holiday.Dates)
+		}
+	})
+}
+
+func TestStoreInTransaction(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		lastName := uniqueName("Transaction")
+		var keptId int
+		err := store.InTransaction(func(tx Store) error {
+			var err error
+			keptId, err = tx.InsertPerson(PersonData{FirstName: "Kept", LastName: lastName, Gender: "M", IsAlive: true})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.DeletePerson(keptId) })
+
+		err = store.InTransaction(func(tx Store) error {
+			_, err := tx.InsertPerson(PersonData{FirstName: "Undone", LastName: lastName, Gender: "F", IsAlive: true})
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("Failed on purpose")
+		})
+		if err == nil {
+			t.Fatal("Failed transaction returned no error")
+		}
+
+		kept, err := store.LoadPersonLiteListByName("Kept", lastName, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		undone, err := store.LoadPersonLiteListByName("Undone", lastName, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(kept) != 1 || len(undone) != 0 {
+			t.Errorf("Kept %+v, undone %+v", kept, undone)
+		}
+	})
+}
+
+func TestStoreReminderEmailClaims(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		// A Monday no other run has used
+		weekStart := time.Date(2031, time.January, 6, 0, 0, 0, 0, time.UTC).AddDate(0, 0, 7*int(time.Now().UnixNano()%500))
+		runId, err := store.InsertReminderRun(weekStart, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		claimed, err := store.ClaimReminderEmail(runId, weekStart, 1, "a@example.com")
+		if err != nil || !claimed {
+			t.Fatalf("First claim gave %v, %v", claimed, err)
+		}
+		t.Cleanup(func() { store.ReleaseReminderEmail(weekStart, 1) })
+		claimed, err = store.ClaimReminderEmail(runId, weekStart, 1, "a@example.com")
+		if err != nil || claimed {
+			t.Errorf("Claiming again gave %v, %v", claimed, err)
+		}
+
+		err = store.ReleaseReminderEmail(weekStart, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claimed, err = store.ClaimReminderEmail(runId, weekStart, 1, "a@example.com")
+		if err != nil || !claimed {
+			t.Errorf("Claiming after releasing gave %v, %v", claimed, err)
+		}
+		err = store.MarkReminderEmailSent(weekStart, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claimed, err = store.ClaimReminderEmail(runId, weekStart, 1, "a@example.com")
+		if err != nil || claimed {
+			t.Errorf("Claiming a sent email gave %v, %v", claimed, err)
+		}
+	})
+}
+
+func TestStaleReminderClaims(t *testing.T) {
+	store := NewMemoryStore()
+	weekStart := time.Date(2031, time.January, 6, 0, 0, 0, 0, time.UTC)
+	claimed, _ := store.ClaimReminderEmail(1, weekStart, 1, "a@example.com")
+	if !claimed {
+		t.Fatal("First claim failed")
+	}
+
+	// A run that died after claiming leaves a claim that's never marked sent
+	key := reminderEmailKey(weekStart, 1)
+	claim := store.runEmails[key]
+	claim.ClaimedAt = time.Now().Add(-reminderClaimTimeout - time.Minute)
+	store.runEmails[key] = claim
+	claimed, _ = store.ClaimReminderEmail(2, weekStart, 1, "a@example.com")
+	if !claimed {
+		t.Errorf("Stale claim wasn't taken over")
+	}
+	if store.runEmails[key].RunId != 2 {
+		t.Errorf("Claim belongs to run %d", store.runEmails[key].RunId)
+	}
+
+	store.MarkReminderEmailSent(weekStart, 1)
+	claim = store.runEmails[key]
+	claim.ClaimedAt = time.Now().Add(-reminderClaimTimeout - time.Minute)
+	store.runEmails[key] = claim
+	claimed, _ = store.ClaimReminderEmail(3, weekStart, 1, "a@example.com")
+	if claimed {
+		t.Errorf("Old but sent email was claimed again")
+	}
+}
+
+func TestStoreHolidayItemsOncePerDate(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		id, err := store.InsertHoliday(HolidayData{Name: uniqueName("Holiday"), Rule: HolidayRuleFixed, Month: 7, Day: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.DeleteHoliday(id) })
+
+		// Another server adding the same year's dates at the same time
+		date := time.Date(2032, time.July, 4, 0, 0, 0, 0, time.UTC)
+		err = store.InsertHolidayItem(id, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ensureHolidayItems(store, 2032, 2033)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = store.InsertHolidayItem(id, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		holiday, err := store.LoadHolidayById(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(holiday.Dates) != 2 || !holiday.Dates[0].Equal(date) || holiday.Dates[1].Year() != 2033 {
+			t.Errorf("Dates are %v", holiday.Dates)
+		}
+
+		// A rule change on another server removes the dates, and they're made
+		// again here
+		err = store.DeleteHolidayItems(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ensureHolidayItems(store, 2032, 2032)
+		if err != nil {
+			t.Fatal(err)
+		}
+		holiday, err = store.LoadHolidayById(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(holiday.Dates) != 1 || !holiday.Dates[0].Equal(date) {
+			t.Errorf("Dates after removing are %v", holiday.Dates)
+		}
+	})
+}
+
+func TestStoreNotFound(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		const missingId = 1 << 30
+		loads := map[string]func() error{
+			"person":       func() error { _, err := store.LoadPersonById(missingId); return err },
+			"person data":  func() error { _, err := store.LoadPersonDataById(missingId); return err },
+			"city":         func() error { _, err := store.LoadCityById(missingId); return err },
+			"region":       func() error { _, err := store.LoadRegionById(missingId); return err },
+			"country":      func() error { _, err := store.LoadCountryByCode("Q!"); return err },
+			"continent":    func() error { _, err := store.LoadContinentByCode("Q!"); return err },
+			"tag":          func() error { _, err := store.LoadTagById(missingId); return err },
+			"tag by label": func() error { _, err := store.LoadTagByLabel(uniqueName("Missing")); return err },
+			"holiday":      func() error { _, err := store.LoadHolidayById(missingId); return err },
+			"user":         func() error { _, err := store.LoadUserById(missingId); return err },
+			"recipient":    func() error { _, err := store.LoadReminderRecipientById(missingId); return err },
+		}
+		for name, load := range loads {
+			err := load()
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Loading a missing %s gave %v", name, err)
+			}
+		}
+	})
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"html/template"
 	"net/http"
 	"strconv"
 	"strings"
@@ -20,16 +20,24 @@ func tagJsonAdd(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Error parsing person_id: %v", err), http.StatusBadRequest)
 		return
 	}
-	tag, err := LoadTagByLabel(db, label)
+	_, err = store.LoadPersonLiteById(personId)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading person: %v", err), http.StatusInternalServerError)
+		return
+	}
+	tag, err := store.LoadTagByLabel(label)
 	if tag == nil {
-		tag, err = InsertTag(db, label)
+		tag, err = store.InsertTag(label)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error inserting tag: %v", err), http.StatusInternalServerError)
 			return
 		}
 	}
 
-	err = InsertPeopleTag(db, tag.Id, personId)
+	err = store.InsertPeopleTag(tag.Id, personId)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error inserting tag: %v", err), http.StatusInternalServerError)
 		return
@@ -51,20 +59,20 @@ func tagJsonDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if tagId == 0 {
-		tag, _ := LoadTagByLabel(db, label)
+		tag, _ := store.LoadTagByLabel(label)
 		if tag == nil {
 			http.Error(w, fmt.Sprintf("Tag with specified label not found: %v", label), http.StatusBadRequest)
 			return
 		}
 		tagId = tag.Id
 	}
-	err = DeletePeopleTag(db, tagId, personId)
+	err = store.DeletePeopleTag(tagId, personId)
 	w.WriteHeader(http.StatusAccepted)
 }
 
 func tagJsonList(w http.ResponseWriter, r *http.Request) {
 	labelPrefix := strings.TrimSpace(r.FormValue("prefix"))
-	tags, err := LoadTagsListByPrefix(db, labelPrefix)
+	tags, err := store.LoadTagsListByPrefix(labelPrefix)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading tags: %v", err), 500)
 		return
@@ -74,15 +82,12 @@ func tagJsonList(w http.ResponseWriter, r *http.Request) {
 }
 
 func tagList(w http.ResponseWriter, r *http.Request) {
-	data, err := LoadTagsListByPrefix(db, "")
+	data, err := store.LoadTagsListByPrefix("")
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading tags: %v", err), 500)
 		return
 	}
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/tag/list.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "tag/list.html", data)
 }
 
 func tagView(w http.ResponseWriter, r *http.Request) {
@@ -92,12 +97,12 @@ func tagView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tag, err := LoadTagByLabel(db, tagLabel)
+	tag, err := store.LoadTagByLabel(tagLabel)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading tag: %v", err), 400)
 		return
 	}
-	personList, err := LoadPersonLiteListWithTag(db, tag.Label)
+	personList, err := store.LoadPersonLiteListWithTag(tag.Label)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error loading person list: %v", err), 400)
 		return
@@ -111,16 +116,13 @@ func tagView(w http.ResponseWriter, r *http.Request) {
 		personList,
 	}
 
-	err = template.Must(template.ParseFiles("tmpl/layout/main.html", "tmpl/tag/view.html")).Execute(w, data)
-	if err != nil {
-		panic(err)
-	}
+	renderTemplate(w, r, "tag/view.html", data)
 }
 
 func addTagRoutes() {
-	http.HandleFunc("/tag/json/add", tagJsonAdd)
-	http.HandleFunc("/tag/json/delete", tagJsonDelete)
-	http.HandleFunc("/tag/json/list", tagJsonList)
-	http.HandleFunc("/tag/list", tagList)
-	http.HandleFunc("/tag/view/", tagView)
+	http.HandleFunc("/tag/json/add", requireRole(RoleEditor, requirePost(tagJsonAdd)))
+	http.HandleFunc("/tag/json/delete", requireRole(RoleEditor, requirePost(tagJsonDelete)))
+	http.HandleFunc("/tag/json/list", requireRole(RoleViewer, tagJsonList))
+	http.HandleFunc("/tag/list", requireRole(RoleViewer, tagList))
+	http.HandleFunc("/tag/view/", requireRole(RoleViewer, tagView))
 }
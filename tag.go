@@ -10,9 +10,9 @@ type Tag struct {
 	Label string
 }
 
-func InsertTag(db *sql.DB, label string) (*Tag, error) {
+func (s *MySqlStore) InsertTag(label string) (*Tag, error) {
 	defer trace(traceName(fmt.Sprintf("InsertTag(%s)", label)))
-	res, err := db.Exec(
+	res, err := s.db.Exec(
 		"INSERT INTO tags"+
 			" (label)"+
 			" VALUES (?)",
@@ -25,9 +25,19 @@ func InsertTag(db *sql.DB, label string) (*Tag, error) {
 	}
 }
 
-func LoadTagById(db *sql.DB, tagId int) (*Tag, error) {
+func (s *MySqlStore) UpdateTag(tagId int, label string) error {
+	defer trace(traceName(fmt.Sprintf("UpdateTag(%d, %s)", tagId, label)))
+	_, err := s.db.Exec(
+		"UPDATE tags"+
+			" SET label=?"+
+			" WHERE id=?",
+		label, tagId)
+	return err
+}
+
+func (s *MySqlStore) LoadTagById(tagId int) (*Tag, error) {
 	defer trace(traceName(fmt.Sprintf("LoadTagById(%d)", tagId)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT id, label"+
 			" FROM tags"+
 			" WHERE id=?",
@@ -37,14 +47,14 @@ func LoadTagById(db *sql.DB, tagId int) (*Tag, error) {
 	}
 	defer rows.Close()
 	if !rows.Next() {
-		return nil, fmt.Errorf("Tag not found (%+v)", tagId)
+		return nil, notFoundf("Tag not found (%+v)", tagId)
 	}
 	return readTagFromRows(rows)
 }
 
-func LoadTagByLabel(db *sql.DB, label string) (*Tag, error) {
+func (s *MySqlStore) LoadTagByLabel(label string) (*Tag, error) {
 	defer trace(traceName(fmt.Sprintf("LoadTagByLabel(%s)", label)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT id, label"+
 			" FROM tags"+
 			" WHERE label=?",
@@ -54,14 +64,14 @@ func LoadTagByLabel(db *sql.DB, label string) (*Tag, error) {
 	}
 	defer rows.Close()
 	if !rows.Next() {
-		return nil, fmt.Errorf("Tag not found (%+v)", label)
+		return nil, notFoundf("Tag not found (%+v)", label)
 	}
 	return readTagFromRows(rows)
 }
 
-func InsertPeopleTag(db *sql.DB, tagId int, personId int) error {
+func (s *MySqlStore) InsertPeopleTag(tagId int, personId int) error {
 	defer trace(traceName(fmt.Sprintf("InsertPeopleTag(%d, %d)", tagId, personId)))
-	_, err := db.Exec(
+	_, err := s.db.Exec(
 		"INSERT IGNORE INTO people_tags"+
 			" (person_id, tag_id)"+
 			" VALUES (?, ?)",
@@ -69,9 +79,9 @@ func InsertPeopleTag(db *sql.DB, tagId int, personId int) error {
 	return err
 }
 
-func LoadTagsListByPrefix(db *sql.DB, labelPrefix string) ([]Tag, error) {
+func (s *MySqlStore) LoadTagsListByPrefix(labelPrefix string) ([]Tag, error) {
 	defer trace(traceName("LoadTags()"))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT id, label"+
 			" FROM tags"+
 			" WHERE label LIKE CONCAT(?, '%')"+
@@ -85,9 +95,9 @@ func LoadTagsListByPrefix(db *sql.DB, labelPrefix string) ([]Tag, error) {
 	return readTagListFromRows(rows)
 }
 
-func LoadTagsForPerson(db *sql.DB, personId int) ([]Tag, error) {
+func (s *MySqlStore) LoadTagsForPerson(personId int) ([]Tag, error) {
 	defer trace(traceName(fmt.Sprintf("LoadTagsForPerson(%d)", personId)))
-	rows, err := db.Query(
+	rows, err := s.db.Query(
 		"SELECT t.id, t.label"+
 			" FROM tags t"+
 			"   INNER JOIN people_tags pt ON pt.tag_id=t.id"+
@@ -102,19 +112,45 @@ func LoadTagsForPerson(db *sql.DB, personId int) ([]Tag, error) {
 	return readTagListFromRows(rows)
 }
 
-func DeletePeopleTag(db *sql.DB, tagId int, personId int) error {
+// Load the tags of every person, keyed by person id
+func (s *MySqlStore) LoadTagsByPerson() (map[int][]Tag, error) {
+	defer trace(traceName("LoadTagsByPerson"))
+	rows, err := s.db.Query(
+		"SELECT pt.person_id, t.id, t.label" +
+			" FROM tags t" +
+			"   INNER JOIN people_tags pt ON pt.tag_id=t.id" +
+			" ORDER BY pt.person_id, t.label")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lookup := make(map[int][]Tag)
+	for rows.Next() {
+		var personId int
+		var tag Tag
+		err = rows.Scan(&personId, &tag.Id, &tag.Label)
+		if err != nil {
+			return nil, err
+		}
+		lookup[personId] = append(lookup[personId], tag)
+	}
+	return lookup, nil
+}
+
+func (s *MySqlStore) DeletePeopleTag(tagId int, personId int) error {
 	defer trace(traceName(fmt.Sprintf("DeletePeopleTag(%d, %d)", tagId, personId)))
-	_, err := db.Exec(
+	_, err := s.db.Exec(
 		"DELETE FROM people_tags"+
 			" WHERE tag_id=? AND person_id=?",
 		tagId, personId)
 	return err
 }
 
-func DeleteTag(db *sql.DB, tagId int) error {
+func (s *MySqlStore) DeleteTag(tagId int) error {
 	defer trace(traceName(fmt.Sprintf("DeleteTag(%d)", tagId)))
-	tx, err := db.Begin()
-	_, err = db.Exec(
+	tx, err := s.db.Begin()
+	_, err = s.db.Exec(
 		"DELETE FROM tags"+
 			" WHERE id=?",
 		tagId)
@@ -122,7 +158,7 @@ func DeleteTag(db *sql.DB, tagId int) error {
 		tx.Rollback()
 		return err
 	}
-	_, err = db.Exec(
+	_, err = s.db.Exec(
 		"DELETE FROM people_tags"+
 			" WHERE tag_id=?",
 		tagId)
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Parsed templates the handlers render, set up at startup
+var templates *TemplateRegistry
+
+const templateDir = "tmpl"
+
+// Page every other page is rendered inside of
+const templateLayout = "layout/main.html"
+
+// Templates rendered on their own rather than inside the layout
+var standaloneTemplates = map[string]bool{
+	"cron/email.html": true,
+	"cron/email.txt":  true,
+}
+
+// Templates pages include, parsed with the pages that use them rather than
+// as pages of their own
+var templatePartials = map[string][]string{
+	"person/view.html": {"person/tree.html"},
+}
+
+// The functions the layout and pages share.  "can" reports whether the
+// logged in user has a role, so pages can hide actions the user isn't allowed
+// to take.  "asset" links to a file under assets/.  "csrfToken" is sent
+// back by forms and scripts that change anything.
+func templateFuncs(user *User, csrfToken string) template.FuncMap {
+	return template.FuncMap{
+		"can": func(role string) bool {
+			return user.Can(Role(role))
+		},
+		"currentUser": func() *User {
+			return user
+		},
+		"asset": assetUrl,
+		"csrfToken": func() string {
+			return csrfToken
+		},
+	}
+}
+
+// A page parsed with its layout and partials
+type parsedTemplate struct {
+	template *template.Template
+	files    []string
+	parsedAt time.Time
+}
+
+// Every template under tmpl, parsed once.  Pages are named by their path
+// under tmpl, like "person/view.html".  When reloading, a page is parsed
+// again when one of its files has changed since it was last parsed.
+type TemplateRegistry struct {
+	files  fs.FS
+	reload bool
+	mutex  sync.RWMutex
+	pages  map[string]*parsedTemplate
+}
+
+// Parse every template, failing if any of them don't parse
+func NewTemplateRegistry(files fs.FS, reload bool) (*TemplateRegistry, error) {
+	registry := &TemplateRegistry{
+		files:  files,
+		reload: reload,
+		pages:  make(map[string]*parsedTemplate),
+	}
+
+	partials := make(map[string]bool)
+	for _, list := range templatePartials {
+		for _, name := range list {
+			partials[name] = true
+		}
+	}
+	err := fs.WalkDir(registry.files, templateDir, func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name := strings.TrimPrefix(filename, templateDir+"/")
+		if name == templateLayout || partials[name] {
+			return nil
+		}
+		page, err := registry.parse(name)
+		if err != nil {
+			return err
+		}
+		registry.pages[name] = page
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func (t *TemplateRegistry) parse(name string) (*parsedTemplate, error) {
+	var files []string
+	if !standaloneTemplates[name] {
+		files = append(files, templateLayout)
+	}
+	files = append(files, name)
+	files = append(files, templatePartials[name]...)
+
+	page := &parsedTemplate{files: files, parsedAt: time.Now()}
+	var patterns []string
+	for _, file := range files {
+		patterns = append(patterns, path.Join(templateDir, file))
+	}
+	var err error
+	page.template, err = template.New(path.Base(files[0])).Funcs(templateFuncs(nil, "")).ParseFS(t.files, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing template %s: %v", name, err)
+	}
+	return page, nil
+}
+
+// Whether any of the page's files have changed since it was parsed
+func (t *TemplateRegistry) changed(page *parsedTemplate) bool {
+	for _, file := range page.files {
+		info, err := fs.Stat(t.files, path.Join(templateDir, file))
+		if err != nil || info.ModTime().After(page.parsedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *TemplateRegistry) lookup(name string) (*template.Template, error) {
+	t.mutex.RLock()
+	page, found := t.pages[name]
+	t.mutex.RUnlock()
+	if !found && !t.reload {
+		return nil, fmt.Errorf("No template named %s", name)
+	}
+	if t.reload && (!found || t.changed(page)) {
+		var err error
+		page, err = t.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		t.mutex.Lock()
+		t.pages[name] = page
+		t.mutex.Unlock()
+	}
+	// Executing a template stops it being cloned, so only ever execute copies
+	return page.template.Clone()
+}
+
+// Execute a template for the given user and their session's CSRF token
+func (t *TemplateRegistry) Execute(w io.Writer, name string, user *User, csrfToken string, data interface{}) error {
+	page, err := t.lookup(name)
+	if err != nil {
+		return err
+	}
+	err = page.Funcs(templateFuncs(user, csrfToken)).Execute(w, data)
+	if err != nil {
+		return fmt.Errorf("Error rendering template %s: %v", name, err)
+	}
+	return nil
+}
+
+// Render a page for the logged in user.  It's rendered to a buffer first, so
+// a page that fails part way through becomes an error page rather than half
+// a page.
+func renderTemplate(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	var buffer bytes.Buffer
+	err := templates.Execute(&buffer, name, CurrentUser(r), CsrfToken(r), data)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, fmt.Sprintf("Error rendering page: %v", err), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buffer.WriteTo(w)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Only redirect back to pages on this site after logging in
+func safeRedirectPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/person/list"
+	}
+	return next
+}
+
+func userLogin(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirectPath(r.FormValue("next"))
+	data := struct {
+		Email string
+		Next  string
+		Error string
+	}{
+		Next: next,
+	}
+
+	if r.Method == "POST" {
+		data.Email = strings.TrimSpace(r.FormValue("email"))
+		user, err := AuthenticateUser(store, data.Email, r.FormValue("password"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error logging in: %v", err), 500)
+			return
+		}
+		if user != nil {
+			store.DeleteExpiredSessions()
+			err = StartSession(w, r, user.Id)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Error starting session: %v", err), 500)
+				return
+			}
+			http.Redirect(w, r, next, 302)
+			return
+		}
+		data.Error = "Incorrect email or password."
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	renderTemplate(w, r, "user/login.html", data)
+}
+
+func userLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Log out with a POST", http.StatusMethodNotAllowed)
+		return
+	}
+	err := EndSession(w, r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error ending session: %v", err), 500)
+		return
+	}
+	http.Redirect(w, r, "/login", 302)
+}
+
+func userList(w http.ResponseWriter, r *http.Request) {
+	users, err := store.LoadUserList()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error loading users: %v", err), 500)
+		return
+	}
+	data := struct {
+		Users []User
+		Roles []Role
+	}{
+		Users: users,
+		Roles: Roles,
+	}
+	renderTemplate(w, r, "user/list.html", data)
+}
+
+func userRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Change roles with a POST", http.StatusMethodNotAllowed)
+		return
+	}
+	userId, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid user id: %v", err), 400)
+		return
+	}
+	role := Role(r.FormValue("role"))
+	if !role.IsValid() {
+		http.Error(w, fmt.Sprintf("Unknown role: %s", role), 400)
+		return
+	}
+	// Stop the last admin locking everyone out of the admin pages
+	if userId == CurrentUser(r).Id && role != RoleAdmin {
+		http.Error(w, "You can't remove your own admin role", 400)
+		return
+	}
+	err = store.UpdateUserRole(userId, role)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error updating role: %v", err), 500)
+		return
+	}
+	http.Redirect(w, r, "/user/list", 302)
+}
+
+func addUserRoutes() {
+	http.HandleFunc("/login", userLogin)
+	http.HandleFunc("/logout", userLogout)
+	http.HandleFunc("/user/list", requireRole(RoleAdmin, userList))
+	http.HandleFunc("/user/role", requireRole(RoleAdmin, userRole))
+}
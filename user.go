@@ -0,0 +1,215 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// What a user may do.  Each role can do everything the ones before it can.
+type Role string
+
+const (
+	// Can browse everything
+	RoleViewer Role = "viewer"
+	// Can also add and edit
+	RoleEditor Role = "editor"
+	// Can also delete, manage users and send emails
+	RoleAdmin Role = "admin"
+)
+
+var Roles = []Role{RoleViewer, RoleEditor, RoleAdmin}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if role == r {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (r Role) IsValid() bool {
+	return r.rank() > 0
+}
+
+// Whether the role includes everything the required role can do
+func (r Role) Allows(required Role) bool {
+	return r.IsValid() && r.rank() >= required.rank()
+}
+
+type User struct {
+	Id    int
+	Email string
+	Name  string
+	Role  Role
+	// Only set when loading by email for checking a login
+	passwordHash string
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Whether the user has at least the given role
+func (u *User) Can(role Role) bool {
+	return u != nil && u.Role.Allows(role)
+}
+
+// Hash compared against when a login uses an unknown email, so it takes as
+// long as a wrong password does
+var unknownUserPasswordHash, _ = HashPassword("unknown user")
+
+// Check an email and password, returning the user when they match
+func AuthenticateUser(store Store, email string, password string) (*User, error) {
+	user, err := store.LoadUserByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		bcrypt.CompareHashAndPassword([]byte(unknownUserPasswordHash), []byte(password))
+		return nil, nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.passwordHash), []byte(password)) != nil {
+		return nil, nil
+	}
+	return user, nil
+}
+
+func (s *MySqlStore) LoadUserById(id int) (*User, error) {
+	defer trace(traceName(fmt.Sprintf("LoadUserById(%d)", id)))
+	var item User
+	err := s.db.QueryRow("SELECT id, email, name, role FROM users WHERE id=?", id).Scan(&item.Id, &item.Email, &item.Name, &item.Role)
+	if err == sql.ErrNoRows {
+		return nil, notFoundf("User not found with id: %d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Load a user along with their password hash, or nil when no user has the email
+func (s *MySqlStore) LoadUserByEmail(email string) (*User, error) {
+	defer trace(traceName(fmt.Sprintf("LoadUserByEmail(%s)", email)))
+	var item User
+	err := s.db.QueryRow(
+		"SELECT id, email, name, role, password_hash FROM users WHERE email=?",
+		email).Scan(&item.Id, &item.Email, &item.Name, &item.Role, &item.passwordHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *MySqlStore) LoadUserList() ([]User, error) {
+	defer trace(traceName("LoadUserList"))
+	rows, err := s.db.Query("SELECT id, email, name, role FROM users ORDER BY name, email")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var item User
+		err = rows.Scan(&item.Id, &item.Email, &item.Name, &item.Role)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, item)
+	}
+	return users, nil
+}
+
+func (s *MySqlStore) InsertUser(email string, name string, role Role, passwordHash string) (int, error) {
+	defer trace(traceName(fmt.Sprintf("InsertUser(%s, %s, %s)", email, name, role)))
+	res, err := s.db.Exec(
+		"INSERT INTO users (email, name, role, password_hash, created_at) VALUES (?, ?, ?, ?, ?)",
+		email, name, role, passwordHash, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	userId, err := res.LastInsertId()
+	return int(userId), err
+}
+
+func (s *MySqlStore) UpdateUserPassword(userId int, passwordHash string) error {
+	defer trace(traceName(fmt.Sprintf("UpdateUserPassword(%d)", userId)))
+	_, err := s.db.Exec("UPDATE users SET password_hash=? WHERE id=?", passwordHash, userId)
+	return err
+}
+
+func (s *MySqlStore) UpdateUserRole(userId int, role Role) error {
+	defer trace(traceName(fmt.Sprintf("UpdateUserRole(%d, %s)", userId, role)))
+	_, err := s.db.Exec("UPDATE users SET role=? WHERE id=?", role, userId)
+	return err
+}
+
+func (s *MySqlStore) UpdateUserCalendarToken(userId int, tokenHash string) error {
+	defer trace(traceName(fmt.Sprintf("UpdateUserCalendarToken(%d)", userId)))
+	_, err := s.db.Exec("UPDATE users SET calendar_token_hash=? WHERE id=?", tokenHash, userId)
+	return err
+}
+
+// Load the user a calendar feed token belongs to, or nil when it's unknown
+func (s *MySqlStore) LoadUserByCalendarToken(tokenHash string) (*User, error) {
+	var item User
+	err := s.db.QueryRow(
+		"SELECT id, email, name, role FROM users WHERE calendar_token_hash=?",
+		tokenHash).Scan(&item.Id, &item.Email, &item.Name, &item.Role)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *MySqlStore) InsertSession(tokenHash string, userId int, expiresAt time.Time) error {
+	defer trace(traceName(fmt.Sprintf("InsertSession(%d)", userId)))
+	_, err := s.db.Exec(
+		"INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+		tokenHash, userId, expiresAt.UTC().Format("2006-01-02 15:04:05"))
+	return err
+}
+
+// Load the user a session belongs to, or nil when the session is unknown or has expired
+func (s *MySqlStore) LoadUserBySession(tokenHash string) (*User, error) {
+	var item User
+	err := s.db.QueryRow(
+		"SELECT u.id, u.email, u.name, u.role"+
+			" FROM sessions s"+
+			"  INNER JOIN users u ON u.id = s.user_id"+
+			" WHERE s.token_hash=? AND s.expires_at > ?",
+		tokenHash, time.Now().UTC().Format("2006-01-02 15:04:05")).Scan(&item.Id, &item.Email, &item.Name, &item.Role)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (s *MySqlStore) DeleteSession(tokenHash string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE token_hash=?", tokenHash)
+	return err
+}
+
+func (s *MySqlStore) DeleteExpiredSessions() error {
+	defer trace(traceName("DeleteExpiredSessions"))
+	_, err := s.db.Exec("DELETE FROM sessions WHERE expires_at <= ?", time.Now().UTC().Format("2006-01-02 15:04:05"))
+	return err
+}